@@ -5,6 +5,7 @@
 package benchmark
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -21,9 +22,12 @@ import (
 	"github.com/hanwen/go-fuse/v2/internal/testutil"
 )
 
+var numQueues = flag.Int("queues", 0, "number of FUSE device queues (MountOptions.NumQueues)")
+
 func setupFS(node fs.InodeEmbedder, N int, tb testing.TB) string {
 	opts := &fs.Options{}
 	opts.Debug = testutil.VerboseTest()
+	opts.NumQueues = *numQueues
 	mountPoint := tb.TempDir()
 	server, err := fs.Mount(mountPoint, node, opts)
 	if err != nil {
@@ -0,0 +1,66 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// mountbroker is a privileged daemon that mounts FUSE file systems
+// for unprivileged clients, which set MountOptions.MountBroker to its
+// socket. It must run as root, or with CAP_SYS_ADMIN.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hanwen/go-fuse/v2/fuse/mountbroker"
+)
+
+func main() {
+	socket := flag.String("socket", "/run/go-fuse-mountbroker.sock", "path of the unix socket to listen on.")
+	mode := flag.String("mode", "0666", "permissions of the socket.")
+	prefixes := flag.String("prefixes", "", "comma-separated directories below which mounts are allowed.")
+	uids := flag.String("uids", "", "comma-separated users that may mount. If empty, all users may.")
+	allowOther := flag.Bool("allow_other", false, "allow the allow_other mount option.")
+	verbose := flag.Bool("verbose", false, "log requests.")
+	flag.Parse()
+
+	b := &mountbroker.Broker{}
+	if *prefixes != "" {
+		b.Policy.MountPrefixes = strings.Split(*prefixes, ",")
+	}
+	if *uids != "" {
+		b.Policy.AllowedUIDs = []uint32{}
+		for _, s := range strings.Split(*uids, ",") {
+			uid, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				log.Fatalf("bad uid %q: %v", s, err)
+			}
+			b.Policy.AllowedUIDs = append(b.Policy.AllowedUIDs, uint32(uid))
+		}
+	}
+	b.Policy.AllowOther = *allowOther
+	if *verbose {
+		b.Logger = log.Default()
+	}
+
+	perm, err := strconv.ParseUint(*mode, 8, 32)
+	if err != nil {
+		log.Fatalf("bad mode %q: %v", *mode, err)
+	}
+	os.Remove(*socket)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: *socket, Net: "unix"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chmod(*socket, os.FileMode(perm)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("listening on %s\n", *socket)
+	log.Fatal(b.Serve(l))
+}
@@ -12,6 +12,9 @@ import (
 	"sync"
 	"syscall"
 	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
 type hangingRootNode struct {
@@ -76,3 +79,98 @@ func TestAbort(t *testing.T) {
 		t.Error("should have been canceled.")
 	}
 }
+
+func TestConnection(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs linux")
+	}
+	hr := &hangingRootNode{
+		openCalled: make(chan struct{}, 0),
+	}
+	dir, srv := testMount(t, hr, nil)
+
+	conn, err := srv.Connection()
+	if err != nil {
+		t.Fatalf("Connection: %v", err)
+	}
+	if err := conn.SetMaxBackground(20); err != nil {
+		t.Fatalf("SetMaxBackground: %v", err)
+	}
+	if got, err := conn.MaxBackground(); err != nil || got != 20 {
+		t.Errorf("MaxBackground: got %d, %v, want 20", got, err)
+	}
+	if err := conn.SetCongestionThreshold(15); err != nil {
+		t.Fatalf("SetCongestionThreshold: %v", err)
+	}
+	if got, err := conn.CongestionThreshold(); err != nil || got != 15 {
+		t.Errorf("CongestionThreshold: got %d, %v, want 15", got, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := syscall.Open(dir, syscall.O_DIRECTORY, 0)
+		done <- err
+	}()
+	<-hr.openCalled
+
+	// Background requests, such as the RELEASE of the directory
+	// that WaitMount polled, may still be in flight.
+	var got int
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		got, err = conn.Waiting()
+		if err != nil || got == 1 || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil || got != 1 {
+		t.Errorf("Waiting: got %d, %v, want 1", got, err)
+	}
+	if err := conn.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Error("opendir should have failed")
+	}
+}
+
+func TestConnectionHungMount(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs linux")
+	}
+	root := newBlockingNode()
+	zero := time.Duration(0)
+	dir, srv := testMount(t, root, &Options{AttrTimeout: &zero, EntryTimeout: &zero})
+
+	root.blockGetattr.Store(true)
+	done := make(chan error, 1)
+	go func() {
+		var st syscall.Stat_t
+		done <- syscall.Stat(dir, &st)
+	}()
+	<-root.started
+
+	type result struct {
+		conn *fuse.Connection
+		err  error
+	}
+	res := make(chan result, 1)
+	go func() {
+		conn, err := srv.Connection()
+		res <- result{conn, err}
+	}()
+	var r result
+	select {
+	case r = <-res:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connection blocked on the hung mount")
+	}
+	if r.err != nil {
+		t.Fatalf("Connection: %v", r.err)
+	}
+	if err := r.conn.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Error("stat on aborted mount should have failed")
+	}
+}
@@ -217,7 +217,10 @@
 // not block, but if files are on a FUSE filesystem, the kernel will
 // generate a POLL operation. To prevent this from happening, Go-FUSE
 // disables the POLL opcode on mount. To ensure this has happened, call
-// WaitMount.
+// WaitMount. If fuse.MountOptions.EnablePoll is set, POLL is served
+// instead: files without NodePoller or FilePoller are reported as
+// ready without calling into the file system, and Poll
+// implementations must not block or open files.
 //
 // 3. Memory mapping a file served by FUSE. Accessing the mapped
 // memory generates a page fault, which blocks the OS thread running
@@ -286,6 +289,14 @@ type NodeStatfser interface {
 	Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno
 }
 
+// Syncfs flushes all data of the file system to stable storage, see
+// syncfs(2). It is only called on the root node. If not defined, the
+// kernel stops sending SYNCFS requests, and syncfs(2) only flushes the
+// kernel's caches.
+type NodeSyncfser interface {
+	Syncfs(ctx context.Context) syscall.Errno
+}
+
 // Access should return if the caller can access the file with the
 // given mode.  This is used for two purposes: to determine if a user
 // may enter a directory, and to implement the access system
@@ -426,6 +437,15 @@ type NodeCopyFileRanger interface {
 	// Ugh. should have been called Copyfilerange
 }
 
+// CopyFileRange64 is like NodeCopyFileRanger, but can copy 4 GiB or
+// more in one call. If not defined, NodeCopyFileRanger is used with
+// the length capped to 32 bits.
+type NodeCopyFileRanger64 interface {
+	CopyFileRange64(ctx context.Context, fhIn FileHandle,
+		offIn uint64, out *Inode, fhOut FileHandle, offOut uint64,
+		len uint64, flags uint64) (uint64, syscall.Errno)
+}
+
 type NodeStatxer interface {
 	Statx(ctx context.Context, f FileHandle, flags uint32, mask uint32, out *fuse.StatxOut) syscall.Errno
 }
@@ -437,6 +457,18 @@ type NodeLseeker interface {
 	Lseek(ctx context.Context, f FileHandle, Off uint64, whence uint32) (uint64, syscall.Errno)
 }
 
+// Poll reports the I/O readiness of an open file for poll(2),
+// select(2) and epoll(7), and is only called if
+// fuse.MountOptions.EnablePoll is set. `events` is the requested
+// event mask (POLLIN, POLLOUT, etc.), and the ready events should be
+// returned. Poll must not block. If `flags` contains
+// fuse.FUSE_POLL_SCHEDULE_NOTIFY, the kernel waits for
+// readiness changes: store `kh` and call Inode.NotifyPoll(kh) when
+// the file becomes ready. If not defined, files are always ready.
+type NodePoller interface {
+	Poll(ctx context.Context, f FileHandle, kh uint64, flags uint32, events uint32) (revents uint32, errno syscall.Errno)
+}
+
 // Getlk returns locks that would conflict with the given input
 // lock. If no locks conflict, the output has type L_UNLCK. See
 // fcntl(2) for more information.
@@ -598,6 +630,16 @@ type NodeCreater interface {
 	Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (node *Inode, fh FileHandle, fuseFlags uint32, errno syscall.Errno)
 }
 
+// Tmpfile creates and opens an unnamed regular file in this
+// directory, for open(2) with O_TMPFILE. The returned Inode is not
+// added to the tree. Unless `flags` has O_EXCL, it can be given a
+// name later with linkat(2), which calls NodeLinker.Link with the
+// Inode as target; the bridge then adds the same Inode under the new
+// name. If not defined, O_TMPFILE fails with EOPNOTSUPP.
+type NodeTmpfiler interface {
+	Tmpfile(ctx context.Context, flags uint32, mode uint32, out *fuse.EntryOut) (node *Inode, fh FileHandle, fuseFlags uint32, errno syscall.Errno)
+}
+
 // Unlink should remove a child from this directory.  If the
 // return status is OK, the Inode is removed as child in the
 // FS tree automatically. Default is to return success.
@@ -694,6 +736,11 @@ type FileLseeker interface {
 	Lseek(ctx context.Context, off uint64, whence uint32) (uint64, syscall.Errno)
 }
 
+// See NodePoller.
+type FilePoller interface {
+	Poll(ctx context.Context, kh uint64, flags uint32, events uint32) (revents uint32, errno syscall.Errno)
+}
+
 // See NodeFlusher.
 type FileFlusher interface {
 	Flush(ctx context.Context) syscall.Errno
@@ -0,0 +1,102 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// blockingNode is a file whose reads block until release is closed,
+// or the read is interrupted. Released reads return "hello". Once
+// blockGetattr is set, Getattr blocks in the same way.
+type blockingNode struct {
+	Inode
+
+	// blockOnce makes only the first read block.
+	blockOnce    bool
+	blockGetattr atomic.Bool
+
+	reads atomic.Int32
+
+	// started receives a value when a call blocks, if it has
+	// room.
+	started chan struct{}
+	release chan struct{}
+
+	// interrupted is closed when a blocked call is interrupted.
+	interrupted   chan struct{}
+	interruptOnce sync.Once
+}
+
+var _ = (NodeOpener)((*blockingNode)(nil))
+var _ = (NodeReader)((*blockingNode)(nil))
+var _ = (NodeGetattrer)((*blockingNode)(nil))
+
+func newBlockingNode() *blockingNode {
+	return &blockingNode{
+		started:     make(chan struct{}, 1),
+		release:     make(chan struct{}),
+		interrupted: make(chan struct{}),
+	}
+}
+
+// wait blocks until release is closed, or ctx is canceled.
+func (n *blockingNode) wait(ctx context.Context) syscall.Errno {
+	select {
+	case n.started <- struct{}{}:
+	default:
+	}
+	select {
+	case <-n.release:
+		return 0
+	case <-ctx.Done():
+		n.interruptOnce.Do(func() { close(n.interrupted) })
+		return syscall.EINTR
+	}
+}
+
+func (n *blockingNode) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *blockingNode) Read(ctx context.Context, f FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	if first := n.reads.Add(1) == 1; first || !n.blockOnce {
+		if errno := n.wait(ctx); errno != 0 {
+			return nil, errno
+		}
+	}
+	data := []byte("hello")
+	if off >= int64(len(data)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	return fuse.ReadResultData(data[off:]), 0
+}
+
+func (n *blockingNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755
+	if n.blockGetattr.Load() {
+		return n.wait(ctx)
+	}
+	return 0
+}
+
+// mountBlockingNode mounts a directory that holds node as "file".
+func mountBlockingNode(t *testing.T, node *blockingNode, opts *Options) (string, *fuse.Server) {
+	root := &Inode{}
+	if opts == nil {
+		opts = &Options{}
+	}
+	opts.OnAdd = func(ctx context.Context) {
+		root.AddChild("file",
+			root.NewPersistentInode(ctx, node, StableAttr{}), false)
+	}
+	return testMount(t, root, opts)
+}
@@ -29,6 +29,10 @@ type fileEntry struct {
 	// Handle number which we communicate to the kernel.
 	fh uint32
 
+	// The flags the file was opened with, so it can be opened
+	// again after a handoff.
+	openFlags uint32
+
 	// Protects directory fields. Must be acquired before bridge.mu
 	mu sync.Mutex
 
@@ -65,6 +69,14 @@ type ServerCallbacks interface {
 	InodeNotifyStoreCache(node uint64, offset int64, data []byte) fuse.Status
 }
 
+type serverEntryExpireCallbacks interface {
+	EntryNotifyExpire(parent uint64, name string) fuse.Status
+}
+
+type serverPollCallbacks interface {
+	PollNotify(kh uint64) fuse.Status
+}
+
 // TODO: fold serverBackingFdCallbacks into ServerCallbacks and bump API version
 type serverBackingFdCallbacks interface {
 	RegisterBackingFd(*fuse.BackingMap) (int32, syscall.Errno)
@@ -72,6 +84,11 @@ type serverBackingFdCallbacks interface {
 }
 
 type rawBridge struct {
+	// RawFileSystem serves the fuse.RawFileSystem API through
+	// ctxBridge.
+	fuse.RawFileSystem
+	ctxBridge *rawBridgeCtx
+
 	options Options
 	root    *Inode
 	server  ServerCallbacks
@@ -172,6 +189,7 @@ func (b *rawBridge) newInode(ctx context.Context, ops InodeEmbedder, id StableAt
 }
 
 // addNewChild inserts the child into the tree. Returns file handle if file != nil.
+// If parent is nil, the child is only registered with the kernel, eg. for O_TMPFILE.
 // Unless fileFlags has the syscall.O_EXCL bit set, child.stableAttr will be used
 // to find an already-known node. If one is found, `child` is ignored and the
 // already-known one is used. The node that was actually used is returned.
@@ -240,7 +258,9 @@ func (b *rawBridge) addNewChild(parent *Inode, name string, child *Inode, file F
 		fe = b.registerFile(child, file, fileFlags)
 	}
 
-	parent.setEntry(name, child)
+	if parent != nil {
+		parent.setEntry(name, child)
+	}
 
 	out.NodeId = child.nodeId
 	out.Generation = child.stableAttr.Gen
@@ -288,6 +308,11 @@ func (b *rawBridge) setAttrTimeout(out *fuse.AttrOut) {
 // InodeEmbedder instance for the root of the tree.
 // If nil is given as opts, default settings are
 // applied, which are 1 second entry and attribute timeout.
+//
+// The file system also implements fuse.RawFileSystemCtx, which
+// passes the context it is called with on to the nodes. Use
+// fuse.NewRawFileSystemCtx to obtain it, for example to wrap it in
+// middleware that adds values or deadlines to the context.
 func NewNodeFS(root InodeEmbedder, opts *Options) fuse.RawFileSystem {
 	if opts == nil {
 		oneSec := time.Second
@@ -304,6 +329,9 @@ func NewNodeFS(root InodeEmbedder, opts *Options) fuse.RawFileSystem {
 		options:      *opts,
 	}
 
+	bridge.ctxBridge = &rawBridgeCtx{bridge}
+	bridge.RawFileSystem = fuse.NewRawFileSystemFromCtx(bridge.ctxBridge)
+
 	if bridge.automaticIno == 0 {
 		bridge.automaticIno = 1 << 63
 	}
@@ -341,6 +369,17 @@ func NewNodeFS(root InodeEmbedder, opts *Options) fuse.RawFileSystem {
 	return bridge
 }
 
+// rawBridgeCtx implements fuse.RawFileSystemCtx for the bridge.
+type rawBridgeCtx struct {
+	*rawBridge
+}
+
+// RawFileSystemCtx returns the bridge as a fuse.RawFileSystemCtx. The
+// fuse.Server uses it to pass contexts on to the nodes.
+func (b *rawBridge) RawFileSystemCtx() fuse.RawFileSystemCtx {
+	return b.ctxBridge
+}
+
 func (b *rawBridge) String() string {
 	return "rawBridge"
 }
@@ -355,9 +394,8 @@ func (b *rawBridge) inode(id uint64, fh uint64) (*Inode, *fileEntry) {
 	return n, f
 }
 
-func (b *rawBridge) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
+func (b *rawBridgeCtx) Lookup(ctx context.Context, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
-	ctx := &fuse.Context{Caller: header.Caller, Cancel: cancel}
 	child, errno := b.lookup(ctx, parent, name, out)
 
 	if errno != 0 {
@@ -374,7 +412,7 @@ func (b *rawBridge) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name s
 	return fuse.OK
 }
 
-func (b *rawBridge) lookup(ctx *fuse.Context, parent *Inode, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+func (b *rawBridge) lookup(ctx context.Context, parent *Inode, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
 	if lu, ok := parent.ops.(NodeLookuper); ok {
 		return lu.Lookup(ctx, name, out)
 	}
@@ -395,11 +433,11 @@ func (b *rawBridge) lookup(ctx *fuse.Context, parent *Inode, name string, out *f
 	return child, OK
 }
 
-func (b *rawBridge) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name string) fuse.Status {
+func (b *rawBridgeCtx) Rmdir(ctx context.Context, header *fuse.InHeader, name string) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
 	var errno syscall.Errno
 	if mops, ok := parent.ops.(NodeRmdirer); ok {
-		errno = mops.Rmdir(&fuse.Context{Caller: header.Caller, Cancel: cancel}, name)
+		errno = mops.Rmdir(ctx, name)
 	}
 
 	// TODO - this should not succeed silently.
@@ -410,11 +448,11 @@ func (b *rawBridge) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name st
 	return errnoToStatus(errno)
 }
 
-func (b *rawBridge) Unlink(cancel <-chan struct{}, header *fuse.InHeader, name string) fuse.Status {
+func (b *rawBridgeCtx) Unlink(ctx context.Context, header *fuse.InHeader, name string) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
 	var errno syscall.Errno
 	if mops, ok := parent.ops.(NodeUnlinker); ok {
-		errno = mops.Unlink(&fuse.Context{Caller: header.Caller, Cancel: cancel}, name)
+		errno = mops.Unlink(ctx, name)
 	}
 
 	// TODO - this should not succeed silently.
@@ -425,10 +463,9 @@ func (b *rawBridge) Unlink(cancel <-chan struct{}, header *fuse.InHeader, name s
 	return errnoToStatus(errno)
 }
 
-func (b *rawBridge) Mkdir(cancel <-chan struct{}, input *fuse.MkdirIn, name string, out *fuse.EntryOut) fuse.Status {
+func (b *rawBridgeCtx) Mkdir(ctx context.Context, input *fuse.MkdirIn, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	mops, ok := parent.ops.(NodeMkdirer)
 	if !ok {
 		return fuse.ENOTSUP
@@ -453,14 +490,13 @@ func (b *rawBridge) Mkdir(cancel <-chan struct{}, input *fuse.MkdirIn, name stri
 	return fuse.OK
 }
 
-func (b *rawBridge) Mknod(cancel <-chan struct{}, input *fuse.MknodIn, name string, out *fuse.EntryOut) fuse.Status {
+func (b *rawBridgeCtx) Mknod(ctx context.Context, input *fuse.MknodIn, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 
 	mops, ok := parent.ops.(NodeMknoder)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	child, errno := mops.Mknod(ctx, name, input.Mode, input.Rdev, out)
 	if errno != 0 {
 		return errnoToStatus(errno)
@@ -472,14 +508,13 @@ func (b *rawBridge) Mknod(cancel <-chan struct{}, input *fuse.MknodIn, name stri
 	return fuse.OK
 }
 
-func (b *rawBridge) Create(cancel <-chan struct{}, input *fuse.CreateIn, name string, out *fuse.CreateOut) fuse.Status {
+func (b *rawBridgeCtx) Create(ctx context.Context, input *fuse.CreateIn, name string, out *fuse.CreateOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 
 	mops, ok := parent.ops.(NodeCreater)
 	if !ok {
 		return fuse.EROFS
 	}
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	child, f, flags, errno := mops.Create(ctx, name, input.Flags, input.Mode, &out.EntryOut)
 
 	if errno != 0 {
@@ -498,6 +533,32 @@ func (b *rawBridge) Create(cancel <-chan struct{}, input *fuse.CreateIn, name st
 	return fuse.OK
 }
 
+func (b *rawBridgeCtx) TmpFile(ctx context.Context, input *fuse.CreateIn, name string, out *fuse.CreateOut) fuse.Status {
+	parent, _ := b.inode(input.NodeId, 0)
+
+	mops, ok := parent.ops.(NodeTmpfiler)
+	if !ok {
+		return fuse.ENOTSUP
+	}
+	child, f, flags, errno := mops.Tmpfile(ctx, input.Flags, input.Mode, &out.EntryOut)
+	if errno != 0 {
+		return errnoToStatus(errno)
+	}
+
+	// The file has no name, so it is not added to the parent.
+	// The kernel can still refer to it by node ID, eg. in LINK.
+	child, fe := b.addNewChild(nil, name, child, f, input.Flags|syscall.O_EXCL, &out.EntryOut)
+	if fe != nil {
+		out.Fh = uint64(fe.fh)
+	}
+	out.OpenFlags = flags
+
+	b.addBackingID(child, f, &out.OpenOut)
+	child.setEntryOut(&out.EntryOut)
+	b.setEntryOutTimeout(&out.EntryOut)
+	return fuse.OK
+}
+
 func (b *rawBridge) Forget(nodeid, nlookup uint64) {
 	n, _ := b.inode(nodeid, 0)
 	hasLookups, _, _ := n.removeRef(nlookup, false)
@@ -545,7 +606,7 @@ func (b *rawBridge) compactMemory() {
 
 func (b *rawBridge) SetDebug(debug bool) {}
 
-func (b *rawBridge) GetAttr(cancel <-chan struct{}, input *fuse.GetAttrIn, out *fuse.AttrOut) fuse.Status {
+func (b *rawBridgeCtx) GetAttr(ctx context.Context, input *fuse.GetAttrIn, out *fuse.AttrOut) fuse.Status {
 	n, fEntry := b.inode(input.NodeId, input.Fh())
 	f := fEntry.file
 	if f == nil {
@@ -561,7 +622,6 @@ func (b *rawBridge) GetAttr(cancel <-chan struct{}, input *fuse.GetAttrIn, out *
 		}
 		b.mu.Unlock()
 	}
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	return errnoToStatus(b.getattr(ctx, n, f, out))
 }
 
@@ -588,8 +648,7 @@ func (b *rawBridge) getattr(ctx context.Context, n *Inode, f FileHandle, out *fu
 	return errno
 }
 
-func (b *rawBridge) SetAttr(cancel <-chan struct{}, in *fuse.SetAttrIn, out *fuse.AttrOut) fuse.Status {
-	ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+func (b *rawBridgeCtx) SetAttr(ctx context.Context, in *fuse.SetAttrIn, out *fuse.AttrOut) fuse.Status {
 
 	fh, _ := in.GetFh()
 
@@ -607,12 +666,12 @@ func (b *rawBridge) SetAttr(cancel <-chan struct{}, in *fuse.SetAttrIn, out *fus
 	return errnoToStatus(errno)
 }
 
-func (b *rawBridge) Rename(cancel <-chan struct{}, input *fuse.RenameIn, oldName string, newName string) fuse.Status {
+func (b *rawBridgeCtx) Rename(ctx context.Context, input *fuse.RenameIn, oldName string, newName string) fuse.Status {
 	p1, _ := b.inode(input.NodeId, 0)
 	p2, _ := b.inode(input.Newdir, 0)
 
 	if mops, ok := p1.ops.(NodeRenamer); ok {
-		errno := mops.Rename(&fuse.Context{Caller: input.Caller, Cancel: cancel}, oldName, p2.ops, newName, input.Flags)
+		errno := mops.Rename(ctx, oldName, p2.ops, newName, input.Flags)
 		if errno == 0 {
 			if input.Flags&RENAME_EXCHANGE != 0 {
 				p1.ExchangeChild(oldName, p2, newName)
@@ -626,7 +685,7 @@ func (b *rawBridge) Rename(cancel <-chan struct{}, input *fuse.RenameIn, oldName
 	return fuse.ENOTSUP
 }
 
-func (b *rawBridge) Link(cancel <-chan struct{}, input *fuse.LinkIn, name string, out *fuse.EntryOut) fuse.Status {
+func (b *rawBridgeCtx) Link(ctx context.Context, input *fuse.LinkIn, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 	target, _ := b.inode(input.Oldnodeid, 0)
 
@@ -635,26 +694,30 @@ func (b *rawBridge) Link(cancel <-chan struct{}, input *fuse.LinkIn, name string
 		return fuse.ENOTSUP
 	}
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	child, errno := mops.Link(ctx, target.ops, name, out)
 	if errno != 0 {
 		return errnoToStatus(errno)
 	}
 
+	if _, p := target.Parent(); p == nil && !target.IsRoot() {
+		// Target has no name yet, eg. because it was created
+		// by NodeTmpfiler. Attach the node the kernel knows,
+		// with its open file handles.
+		child = target
+	}
 	child, _ = b.addNewChild(parent, name, child, nil, 0, out)
 	child.setEntryOut(out)
 	b.setEntryOutTimeout(out)
 	return fuse.OK
 }
 
-func (b *rawBridge) Symlink(cancel <-chan struct{}, header *fuse.InHeader, target string, name string, out *fuse.EntryOut) fuse.Status {
+func (b *rawBridgeCtx) Symlink(ctx context.Context, header *fuse.InHeader, target string, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
 
 	mops, ok := parent.ops.(NodeSymlinker)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	ctx := &fuse.Context{Caller: header.Caller, Cancel: cancel}
 	child, status := mops.Symlink(ctx, target, name, out)
 	if status != 0 {
 		return errnoToStatus(status)
@@ -666,14 +729,13 @@ func (b *rawBridge) Symlink(cancel <-chan struct{}, header *fuse.InHeader, targe
 	return fuse.OK
 }
 
-func (b *rawBridge) Readlink(cancel <-chan struct{}, header *fuse.InHeader) (out []byte, status fuse.Status) {
+func (b *rawBridgeCtx) Readlink(ctx context.Context, header *fuse.InHeader) (out []byte, status fuse.Status) {
 	n, _ := b.inode(header.NodeId, 0)
 
 	linker, ok := n.ops.(NodeReadlinker)
 	if !ok {
 		return nil, fuse.ENOTSUP
 	}
-	ctx := &fuse.Context{Caller: header.Caller, Cancel: cancel}
 	result, errno := linker.Readlink(ctx)
 	if errno != 0 {
 		return nil, errnoToStatus(errno)
@@ -682,10 +744,9 @@ func (b *rawBridge) Readlink(cancel <-chan struct{}, header *fuse.InHeader) (out
 	return result, fuse.OK
 }
 
-func (b *rawBridge) Access(cancel <-chan struct{}, input *fuse.AccessIn) fuse.Status {
+func (b *rawBridgeCtx) Access(ctx context.Context, input *fuse.AccessIn) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if a, ok := n.ops.(NodeAccesser); ok {
 		return errnoToStatus(a.Access(ctx, input.Mask))
 	}
@@ -698,7 +759,13 @@ func (b *rawBridge) Access(cancel <-chan struct{}, input *fuse.AccessIn) fuse.St
 		return errnoToStatus(s)
 	}
 
-	if !internal.HasAccess(caller.Uid, caller.Gid, out.Uid, out.Gid, out.Mode, input.Mask) {
+	// Prefer the groups of the calling process over those in the
+	// user database.
+	if groups, err := fuse.GroupsFromContext(ctx); err == nil {
+		if !internal.HasGroupAccess(caller.Uid, caller.Gid, groups, out.Uid, out.Gid, out.Mode, input.Mask) {
+			return fuse.EACCES
+		}
+	} else if !internal.HasAccess(caller.Uid, caller.Gid, out.Uid, out.Gid, out.Mode, input.Mask) {
 		return fuse.EACCES
 	}
 	return fuse.OK
@@ -706,50 +773,50 @@ func (b *rawBridge) Access(cancel <-chan struct{}, input *fuse.AccessIn) fuse.St
 
 // Extended attributes.
 
-func (b *rawBridge) GetXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr string, data []byte) (uint32, fuse.Status) {
+func (b *rawBridgeCtx) GetXAttr(ctx context.Context, header *fuse.InHeader, attr string, data []byte) (uint32, fuse.Status) {
 	n, _ := b.inode(header.NodeId, 0)
 
 	if xops, ok := n.ops.(NodeGetxattrer); ok {
-		nb, errno := xops.Getxattr(&fuse.Context{Caller: header.Caller, Cancel: cancel}, attr, data)
+		nb, errno := xops.Getxattr(ctx, attr, data)
 		return nb, errnoToStatus(errno)
 	}
 
 	return 0, fuse.ENOATTR
 }
 
-func (b *rawBridge) ListXAttr(cancel <-chan struct{}, header *fuse.InHeader, dest []byte) (sz uint32, status fuse.Status) {
+func (b *rawBridgeCtx) ListXAttr(ctx context.Context, header *fuse.InHeader, dest []byte) (sz uint32, status fuse.Status) {
 	n, _ := b.inode(header.NodeId, 0)
 	if xops, ok := n.ops.(NodeListxattrer); ok {
-		sz, errno := xops.Listxattr(&fuse.Context{Caller: header.Caller, Cancel: cancel}, dest)
+		sz, errno := xops.Listxattr(ctx, dest)
 		return sz, errnoToStatus(errno)
 	}
 	return 0, fuse.OK
 }
 
-func (b *rawBridge) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr string, data []byte) fuse.Status {
+func (b *rawBridgeCtx) SetXAttr(ctx context.Context, input *fuse.SetXAttrIn, attr string, data []byte) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 	if xops, ok := n.ops.(NodeSetxattrer); ok {
-		return errnoToStatus(xops.Setxattr(&fuse.Context{Caller: input.Caller, Cancel: cancel}, attr, data, input.Flags))
+		return errnoToStatus(xops.Setxattr(ctx, attr, data, input.Flags))
 	}
 	return fuse.ENOATTR
 }
 
-func (b *rawBridge) RemoveXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr string) fuse.Status {
+func (b *rawBridgeCtx) RemoveXAttr(ctx context.Context, header *fuse.InHeader, attr string) fuse.Status {
 	n, _ := b.inode(header.NodeId, 0)
 	if xops, ok := n.ops.(NodeRemovexattrer); ok {
-		return errnoToStatus(xops.Removexattr(&fuse.Context{Caller: header.Caller, Cancel: cancel}, attr))
+		return errnoToStatus(xops.Removexattr(ctx, attr))
 	}
 	return fuse.ENOATTR
 }
 
-func (b *rawBridge) Open(cancel <-chan struct{}, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
+func (b *rawBridgeCtx) Open(ctx context.Context, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 
 	op, ok := n.ops.(NodeOpener)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	f, flags, errno := op.Open(&fuse.Context{Caller: input.Caller, Cancel: cancel}, input.Flags)
+	f, flags, errno := op.Open(ctx, input.Flags)
 	if errno != 0 {
 		return errnoToStatus(errno)
 	}
@@ -845,15 +912,26 @@ func (b *rawBridge) registerFile(n *Inode, f FileHandle, flags uint32) *fileEntr
 	}
 	fe.nodeIndex = len(n.openFiles)
 	fe.file = f
+	fe.openFlags = flags
 	n.openFiles = append(n.openFiles, fe.fh)
 
 	return fe
 }
 
-func (b *rawBridge) Read(cancel <-chan struct{}, input *fuse.ReadIn, buf []byte) (fuse.ReadResult, fuse.Status) {
+// openFileHandles returns the file handles that are open on n.
+func (b *rawBridge) openFileHandles(n *Inode) []FileHandle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fhs := make([]FileHandle, 0, len(n.openFiles))
+	for _, fh := range n.openFiles {
+		fhs = append(fhs, b.files[fh].file)
+	}
+	return fhs
+}
+
+func (b *rawBridgeCtx) Read(ctx context.Context, input *fuse.ReadIn, buf []byte) (fuse.ReadResult, fuse.Status) {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if fops, ok := n.ops.(NodeReader); ok {
 		res, errno := fops.Read(ctx, f.file, buf, int64(input.Offset))
 		return res, errnoToStatus(errno)
@@ -866,10 +944,9 @@ func (b *rawBridge) Read(cancel <-chan struct{}, input *fuse.ReadIn, buf []byte)
 	return nil, fuse.ENOTSUP
 }
 
-func (b *rawBridge) GetLk(cancel <-chan struct{}, input *fuse.LkIn, out *fuse.LkOut) fuse.Status {
+func (b *rawBridgeCtx) GetLk(ctx context.Context, input *fuse.LkIn, out *fuse.LkOut) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if lops, ok := n.ops.(NodeGetlker); ok {
 		return errnoToStatus(lops.Getlk(ctx, f.file, input.Owner, &input.Lk, input.LkFlags, &out.Lk))
 	}
@@ -879,9 +956,8 @@ func (b *rawBridge) GetLk(cancel <-chan struct{}, input *fuse.LkIn, out *fuse.Lk
 	return fuse.ENOTSUP
 }
 
-func (b *rawBridge) SetLk(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status {
+func (b *rawBridgeCtx) SetLk(ctx context.Context, input *fuse.LkIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if lops, ok := n.ops.(NodeSetlker); ok {
 		return errnoToStatus(lops.Setlk(ctx, f.file, input.Owner, &input.Lk, input.LkFlags))
 	}
@@ -890,9 +966,8 @@ func (b *rawBridge) SetLk(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status
 	}
 	return fuse.ENOTSUP
 }
-func (b *rawBridge) SetLkw(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status {
+func (b *rawBridgeCtx) SetLkw(ctx context.Context, input *fuse.LkIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if lops, ok := n.ops.(NodeSetlkwer); ok {
 		return errnoToStatus(lops.Setlkw(ctx, f.file, input.Owner, &input.Lk, input.LkFlags))
 	}
@@ -902,7 +977,7 @@ func (b *rawBridge) SetLkw(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status
 	return fuse.ENOTSUP
 }
 
-func (b *rawBridge) Release(cancel <-chan struct{}, input *fuse.ReleaseIn) {
+func (b *rawBridgeCtx) Release(ctx context.Context, input *fuse.ReleaseIn) {
 	n, f := b.releaseFileEntry(input.NodeId, input.Fh)
 	if f == nil {
 		return
@@ -910,7 +985,6 @@ func (b *rawBridge) Release(cancel <-chan struct{}, input *fuse.ReleaseIn) {
 
 	f.wg.Wait()
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if r, ok := n.ops.(NodeReleaser); ok {
 		r.Release(ctx, f.file)
 	} else if r, ok := f.file.(FileReleaser); ok {
@@ -963,10 +1037,9 @@ func (b *rawBridge) releaseFileEntry(nid uint64, fh uint64) (*Inode, *fileEntry)
 	return n, entry
 }
 
-func (b *rawBridge) Write(cancel <-chan struct{}, input *fuse.WriteIn, data []byte) (written uint32, status fuse.Status) {
+func (b *rawBridgeCtx) Write(ctx context.Context, input *fuse.WriteIn, data []byte) (written uint32, status fuse.Status) {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if wr, ok := n.ops.(NodeWriter); ok {
 		w, errno := wr.Write(ctx, f.file, data, int64(input.Offset))
 		return w, errnoToStatus(errno)
@@ -979,9 +1052,8 @@ func (b *rawBridge) Write(cancel <-chan struct{}, input *fuse.WriteIn, data []by
 	return 0, fuse.ENOTSUP
 }
 
-func (b *rawBridge) Flush(cancel <-chan struct{}, input *fuse.FlushIn) fuse.Status {
+func (b *rawBridgeCtx) Flush(ctx context.Context, input *fuse.FlushIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if fl, ok := n.ops.(NodeFlusher); ok {
 		return errnoToStatus(fl.Flush(ctx, f.file))
 	}
@@ -991,9 +1063,8 @@ func (b *rawBridge) Flush(cancel <-chan struct{}, input *fuse.FlushIn) fuse.Stat
 	return 0
 }
 
-func (b *rawBridge) Fsync(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.Status {
+func (b *rawBridgeCtx) Fsync(ctx context.Context, input *fuse.FsyncIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if fs, ok := n.ops.(NodeFsyncer); ok {
 		return errnoToStatus(fs.Fsync(ctx, f.file, input.FsyncFlags))
 	}
@@ -1003,9 +1074,8 @@ func (b *rawBridge) Fsync(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.Stat
 	return fuse.ENOTSUP
 }
 
-func (b *rawBridge) Fallocate(cancel <-chan struct{}, input *fuse.FallocateIn) fuse.Status {
+func (b *rawBridgeCtx) Fallocate(ctx context.Context, input *fuse.FallocateIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if a, ok := n.ops.(NodeAllocater); ok {
 		return errnoToStatus(a.Allocate(ctx, f.file, input.Offset, input.Length, input.Mode))
 	}
@@ -1015,56 +1085,48 @@ func (b *rawBridge) Fallocate(cancel <-chan struct{}, input *fuse.FallocateIn) f
 	return fuse.ENOTSUP
 }
 
-func (b *rawBridge) OpenDir(cancel <-chan struct{}, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
+func (b *rawBridgeCtx) OpenDir(ctx context.Context, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 
-	var fh FileHandle
-	var fuseFlags uint32
-	var errno syscall.Errno
-
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-
-	nod, _ := n.ops.(NodeOpendirer)
-	nrd, _ := n.ops.(NodeReaddirer)
-
-	if odh, ok := n.ops.(NodeOpendirHandler); ok {
-		fh, fuseFlags, errno = odh.OpendirHandle(ctx, input.Flags)
-
-		if errno != 0 {
-			return errnoToStatus(errno)
-		}
-	} else {
-		if nod != nil {
-			errno = nod.Opendir(ctx)
-			if errno != 0 {
-				return errnoToStatus(errno)
-			}
-		}
-
-		var ctor func(context.Context) (DirStream, syscall.Errno)
-		if nrd != nil {
-			ctor = func(ctx context.Context) (DirStream, syscall.Errno) {
-				return nrd.Readdir(ctx)
-			}
-		} else {
-			ctor = func(ctx context.Context) (DirStream, syscall.Errno) {
-				return n.childrenAsDirstream(), 0
-			}
-		}
-		fh = &dirStreamAsFile{creator: ctor}
+	fh, fuseFlags, errno := b.openDir(ctx, n, input.Flags)
+	if errno != 0 {
+		return errnoToStatus(errno)
 	}
-
 	if fuseFlags&(fuse.FOPEN_CACHE_DIR|fuse.FOPEN_KEEP_CACHE) != 0 {
 		fuseFlags |= fuse.FOPEN_CACHE_DIR | fuse.FOPEN_KEEP_CACHE
 	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	fe := b.registerFile(n, fh, 0)
+	fe := b.registerFile(n, fh, input.Flags)
 	out.Fh = uint64(fe.fh)
 	out.OpenFlags = fuseFlags
 	return fuse.OK
 }
 
+// openDir returns the handle for reading directory n.
+func (b *rawBridge) openDir(ctx context.Context, n *Inode, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	if odh, ok := n.ops.(NodeOpendirHandler); ok {
+		return odh.OpendirHandle(ctx, flags)
+	}
+	if nod, ok := n.ops.(NodeOpendirer); ok {
+		if errno := nod.Opendir(ctx); errno != 0 {
+			return nil, 0, errno
+		}
+	}
+
+	var ctor func(context.Context) (DirStream, syscall.Errno)
+	if nrd, ok := n.ops.(NodeReaddirer); ok {
+		ctor = func(ctx context.Context) (DirStream, syscall.Errno) {
+			return nrd.Readdir(ctx)
+		}
+	} else {
+		ctor = func(ctx context.Context) (DirStream, syscall.Errno) {
+			return n.childrenAsDirstream(), 0
+		}
+	}
+	return &dirStreamAsFile{creator: ctor}, 0, 0
+}
+
 func (n *Inode) childrenAsDirstream() DirStream {
 	lst := n.childrenList()
 	r := make([]fuse.DirEntry, 0, len(lst))
@@ -1076,15 +1138,15 @@ func (n *Inode) childrenAsDirstream() DirStream {
 	return NewListDirStream(r)
 }
 
-func (b *rawBridge) ReadDirPlus(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
-	return b.readDirMaybeLookup(cancel, input, out, true)
+func (b *rawBridgeCtx) ReadDirPlus(ctx context.Context, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
+	return b.readDirMaybeLookup(ctx, input, out, true)
 }
 
-func (b *rawBridge) ReadDir(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
-	return b.readDirMaybeLookup(cancel, input, out, false)
+func (b *rawBridgeCtx) ReadDir(ctx context.Context, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
+	return b.readDirMaybeLookup(ctx, input, out, false)
 }
 
-func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList, lookup bool) fuse.Status {
+func (b *rawBridge) readDirMaybeLookup(ctx context.Context, input *fuse.ReadIn, out *fuse.DirEntryList, lookup bool) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
 
 	direnter, ok := f.file.(FileReaddirenter)
@@ -1096,7 +1158,6 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	interruptedRead := false
 	if input.Offset != f.dirOffset {
 		// If the last readdir(plus) was interrupted, the
@@ -1231,9 +1292,8 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 	return fuse.OK
 }
 
-func (b *rawBridge) FsyncDir(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.Status {
+func (b *rawBridgeCtx) FsyncDir(ctx context.Context, input *fuse.FsyncIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
 	if fsd, ok := f.file.(FileFsyncdirer); ok {
 		return errnoToStatus(fsd.Fsyncdir(ctx, input.FsyncFlags))
 	} else if fs, ok := n.ops.(NodeFsyncer); ok {
@@ -1243,21 +1303,28 @@ func (b *rawBridge) FsyncDir(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.S
 	return fuse.ENOTSUP
 }
 
-func (b *rawBridge) StatFs(cancel <-chan struct{}, input *fuse.InHeader, out *fuse.StatfsOut) fuse.Status {
+func (b *rawBridgeCtx) StatFs(ctx context.Context, input *fuse.InHeader, out *fuse.StatfsOut) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 	if sf, ok := n.ops.(NodeStatfser); ok {
-		return errnoToStatus(sf.Statfs(&fuse.Context{Caller: input.Caller, Cancel: cancel}, out))
+		return errnoToStatus(sf.Statfs(ctx, out))
 	}
 
 	// leave zeroed out
 	return fuse.OK
 }
 
+func (b *rawBridgeCtx) SyncFs(ctx context.Context, input *fuse.SyncFsIn) fuse.Status {
+	if sf, ok := b.root.ops.(NodeSyncfser); ok {
+		return errnoToStatus(sf.Syncfs(ctx))
+	}
+	return fuse.ENOSYS
+}
+
 func (b *rawBridge) Init(s *fuse.Server) {
 	b.server = s
 }
 
-func (b *rawBridge) CopyFileRange(cancel <-chan struct{}, in *fuse.CopyFileRangeIn) (size uint32, status fuse.Status) {
+func (b *rawBridgeCtx) CopyFileRange(ctx context.Context, in *fuse.CopyFileRangeIn) (size uint32, status fuse.Status) {
 	n1, f1 := b.inode(in.NodeId, in.FhIn)
 	cfr, ok := n1.ops.(NodeCopyFileRanger)
 	if !ok {
@@ -1266,21 +1333,34 @@ func (b *rawBridge) CopyFileRange(cancel <-chan struct{}, in *fuse.CopyFileRange
 
 	n2, f2 := b.inode(in.NodeIdOut, in.FhOut)
 
-	sz, errno := cfr.CopyFileRange(&fuse.Context{Caller: in.Caller, Cancel: cancel},
+	sz, errno := cfr.CopyFileRange(ctx,
 		f1.file, in.OffIn, n2, f2.file, in.OffOut, in.Len, in.Flags)
 	return sz, errnoToStatus(errno)
 }
 
-func (b *rawBridge) Ioctl(cancel <-chan struct{}, in *fuse.IoctlIn, inbuf []byte, out *fuse.IoctlOut, outbuf []byte) (code fuse.Status) {
+func (b *rawBridgeCtx) CopyFileRange64(ctx context.Context, in *fuse.CopyFileRangeIn) (size uint64, status fuse.Status) {
+	n1, f1 := b.inode(in.NodeId, in.FhIn)
+	cfr, ok := n1.ops.(NodeCopyFileRanger64)
+	if !ok {
+		// fall back to CopyFileRange.
+		return 0, fuse.ENOSYS
+	}
+
+	n2, f2 := b.inode(in.NodeIdOut, in.FhOut)
+
+	sz, errno := cfr.CopyFileRange64(ctx,
+		f1.file, in.OffIn, n2, f2.file, in.OffOut, in.Len, in.Flags)
+	return sz, errnoToStatus(errno)
+}
+
+func (b *rawBridgeCtx) Ioctl(ctx context.Context, in *fuse.IoctlIn, inbuf []byte, out *fuse.IoctlOut, outbuf []byte) (code fuse.Status) {
 	n, f := b.inode(in.NodeId, in.Fh)
 	if nio, ok := n.ops.(NodeIoctler); ok {
-		ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
 		result, errno := nio.Ioctl(ctx, f.file, in.Cmd, in.Arg, inbuf, outbuf)
 		out.Result = result
 		return errnoToStatus(errno)
 	}
 	if fio, ok := f.file.(FileIoctler); ok {
-		ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
 		result, errno := fio.Ioctl(ctx, in.Cmd, in.Arg, inbuf, outbuf)
 		out.Result = result
 		return errnoToStatus(errno)
@@ -1288,10 +1368,30 @@ func (b *rawBridge) Ioctl(cancel <-chan struct{}, in *fuse.IoctlIn, inbuf []byte
 	return fuse.Status(syscall.ENOTTY)
 }
 
-func (b *rawBridge) Lseek(cancel <-chan struct{}, in *fuse.LseekIn, out *fuse.LseekOut) fuse.Status {
+func (b *rawBridgeCtx) Poll(ctx context.Context, in *fuse.PollIn, out *fuse.PollOut) fuse.Status {
 	n, f := b.inode(in.NodeId, in.Fh)
 
-	ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+	if np, ok := n.ops.(NodePoller); ok {
+		revents, errno := np.Poll(ctx, f.file, in.Kh, in.Flags, in.Events)
+		out.Revents = revents
+		return errnoToStatus(errno)
+	}
+	if fp, ok := f.file.(FilePoller); ok {
+		revents, errno := fp.Poll(ctx, in.Kh, in.Flags, in.Events)
+		out.Revents = revents
+		return errnoToStatus(errno)
+	}
+
+	// Files that do not support polling are always ready, like
+	// regular files on other file systems. This reply does not
+	// involve file system code, so the Go runtime registering
+	// files with its poller cannot deadlock.
+	out.Revents = _DEFAULT_POLLMASK
+	return fuse.OK
+}
+
+func (b *rawBridgeCtx) Lseek(ctx context.Context, in *fuse.LseekIn, out *fuse.LseekOut) fuse.Status {
+	n, f := b.inode(in.NodeId, in.Fh)
 
 	ls, ok := n.ops.(NodeLseeker)
 	if ok {
@@ -2,8 +2,12 @@
 
 package fs
 
-import "github.com/hanwen/go-fuse/v2/fuse"
+import (
+	"context"
 
-func (b *rawBridge) Statx(cancel <-chan struct{}, in *fuse.StatxIn, out *fuse.StatxOut) fuse.Status {
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func (b *rawBridgeCtx) Statx(ctx context.Context, in *fuse.StatxIn, out *fuse.StatxOut) fuse.Status {
 	return fuse.ENOSYS
 }
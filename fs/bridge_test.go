@@ -284,3 +284,64 @@ func TestNegativeLookupCache(t *testing.T) {
 func TestNewNodeFSNilOpts(t *testing.T) {
 	NewNodeFS(&Inode{}, nil)
 }
+
+type traceKey struct{}
+
+// traceFS adds a value to the context of each Lookup.
+type traceFS struct {
+	fuse.RawFileSystemCtx
+}
+
+func (fs *traceFS) Lookup(ctx context.Context, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
+	return fs.RawFileSystemCtx.Lookup(context.WithValue(ctx, traceKey{}, name), header, name, out)
+}
+
+// ctxNode records the context of the last Lookup.
+type ctxNode struct {
+	Inode
+
+	ctx context.Context
+}
+
+var _ = (NodeLookuper)((*ctxNode)(nil))
+
+func (n *ctxNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	n.ctx = ctx
+	return n.NewInode(ctx, &Inode{}, StableAttr{}), 0
+}
+
+func TestRawFileSystemCtx(t *testing.T) {
+	root := &ctxNode{}
+	rawFS := fuse.NewRawFileSystemFromCtx(&traceFS{fuse.NewRawFileSystemCtx(NewNodeFS(root, nil))})
+
+	cancel := make(chan struct{})
+	header := fuse.InHeader{
+		NodeId: 1,
+		Unique: 42,
+		Caller: fuse.Caller{Owner: fuse.Owner{Uid: 123}},
+	}
+	var out fuse.EntryOut
+	if st := rawFS.Lookup(cancel, &header, "file", &out); !st.Ok() {
+		t.Fatalf("Lookup: %v", st)
+	}
+
+	ctx := root.ctx
+	if got := ctx.Value(traceKey{}); got != "file" {
+		t.Errorf("got trace value %v, want %q", got, "file")
+	}
+	if got, ok := fuse.UniqueFromContext(ctx); !ok || got != 42 {
+		t.Errorf("got unique %d, %v, want 42", got, ok)
+	}
+	if caller, ok := fuse.FromContext(ctx); !ok || caller.Uid != 123 {
+		t.Errorf("got caller %v, %v, want uid 123", caller, ok)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("context canceled early: %v", ctx.Err())
+	}
+	close(cancel)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not canceled")
+	}
+}
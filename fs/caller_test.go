@@ -0,0 +1,213 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// callerNode records the security context and the groups of the
+// callers that create files.
+type callerNode struct {
+	Inode
+
+	mu      sync.Mutex
+	names   []string
+	secctx  map[string]bool
+	groups  []uint32
+	grpErrs []error
+}
+
+var _ = (NodeCreater)((*callerNode)(nil))
+var _ = (NodeMkdirer)((*callerNode)(nil))
+var _ = (NodeSymlinker)((*callerNode)(nil))
+
+func (n *callerNode) record(ctx context.Context, name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.names = append(n.names, name)
+	_, ok := fuse.SecurityContextFromContext(ctx)
+	n.secctx[name] = ok
+	groups, err := fuse.GroupsFromContext(ctx)
+	if err != nil {
+		n.grpErrs = append(n.grpErrs, err)
+	}
+	n.groups = groups
+}
+
+func (n *callerNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*Inode, FileHandle, uint32, syscall.Errno) {
+	n.record(ctx, name)
+	ch := n.NewInode(ctx, &MemRegularFile{}, StableAttr{Mode: fuse.S_IFREG})
+	return ch, nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *callerNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	n.record(ctx, name)
+	return n.NewInode(ctx, &Inode{}, StableAttr{Mode: fuse.S_IFDIR}), 0
+}
+
+func (n *callerNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	n.record(ctx, name)
+	return n.NewInode(ctx, &MemSymlink{Data: []byte(target)}, StableAttr{Mode: fuse.S_IFLNK}), 0
+}
+
+func TestCallerExtensions(t *testing.T) {
+	root := &callerNode{secctx: map[string]bool{}}
+	opts := &Options{}
+	opts.EnableSecurityContext = true
+	opts.EnableCreateSupplementaryGroup = true
+	mnt, server := testMount(t, root, opts)
+	if server.KernelSettings().Flags64()&fuse.CAP_SECURITY_CTX == 0 {
+		t.Skip("kernel does not support CAP_SECURITY_CTX")
+	}
+
+	f, err := os.Create(filepath.Join(mnt, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := os.Mkdir(filepath.Join(mnt, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target", filepath.Join(mnt, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.Readlink(filepath.Join(mnt, "link")); err != nil || got != "target" {
+		t.Errorf("Readlink: %q, %v", got, err)
+	}
+
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	// The extensions must not end up in the names.
+	if want := []string{"file", "dir", "link"}; len(root.names) != len(want) ||
+		root.names[0] != want[0] || root.names[1] != want[1] || root.names[2] != want[2] {
+		t.Errorf("got names %q, want %q", root.names, want)
+	}
+	for name, ok := range root.secctx {
+		if !ok {
+			t.Errorf("%s: no security context", name)
+		}
+	}
+
+	if len(root.grpErrs) > 0 {
+		t.Fatalf("GroupsFromContext: %v", root.grpErrs)
+	}
+	want, err := os.Getgroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, g := range root.groups {
+		got = append(got, int(g))
+	}
+	sort.Ints(got)
+	sort.Ints(want)
+	if len(got) != len(want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+	for i := range want {
+		if i < len(got) && got[i] != want[i] {
+			t.Errorf("got groups %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestLoopbackSetgidDirectory(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("must run test as root")
+	}
+	orig := t.TempDir()
+	dir := filepath.Join(orig, "dir")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	const gid = 4321
+	if err := os.Chown(dir, 0, gid); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnt, _ := testMount(t, root, nil)
+	if err := os.WriteFile(filepath.Join(mnt, "dir/file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(filepath.Join(dir, "file"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Gid != gid {
+		t.Errorf("got gid %d, want the gid %d of the set-group-ID directory", st.Gid, gid)
+	}
+}
+
+func TestLoopbackSetgidMember(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("must run test as root")
+	}
+	dir := t.TempDir()
+	const gid = 4321
+	if err := os.Chown(dir, 0, gid); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewLoopbackRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMount(t, root, nil)
+	ln := root.(*LoopbackNode)
+
+	for _, tc := range []struct {
+		name   string
+		gid    uint32
+		setgid bool
+	}{
+		{"member", gid, true},
+		{"other", 1234, false},
+	} {
+		p := filepath.Join(dir, tc.name)
+		if err := os.WriteFile(p, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, 0775|os.ModeSetgid); err != nil {
+			t.Fatal(err)
+		}
+		ctx := &fuse.Context{Caller: fuse.Caller{Owner: fuse.Owner{Uid: 1234, Gid: tc.gid}}}
+		if err := ln.preserveOwner(ctx, p); err != nil {
+			t.Fatalf("%s: preserveOwner: %v", tc.name, err)
+		}
+		var st syscall.Stat_t
+		if err := syscall.Stat(p, &st); err != nil {
+			t.Fatal(err)
+		}
+		if st.Uid != 1234 || st.Gid != gid {
+			t.Errorf("%s: got owner %d:%d, want 1234:%d", tc.name, st.Uid, st.Gid, gid)
+		}
+		if got := st.Mode&syscall.S_ISGID != 0; got != tc.setgid {
+			t.Errorf("%s: got mode %o, want set-group-ID %v", tc.name, st.Mode, tc.setgid)
+		}
+		if st.Mode&0777 != 0775 {
+			t.Errorf("%s: got mode %o, want permissions 0775", tc.name, st.Mode)
+		}
+	}
+}
@@ -29,5 +29,9 @@ const _SEEK_DATA = 3
 // seek to the next hole
 const _SEEK_HOLE = 4
 
+// readiness of files that do not support polling:
+// POLLIN|POLLOUT|POLLRDNORM|POLLWRNORM, as DEFAULT_POLLMASK in Linux.
+const _DEFAULT_POLLMASK = 0x145
+
 // ENOATTR indicates that an extended attribute was not present.
 const ENOATTR = xattr.ENOATTR
@@ -0,0 +1,144 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// fuzzRecording returns a recording of an INIT request followed by
+// data.
+func fuzzRecording(data []byte) []fuse.TrafficRecord {
+	in := fuse.InitIn{
+		InHeader: fuse.InHeader{
+			Length: uint32(unsafe.Sizeof(fuse.InitIn{})),
+			Opcode: fuse.FUSE_INIT,
+			Unique: 1,
+		},
+		Major:        7,
+		Minor:        40,
+		MaxReadAhead: 1 << 20,
+		Flags:        ^uint32(0),
+		Flags2:       ^uint32(0),
+	}
+	initReq := unsafe.Slice((*byte)(unsafe.Pointer(&in)), unsafe.Sizeof(in))
+	return []fuse.TrafficRecord{
+		{Kind: fuse.RecordRequest, Data: initReq},
+		{Kind: fuse.RecordRequest, Data: data},
+	}
+}
+
+// fuzzHeaderSize is the size of the fuse.InHeader that starts each
+// request.
+const fuzzHeaderSize = int(unsafe.Sizeof(fuse.InHeader{}))
+
+// fuzzRequest returns a request for opcode op on the root, with
+// an input struct of size bytes followed by the payload.
+func fuzzRequest(op uint32, size int, payload string) []byte {
+	buf := make([]byte, fuzzHeaderSize+size, fuzzHeaderSize+size+len(payload))
+	buf = append(buf, payload...)
+	binary.LittleEndian.PutUint32(buf[unsafe.Offsetof(fuse.InHeader{}.Length):], uint32(len(buf)))
+	binary.LittleEndian.PutUint32(buf[unsafe.Offsetof(fuse.InHeader{}.Opcode):], op)
+	binary.LittleEndian.PutUint64(buf[unsafe.Offsetof(fuse.InHeader{}.Unique):], 2)
+	binary.LittleEndian.PutUint64(buf[unsafe.Offsetof(fuse.InHeader{}.NodeId):], fuse.FUSE_ROOT_ID)
+	return buf
+}
+
+// fuzzNodeIDs holds the offsets of node IDs in the input structs,
+// per opcode.
+var fuzzNodeIDs = map[uint32][]uintptr{
+	fuse.FUSE_RENAME:             {unsafe.Offsetof(fuse.Rename1In{}.Newdir)},
+	fuse.FUSE_LINK:               {unsafe.Offsetof(fuse.LinkIn{}.Oldnodeid)},
+	fuse.FUSE_RENAME2:            {unsafe.Offsetof(fuse.RenameIn{}.Newdir)},
+	fuse.FUSE_COPY_FILE_RANGE:    {unsafe.Offsetof(fuse.CopyFileRangeIn{}.NodeIdOut)},
+	fuse.FUSE_COPY_FILE_RANGE_64: {unsafe.Offsetof(fuse.CopyFileRangeIn{}.NodeIdOut)},
+}
+
+// fuzzHandles holds the offsets of file handles in the input
+// structs, per opcode.
+var fuzzHandles = map[uint32][]uintptr{
+	fuse.FUSE_GETATTR:     {unsafe.Offsetof(fuse.GetAttrIn{}.Fh_)},
+	fuse.FUSE_SETATTR:     {unsafe.Offsetof(fuse.SetAttrIn{}.Fh)},
+	fuse.FUSE_READ:        {unsafe.Offsetof(fuse.ReadIn{}.Fh)},
+	fuse.FUSE_WRITE:       {unsafe.Offsetof(fuse.WriteIn{}.Fh)},
+	fuse.FUSE_RELEASE:     {unsafe.Offsetof(fuse.ReleaseIn{}.Fh)},
+	fuse.FUSE_FSYNC:       {unsafe.Offsetof(fuse.FsyncIn{}.Fh)},
+	fuse.FUSE_FLUSH:       {unsafe.Offsetof(fuse.FlushIn{}.Fh)},
+	fuse.FUSE_READDIR:     {unsafe.Offsetof(fuse.ReadIn{}.Fh)},
+	fuse.FUSE_RELEASEDIR:  {unsafe.Offsetof(fuse.ReleaseIn{}.Fh)},
+	fuse.FUSE_FSYNCDIR:    {unsafe.Offsetof(fuse.FsyncIn{}.Fh)},
+	fuse.FUSE_GETLK:       {unsafe.Offsetof(fuse.LkIn{}.Fh)},
+	fuse.FUSE_SETLK:       {unsafe.Offsetof(fuse.LkIn{}.Fh)},
+	fuse.FUSE_SETLKW:      {unsafe.Offsetof(fuse.LkIn{}.Fh)},
+	fuse.FUSE_IOCTL:       {unsafe.Offsetof(fuse.IoctlIn{}.Fh)},
+	fuse.FUSE_POLL:        {unsafe.Offsetof(fuse.PollIn{}.Fh)},
+	fuse.FUSE_FALLOCATE:   {unsafe.Offsetof(fuse.FallocateIn{}.Fh)},
+	fuse.FUSE_READDIRPLUS: {unsafe.Offsetof(fuse.ReadIn{}.Fh)},
+	fuse.FUSE_LSEEK:       {unsafe.Offsetof(fuse.LseekIn{}.Fh)},
+	fuse.FUSE_COPY_FILE_RANGE: {
+		unsafe.Offsetof(fuse.CopyFileRangeIn{}.FhIn),
+		unsafe.Offsetof(fuse.CopyFileRangeIn{}.FhOut),
+	},
+	fuse.FUSE_STATX: {unsafe.Offsetof(fuse.StatxIn{}.Fh)},
+	fuse.FUSE_COPY_FILE_RANGE_64: {
+		unsafe.Offsetof(fuse.CopyFileRangeIn{}.FhIn),
+		unsafe.Offsetof(fuse.CopyFileRangeIn{}.FhOut),
+	},
+}
+
+// FuzzLoopback replays mutated requests against a loopback file
+// system. The bridge trusts the kernel to only use node IDs and file
+// handles it handed out, and to only forget lookups it did, so the
+// harness addresses all requests to the root node, clears file
+// handles, and skips FORGET and BATCH_FORGET.
+func FuzzLoopback(f *testing.F) {
+	for op := uint32(fuse.FUSE_LOOKUP); op <= fuse.FUSE_COPY_FILE_RANGE_64; op++ {
+		f.Add(fuzzRequest(op, 0, ""))
+		f.Add(fuzzRequest(op, 64, "file\x00file2\x00"))
+		f.Add(fuzzRequest(op, 64, "dir\x00"))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < fuzzHeaderSize {
+			return
+		}
+		set := func(offsets []uintptr, v uint64) {
+			for _, off := range offsets {
+				if int(off)+8 <= len(data) {
+					binary.LittleEndian.PutUint64(data[off:], v)
+				}
+			}
+		}
+		op := binary.LittleEndian.Uint32(data[unsafe.Offsetof(fuse.InHeader{}.Opcode):])
+		if op == fuse.FUSE_FORGET || op == fuse.FUSE_BATCH_FORGET {
+			return
+		}
+		set([]uintptr{unsafe.Offsetof(fuse.InHeader{}.NodeId)}, fuse.FUSE_ROOT_ID)
+		set(fuzzNodeIDs[op], fuse.FUSE_ROOT_ID)
+		set(fuzzHandles[op], 0)
+
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "file"), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		root, err := NewLoopbackRoot(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := &Options{}
+		opts.Logger = log.New(io.Discard, "", 0)
+		fuse.Replay(fuzzRecording(data), NewNodeFS(root, opts), &opts.MountOptions)
+	})
+}
@@ -0,0 +1,360 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// The bridge implements fuse.StateHandoffer, so the node IDs that the
+// kernel holds stay valid when the mount is passed to a new process
+// with fuse.Server.Handoff.
+//
+// Nodes are exported with their path from the root. On import, the
+// paths are looked up again in the new tree, and the nodes found get
+// their old node IDs, if they have the same StableAttr. Nodes that
+// cannot be found, for example because they were unlinked, are
+// replaced by placeholders that fail with ESTALE. Open files are
+// opened again with their original flags, and keep their handle
+// numbers; directories continue reading where they were. If an open
+// file cannot be opened again, for example because it was unlinked,
+// the import fails, and the original server keeps serving.
+
+// handoffNode is the exported state of a node the kernel knows.
+type handoffNode struct {
+	NodeID  uint64
+	Path    string
+	Orphan  bool `json:",omitempty"`
+	Attr    StableAttr
+	Lookups uint64
+	Files   []handoffFile `json:",omitempty"`
+
+	// BackingID is the passthrough backing ID, and BackingRefs the
+	// number of open files that use it.
+	BackingID   int32 `json:",omitempty"`
+	BackingRefs int   `json:",omitempty"`
+}
+
+// handoffFile is an open file of a handoffNode.
+type handoffFile struct {
+	Fh    uint32
+	Flags uint32
+
+	// DirOffset is the position of a directory stream.
+	DirOffset uint64 `json:",omitempty"`
+}
+
+// handoffState is the exported state of the bridge.
+type handoffState struct {
+	NextNodeID uint64
+	Nodes      []handoffNode
+}
+
+var _ = (fuse.StateHandoffer)((*rawBridge)(nil))
+
+// ExportState implements fuse.StateHandoffer.
+func (b *rawBridge) ExportState() ([]byte, error) {
+	b.mu.Lock()
+	state := handoffState{NextNodeID: b.nextNodeId}
+	nodes := make([]*Inode, 0, len(b.kernelNodeIds))
+	for _, n := range b.kernelNodeIds {
+		nodes = append(nodes, n)
+	}
+	var files [][]*fileEntry
+	for _, n := range nodes {
+		state.Nodes = append(state.Nodes, handoffNode{
+			NodeID:      n.nodeId,
+			Attr:        n.stableAttr,
+			BackingID:   n.backingID,
+			BackingRefs: n.backingIDRefcount,
+		})
+		var fes []*fileEntry
+		for _, fh := range n.openFiles {
+			fes = append(fes, b.files[fh])
+		}
+		files = append(files, fes)
+	}
+	b.mu.Unlock()
+
+	for i, n := range nodes {
+		e := &state.Nodes[i]
+		for _, fe := range files[i] {
+			fe.mu.Lock()
+			e.Files = append(e.Files, handoffFile{
+				Fh:        fe.fh,
+				Flags:     fe.openFlags,
+				DirOffset: fe.dirOffset,
+			})
+			fe.mu.Unlock()
+		}
+		var ok bool
+		e.Path, ok = n.handoffPath()
+		e.Orphan = !ok
+		n.mu.Lock()
+		e.Lookups = n.lookupCount
+		n.mu.Unlock()
+	}
+	return json.Marshal(&state)
+}
+
+// handoffPath returns the path of n relative to the root, and false
+// if n is not connected to the root.
+func (n *Inode) handoffPath() (string, bool) {
+	var segments []string
+	p := n
+	for p != n.bridge.root {
+		p.mu.Lock()
+		pd := p.parents.get()
+		p.mu.Unlock()
+		if pd == nil {
+			return "", false
+		}
+		segments = append(segments, pd.name)
+		p = pd.parent
+	}
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// ImportState implements fuse.StateHandoffer. It must be called
+// before the file system serves any request.
+func (b *rawBridge) ImportState(data []byte) (err error) {
+	var state handoffState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	if len(b.kernelNodeIds) > 1 {
+		b.mu.Unlock()
+		return errors.New("ImportState: file system is already in use")
+	}
+	if state.NextNodeID > b.nextNodeId {
+		b.nextNodeId = state.NextNodeID
+	}
+	b.mu.Unlock()
+
+	// Nodes created before the import, for example in OnAdd, may
+	// have been given node IDs that the kernel uses for other
+	// nodes. Give them fresh ones.
+	b.renumber(b.root, map[*Inode]bool{})
+
+	// Parents must be resolved before their children.
+	sort.Slice(state.Nodes, func(i, j int) bool {
+		return strings.Count(state.Nodes[i].Path, "/") < strings.Count(state.Nodes[j].Path, "/")
+	})
+
+	ctx := context.Background()
+	imported := map[*Inode]bool{}
+	var reopened []FileHandle
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, f := range reopened {
+			if r, ok := f.(FileReleaser); ok {
+				r.Release(ctx)
+			} else if r, ok := f.(FileReleasedirer); ok {
+				r.Releasedir(ctx, 0)
+			}
+		}
+	}()
+	for _, e := range state.Nodes {
+		var n *Inode
+		if e.NodeID == 1 {
+			n = b.root
+		} else if !e.Orphan {
+			n = b.resolvePath(ctx, e.Path)
+		}
+		if n == nil || n.stableAttr != e.Attr || imported[n] {
+			if len(e.Files) > 0 {
+				return fmt.Errorf("ImportState: %q has open files, but cannot be found", e.Path)
+			}
+			n = b.newStaleInode(e.Attr)
+		}
+		imported[n] = true
+
+		var fes []*fileEntry
+		for _, hf := range e.Files {
+			f, errno := b.reopen(ctx, n, hf)
+			if errno != 0 {
+				return fmt.Errorf("ImportState: open %q: %w", e.Path, errno)
+			}
+			reopened = append(reopened, f)
+			fes = append(fes, &fileEntry{
+				file:      f,
+				fh:        hf.Fh,
+				openFlags: hf.Flags,
+				dirOffset: hf.DirOffset,
+			})
+		}
+
+		n.mu.Lock()
+		b.mu.Lock()
+		if n != b.root {
+			n.nodeId = e.NodeID
+			n.lookupCount = e.Lookups
+			n.backingID = e.BackingID
+			n.backingIDRefcount = e.BackingRefs
+			if _, ok := n.ops.(*staleNode); !ok {
+				b.stableAttrs[n.stableAttr] = n
+			}
+		}
+		b.kernelNodeIds[e.NodeID] = n
+		for _, fe := range fes {
+			for uint32(len(b.files)) <= fe.fh {
+				b.files = append(b.files, nil)
+			}
+			if _, ok := fe.file.(FileReaddirenter); ok {
+				fe.lastRead = make([]fuse.DirEntry, 0, 100)
+			}
+			fe.nodeIndex = len(n.openFiles)
+			b.files[fe.fh] = fe
+			n.openFiles = append(n.openFiles, fe.fh)
+		}
+		b.mu.Unlock()
+		n.mu.Unlock()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nodeCountHigh = len(b.kernelNodeIds)
+	for fh := uint32(1); fh < uint32(len(b.files)); fh++ {
+		if b.files[fh] == nil {
+			b.files[fh] = &fileEntry{}
+			b.freeFiles = append(b.freeFiles, fh)
+		}
+	}
+	return nil
+}
+
+// renumber gives the nodes in the tree below n fresh node IDs.
+func (b *rawBridge) renumber(n *Inode, seen map[*Inode]bool) {
+	for _, ch := range n.Children() {
+		if seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		ch.mu.Lock()
+		b.mu.Lock()
+		ch.nodeId = b.nextNodeId
+		b.nextNodeId++
+		b.mu.Unlock()
+		ch.mu.Unlock()
+		b.renumber(ch, seen)
+	}
+}
+
+// resolvePath finds the node for path, looking up the nodes that are
+// not in the tree yet. It returns nil if path cannot be found.
+func (b *rawBridge) resolvePath(ctx context.Context, path string) *Inode {
+	n := b.root
+	for _, name := range strings.Split(path, "/") {
+		ch := n.GetChild(name)
+		if ch == nil {
+			var out fuse.EntryOut
+			var errno syscall.Errno
+			ch, errno = b.lookup(ctx, n, name, &out)
+			if errno != 0 || ch == nil {
+				return nil
+			}
+			// Hard links resolve to the node that was
+			// imported before.
+			b.mu.Lock()
+			if old := b.stableAttrs[ch.stableAttr]; old != nil {
+				ch = old
+			}
+			b.mu.Unlock()
+			if !n.AddChild(name, ch, false) {
+				return nil
+			}
+		}
+		n = ch
+	}
+	return n
+}
+
+// newStaleInode returns a placeholder for a node that could not be
+// found again after a handoff.
+func (b *rawBridge) newStaleInode(attr StableAttr) *Inode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ops := &staleNode{}
+	// Use a fresh inode number, so forgetting the placeholder does
+	// not affect a live node with the old StableAttr.
+	attr.Ino = b.automaticIno
+	b.automaticIno++
+	initInode(&ops.Inode, ops, attr, b, false, 0)
+	return &ops.Inode
+}
+
+// staleNode replaces nodes that are gone after a handoff.
+type staleNode struct {
+	Inode
+}
+
+var _ = (NodeGetattrer)((*staleNode)(nil))
+
+func (n *staleNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return syscall.ESTALE
+}
+
+// reopen opens a file again that was open on n before a handoff.
+func (b *rawBridge) reopen(ctx context.Context, n *Inode, hf handoffFile) (FileHandle, syscall.Errno) {
+	if n.IsDir() {
+		f, _, errno := b.openDir(ctx, n, hf.Flags)
+		if errno != 0 || hf.DirOffset == 0 {
+			return f, errno
+		}
+		return f, seekDir(ctx, f, hf.DirOffset)
+	}
+	op, ok := n.ops.(NodeOpener)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+	// The file exists now, and must not be truncated again.
+	f, _, errno := op.Open(ctx, hf.Flags&^(syscall.O_CREAT|syscall.O_EXCL|syscall.O_TRUNC))
+	return f, errno
+}
+
+// seekDir positions the directory stream f at off. Streams that
+// cannot seek are read up to off, numbering the entries like
+// readDirMaybeLookup does.
+func seekDir(ctx context.Context, f FileHandle, off uint64) syscall.Errno {
+	if sd, ok := f.(FileSeekdirer); ok {
+		if errno := sd.Seekdir(ctx, off); errno != syscall.ENOTSUP {
+			return errno
+		}
+	}
+	rd, ok := f.(FileReaddirenter)
+	if !ok {
+		return 0
+	}
+	var last uint64
+	for last < off {
+		de, errno := rd.Readdirent(ctx)
+		if errno != 0 {
+			return errno
+		}
+		if de == nil {
+			break
+		}
+		if de.Off == 0 {
+			de.Off = last + 1
+		}
+		last = de.Off
+	}
+	return 0
+}
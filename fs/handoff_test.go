@@ -0,0 +1,211 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func unixSocketPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns [2]*net.UnixConn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = c.(*net.UnixConn)
+		t.Cleanup(func() { c.Close() })
+	}
+	return conns[0], conns[1]
+}
+
+func TestHandoff(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("handoff is only supported on Linux")
+	}
+	orig := t.TempDir()
+	if err := os.Mkdir(filepath.Join(orig, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(orig, "dir/file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Long timeouts, so the kernel uses the node IDs it got before
+	// the handoff rather than looking up the files again.
+	timeout := time.Hour
+	newOptions := func() *Options {
+		return &Options{EntryTimeout: &timeout, AttrTimeout: &timeout}
+	}
+	root1, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnt, server1 := testMount(t, root1, newOptions())
+
+	dir, err := os.Open(filepath.Join(mnt, "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	f, err := os.Open(filepath.Join(mnt, "dir/file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := os.OpenFile(filepath.Join(mnt, "dir/file"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	c1, c2 := unixSocketPair(t)
+	// Handoff returns once the successor has resumed.
+	handoffErr := make(chan error, 1)
+	go func() {
+		handoffErr <- server1.Handoff(c1)
+	}()
+
+	root2, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := newOptions()
+	server2, err := fuse.Resume(c2, NewNodeFS(root2, opts), &opts.MountOptions)
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if err := <-handoffErr; err != nil {
+		t.Fatalf("Handoff: %v", err)
+	}
+	server1.Wait()
+	go server2.Serve()
+	t.Cleanup(func() {
+		if err := server2.Unmount(); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+	})
+
+	if data, err := os.ReadFile(filepath.Join(mnt, "dir/file")); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile after handoff: %q, %v", data, err)
+	}
+	if fi, err := dir.Stat(); err != nil || !fi.IsDir() {
+		t.Errorf("Stat on directory opened before handoff: %v, %v", fi, err)
+	}
+	if names, err := dir.Readdirnames(-1); err != nil || len(names) != 1 || names[0] != "file" {
+		t.Errorf("Readdirnames on directory opened before handoff: %v, %v", names, err)
+	}
+	buf := make([]byte, 10)
+	if n, err := f.ReadAt(buf, 0); string(buf[:n]) != "hello" {
+		t.Errorf("ReadAt on file opened before handoff: %q, %v", buf[:n], err)
+	}
+	if _, err := w.WriteAt([]byte("HE"), 0); err != nil {
+		t.Errorf("WriteAt on file opened before handoff: %v", err)
+	}
+	if err := w.Sync(); err != nil {
+		t.Errorf("Sync on file opened before handoff: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(orig, "dir/file")); err != nil || string(data) != "HEllo" {
+		t.Errorf("ReadFile of backing file: %q, %v", data, err)
+	}
+	if err := os.WriteFile(filepath.Join(mnt, "dir/new"), []byte("new"), 0644); err != nil {
+		t.Errorf("WriteFile after handoff: %v", err)
+	}
+	if err := server1.Unmount(); err != nil {
+		t.Errorf("Unmount of handed off server: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mnt, "dir/new")); err != nil {
+		t.Errorf("Stat after Unmount of handed off server: %v", err)
+	}
+}
+
+func TestHandoffFailure(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("handoff is only supported on Linux")
+	}
+	orig := t.TempDir()
+	if err := os.WriteFile(filepath.Join(orig, "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root1, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnt, server1 := testMount(t, root1, nil)
+
+	f, err := os.Open(filepath.Join(mnt, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A successor that goes away.
+	c1, c2 := unixSocketPair(t)
+	c2.Close()
+	if err := server1.Handoff(c1); err == nil {
+		t.Fatal("Handoff to closed socket succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(mnt, "file")); err != nil {
+		t.Errorf("Stat after failed handoff: %v", err)
+	}
+
+	// A successor that cannot open the file again.
+	if err := os.Remove(filepath.Join(orig, "file")); err != nil {
+		t.Fatal(err)
+	}
+	c1, c2 = unixSocketPair(t)
+	done := make(chan error, 1)
+	go func() {
+		root2, err := NewLoopbackRoot(orig)
+		if err != nil {
+			done <- err
+			return
+		}
+		_, err = fuse.Resume(c2, NewNodeFS(root2, nil), nil)
+		done <- err
+	}()
+	if err := server1.Handoff(c1); err == nil {
+		t.Fatal("Handoff with unlinked open file succeeded")
+	}
+	if err := <-done; err == nil {
+		t.Fatal("Resume with unlinked open file succeeded")
+	}
+	buf := make([]byte, 10)
+	if n, err := f.ReadAt(buf, 0); string(buf[:n]) != "hello" {
+		t.Errorf("ReadAt after failed handoff: %q, %v", buf[:n], err)
+	}
+}
+
+// TestHandoffWakeName checks that the name Handoff uses to wake up
+// readers reaches the file system when no handoff is in progress.
+func TestHandoffWakeName(t *testing.T) {
+	orig := t.TempDir()
+	name := ".go-fuse-handoff-wakeup"
+	if err := os.WriteFile(filepath.Join(orig, name), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnt, _ := testMount(t, root, nil)
+	if _, err := os.Stat(filepath.Join(mnt, name)); err != nil {
+		t.Errorf("Stat: %v", err)
+	}
+}
@@ -737,6 +737,19 @@ func (n *Inode) NotifyEntry(name string) syscall.Errno {
 	return syscall.Errno(status)
 }
 
+// NotifyEntryExpire marks the (directory, name) tuple as expired, so
+// the next access will start a LOOKUP operation. Unlike NotifyEntry,
+// the kernel keeps the entry if it is in use, eg. as a mount point or
+// working directory. Returns ENOSYS if the kernel does not support
+// this.
+func (n *Inode) NotifyEntryExpire(name string) syscall.Errno {
+	ec, ok := n.bridge.server.(serverEntryExpireCallbacks)
+	if !ok {
+		return syscall.ENOSYS
+	}
+	return syscall.Errno(ec.EntryNotifyExpire(n.nodeId, name))
+}
+
 // NotifyPrune instructs the kernel to forget the inodes passed as
 // argument. The kernel will issue FORGET requests as far as possible
 // in response.  If the receiver Inode must be forgotten too it must
@@ -753,6 +766,16 @@ func (n *Inode) NotifyPrune(nodes []*Inode) syscall.Errno {
 	return syscall.Errno(status)
 }
 
+// NotifyPoll wakes up poll(2) callers waiting on the kernel handle
+// `kh`, as passed to NodePoller or FilePoller.
+func (n *Inode) NotifyPoll(kh uint64) syscall.Errno {
+	pc, ok := n.bridge.server.(serverPollCallbacks)
+	if !ok {
+		return syscall.ENOSYS
+	}
+	return syscall.Errno(pc.PollNotify(kh))
+}
+
 // NotifyDelete notifies the kernel that the given inode was removed
 // from this directory as entry under the given name. It is equivalent
 // to NotifyEntry, but also sends an event to inotify watchers.
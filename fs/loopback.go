@@ -6,6 +6,7 @@ package fs
 
 import (
 	"context"
+	"math"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -142,8 +143,40 @@ func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryO
 }
 
 // preserveOwner sets uid and gid of `path` according to the caller information
-// in `ctx`.
+// in `ctx`. Like the kernel, it leaves files in a set-group-ID
+// directory with the group of the directory, and clears their
+// set-group-ID bit unless the caller is a member of that group.
 func (n *LoopbackNode) preserveOwner(ctx context.Context, path string) error {
+	return n.setOwner(ctx, pathOwnerTarget(path))
+}
+
+// preserveOwnerFd is like preserveOwner, for the file open as fd.
+func (n *LoopbackNode) preserveOwnerFd(ctx context.Context, fd int) error {
+	return n.setOwner(ctx, fdOwnerTarget(fd))
+}
+
+// ownerTarget is a new file that gets the owner of the caller.
+type ownerTarget interface {
+	stat(st *syscall.Stat_t) error
+	chown(uid, gid int) error
+	chmod(mode uint32) error
+}
+
+// pathOwnerTarget is a file by path. Symlinks are not followed.
+type pathOwnerTarget string
+
+func (p pathOwnerTarget) stat(st *syscall.Stat_t) error { return syscall.Lstat(string(p), st) }
+func (p pathOwnerTarget) chown(uid, gid int) error      { return syscall.Lchown(string(p), uid, gid) }
+func (p pathOwnerTarget) chmod(mode uint32) error       { return syscall.Chmod(string(p), mode) }
+
+// fdOwnerTarget is an open file.
+type fdOwnerTarget int
+
+func (fd fdOwnerTarget) stat(st *syscall.Stat_t) error { return syscall.Fstat(int(fd), st) }
+func (fd fdOwnerTarget) chown(uid, gid int) error      { return syscall.Fchown(int(fd), uid, gid) }
+func (fd fdOwnerTarget) chmod(mode uint32) error       { return syscall.Fchmod(int(fd), mode) }
+
+func (n *LoopbackNode) setOwner(ctx context.Context, f ownerTarget) error {
 	if os.Getuid() != 0 {
 		return nil
 	}
@@ -151,7 +184,59 @@ func (n *LoopbackNode) preserveOwner(ctx context.Context, path string) error {
 	if !ok {
 		return nil
 	}
-	return syscall.Lchown(path, int(caller.Uid), int(caller.Gid))
+	var dir syscall.Stat_t
+	if err := syscall.Stat(n.path(), &dir); err != nil || dir.Mode&syscall.S_ISGID == 0 {
+		return f.chown(int(caller.Uid), int(caller.Gid))
+	}
+
+	// Chown clears the set-user-ID and set-group-ID bits of
+	// executable files, so read the mode before and restore it after.
+	var st syscall.Stat_t
+	if err := f.stat(&st); err != nil {
+		return err
+	}
+	if err := f.chown(int(caller.Uid), -1); err != nil {
+		return err
+	}
+	if st.Mode&syscall.S_IFMT == syscall.S_IFLNK || st.Mode&(syscall.S_ISUID|syscall.S_ISGID) == 0 {
+		return nil
+	}
+	mode := st.Mode &^ syscall.S_IFMT
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR && caller.Uid != 0 && !callerInGroup(ctx, caller, st.Gid) {
+		mode &^= syscall.S_ISGID
+	}
+	return f.chmod(uint32(mode))
+}
+
+// callerInGroup returns whether gid is the group or one of the
+// supplementary groups of the caller.
+func callerInGroup(ctx context.Context, caller *fuse.Caller, gid uint32) bool {
+	if caller.Gid == gid {
+		return true
+	}
+	groups, err := fuse.GroupsFromContext(ctx)
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// setSecurityContext labels the new file at `path` with the security
+// contexts, for example SELinux labels, that the kernel sent for it.
+// See fuse.MountOptions.EnableSecurityContext.
+func setSecurityContext(ctx context.Context, path string) syscall.Errno {
+	scs, _ := fuse.SecurityContextFromContext(ctx)
+	for _, sc := range scs {
+		if err := unix.Lsetxattr(path, sc.Name, sc.Value, 0); err != nil {
+			return ToErrno(err)
+		}
+	}
+	return 0
 }
 
 var _ = (NodeMknoder)((*LoopbackNode)(nil))
@@ -162,6 +247,10 @@ func (n *LoopbackNode) Mknod(ctx context.Context, name string, mode, rdev uint32
 	if err != nil {
 		return nil, ToErrno(err)
 	}
+	if errno := setSecurityContext(ctx, p); errno != 0 {
+		syscall.Unlink(p)
+		return nil, errno
+	}
 	n.preserveOwner(ctx, p)
 	st := syscall.Stat_t{}
 	if err := syscall.Lstat(p, &st); err != nil {
@@ -185,6 +274,10 @@ func (n *LoopbackNode) Mkdir(ctx context.Context, name string, mode uint32, out
 	if err != nil {
 		return nil, ToErrno(err)
 	}
+	if errno := setSecurityContext(ctx, p); errno != 0 {
+		syscall.Rmdir(p)
+		return nil, errno
+	}
 	n.preserveOwner(ctx, p)
 	st := syscall.Stat_t{}
 	if err := syscall.Lstat(p, &st); err != nil {
@@ -244,11 +337,24 @@ var _ = (NodeCreater)((*LoopbackNode)(nil))
 func (n *LoopbackNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (inode *Inode, fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
 	p := filepath.Join(n.path(), name)
 	flags = flags &^ syscall.O_APPEND
-	fd, err := syscall.Open(p, int(flags)|os.O_CREATE, mode)
+	// Try O_EXCL first, so we know whether the file is ours to label
+	// and to remove again if labeling fails.
+	fd, err := syscall.Open(p, int(flags)|os.O_CREATE|os.O_EXCL, mode)
+	created := err == nil
+	if err == syscall.EEXIST && flags&syscall.O_EXCL == 0 {
+		fd, err = syscall.Open(p, int(flags)|os.O_CREATE, mode)
+	}
 	if err != nil {
 		return nil, nil, 0, ToErrno(err)
 	}
-	n.preserveOwner(ctx, p)
+	if created {
+		if errno := setSecurityContext(ctx, p); errno != 0 {
+			syscall.Close(fd)
+			syscall.Unlink(p)
+			return nil, nil, 0, errno
+		}
+		n.preserveOwner(ctx, p)
+	}
 	st := syscall.Stat_t{}
 	if err := syscall.Fstat(fd, &st); err != nil {
 		syscall.Close(fd)
@@ -304,6 +410,10 @@ func (n *LoopbackNode) Symlink(ctx context.Context, target, name string, out *fu
 	if err != nil {
 		return nil, ToErrno(err)
 	}
+	if errno := setSecurityContext(ctx, p); errno != 0 {
+		syscall.Unlink(p)
+		return nil, errno
+	}
 	n.preserveOwner(ctx, p)
 	st := syscall.Stat_t{}
 	if err := syscall.Lstat(p, &st); err != nil {
@@ -322,7 +432,13 @@ var _ = (NodeLinker)((*LoopbackNode)(nil))
 func (n *LoopbackNode) Link(ctx context.Context, target InodeEmbedder, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
 
 	p := filepath.Join(n.path(), name)
-	err := syscall.Link(filepath.Join(n.RootData.Path, target.EmbeddedInode().Path(nil)), p)
+	var err error
+	if _, parent := target.EmbeddedInode().Parent(); parent == nil && !target.EmbeddedInode().IsRoot() {
+		// Unnamed file, eg. from O_TMPFILE.
+		err = linkOpenFile(target.EmbeddedInode(), p)
+	} else {
+		err = syscall.Link(filepath.Join(n.RootData.Path, target.EmbeddedInode().Path(nil)), p)
+	}
 	if err != nil {
 		return nil, ToErrno(err)
 	}
@@ -514,6 +630,18 @@ var _ = (NodeCopyFileRanger)((*LoopbackNode)(nil))
 func (n *LoopbackNode) CopyFileRange(ctx context.Context, fhIn FileHandle,
 	offIn uint64, out *Inode, fhOut FileHandle, offOut uint64,
 	len uint64, flags uint64) (uint32, syscall.Errno) {
+	if len > math.MaxUint32 {
+		len = math.MaxUint32
+	}
+	sz, errno := n.CopyFileRange64(ctx, fhIn, offIn, out, fhOut, offOut, len, flags)
+	return uint32(sz), errno
+}
+
+var _ = (NodeCopyFileRanger64)((*LoopbackNode)(nil))
+
+func (n *LoopbackNode) CopyFileRange64(ctx context.Context, fhIn FileHandle,
+	offIn uint64, out *Inode, fhOut FileHandle, offOut uint64,
+	len uint64, flags uint64) (uint64, syscall.Errno) {
 	lfIn, ok := fhIn.(*LoopbackFile)
 	if !ok {
 		return 0, unix.ENOTSUP
@@ -524,8 +652,7 @@ func (n *LoopbackNode) CopyFileRange(ctx context.Context, fhIn FileHandle,
 	}
 	signedOffIn := int64(offIn)
 	signedOffOut := int64(offOut)
-	doCopyFileRange(lfIn.fd, signedOffIn, lfOut.fd, signedOffOut, int(len), int(flags))
-	return 0, syscall.ENOSYS
+	return doCopyFileRange(lfIn.fd, signedOffIn, lfOut.fd, signedOffOut, int(min(len, math.MaxInt)), int(flags))
 }
 
 // NewLoopbackRoot returns a root node for a loopback file system whose
@@ -27,10 +27,16 @@ func timeToTimeval(t *time.Time) syscall.Timeval {
 }
 
 func doCopyFileRange(fdIn int, offIn int64, fdOut int, offOut int64,
-	len int, flags int) (uint32, syscall.Errno) {
+	len int, flags int) (uint64, syscall.Errno) {
 	return 0, syscall.ENOSYS
 }
 
 func intDev(dev uint32) int {
 	return int(dev)
 }
+
+// linkOpenFile gives the unnamed file `target` the name `dest`. This
+// requires linkat(2) through /proc, which is Linux specific.
+func linkOpenFile(target *Inode, dest string) error {
+	return syscall.ENOTSUP
+}
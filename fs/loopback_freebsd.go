@@ -23,12 +23,12 @@ const sys_COPY_FILE_RANGE = 569
 // TODO: replace the manual syscall when sys/unix provides CopyFileRange
 // for FreeBSD
 func doCopyFileRange(fdIn int, offIn int64, fdOut int, offOut int64,
-	len int, flags int) (uint32, syscall.Errno) {
+	len int, flags int) (uint64, syscall.Errno) {
 	count, _, errno := unix.Syscall6(sys_COPY_FILE_RANGE,
 		uintptr(fdIn), uintptr(offIn), uintptr(fdOut), uintptr(offOut),
 		uintptr(len), uintptr(flags),
 	)
-	return uint32(count), errno
+	return uint64(count), errno
 }
 
 func intDev(dev uint32) uint64 {
@@ -78,3 +78,9 @@ func (n *LoopbackNode) Listxattr(ctx context.Context, dest []byte) (uint32, sysc
 	}
 	return uint32(sz), ToErrno(err)
 }
+
+// linkOpenFile gives the unnamed file `target` the name `dest`. This
+// requires linkat(2) through /proc, which is Linux specific.
+func linkOpenFile(target *Inode, dest string) error {
+	return syscall.ENOTSUP
+}
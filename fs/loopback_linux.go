@@ -9,6 +9,7 @@ package fs
 
 import (
 	"context"
+	"fmt"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -18,9 +19,9 @@ import (
 const unix_UTIME_OMIT = unix.UTIME_OMIT
 
 func doCopyFileRange(fdIn int, offIn int64, fdOut int, offOut int64,
-	len int, flags int) (uint32, syscall.Errno) {
+	len int, flags int) (uint64, syscall.Errno) {
 	count, err := unix.CopyFileRange(fdIn, &offIn, fdOut, &offOut, len, flags)
-	return uint32(count), ToErrno(err)
+	return uint64(count), ToErrno(err)
 }
 
 func intDev(dev uint32) int {
@@ -48,3 +49,66 @@ func (n *LoopbackNode) Statx(ctx context.Context, f FileHandle,
 	out.FromStatx(&st)
 	return OK
 }
+
+var _ = (NodeSyncfser)((*LoopbackNode)(nil))
+
+func (n *LoopbackNode) Syncfs(ctx context.Context) syscall.Errno {
+	fd, err := syscall.Open(n.RootData.Path, syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return ToErrno(err)
+	}
+	defer syscall.Close(fd)
+	return ToErrno(unix.Syncfs(fd))
+}
+
+var _ = (NodeTmpfiler)((*LoopbackNode)(nil))
+
+func (n *LoopbackNode) Tmpfile(ctx context.Context, flags uint32, mode uint32, out *fuse.EntryOut) (inode *Inode, fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	flags = flags &^ (syscall.O_APPEND | syscall.O_CREAT)
+	fd, err := syscall.Open(n.path(), int(flags)|unix.O_TMPFILE, mode)
+	if err != nil {
+		return nil, nil, 0, ToErrno(err)
+	}
+	scs, _ := fuse.SecurityContextFromContext(ctx)
+	for _, sc := range scs {
+		if err := unix.Fsetxattr(fd, sc.Name, sc.Value, 0); err != nil {
+			syscall.Close(fd)
+			return nil, nil, 0, ToErrno(err)
+		}
+	}
+	n.preserveOwnerFd(ctx, fd)
+	st := syscall.Stat_t{}
+	if err := syscall.Fstat(fd, &st); err != nil {
+		syscall.Close(fd)
+		return nil, nil, 0, ToErrno(err)
+	}
+
+	node := n.RootData.newNode(n.EmbeddedInode(), "", &st)
+	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	lf := NewLoopbackFile(fd)
+
+	out.FromStat(&st)
+	return ch, lf, 0, 0
+}
+
+// linkOpenFile gives the unnamed file `target` the name `dest`,
+// through one of its open file descriptors.
+func linkOpenFile(target *Inode, dest string) error {
+	for _, fh := range target.bridge.openFileHandles(target) {
+		lf, ok := fh.(*LoopbackFile)
+		if !ok {
+			continue
+		}
+		lf.mu.Lock()
+		var err error = syscall.EBADF
+		if lf.fd != -1 {
+			err = unix.Linkat(unix.AT_FDCWD, fmt.Sprintf("/proc/self/fd/%d", lf.fd),
+				unix.AT_FDCWD, dest, unix.AT_SYMLINK_FOLLOW)
+		}
+		lf.mu.Unlock()
+		if err != syscall.EBADF {
+			return err
+		}
+	}
+	return syscall.ENOENT
+}
@@ -6,9 +6,13 @@ package fs
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -432,3 +436,236 @@ func TestMknod(t *testing.T) {
 		})
 	}
 }
+
+type syncfsNode struct {
+	Inode
+
+	calls atomic.Int32
+}
+
+var _ = (NodeSyncfser)((*syncfsNode)(nil))
+
+func (n *syncfsNode) Syncfs(ctx context.Context) syscall.Errno {
+	n.calls.Add(1)
+	return 0
+}
+
+// serveSyncfs sends a SYNCFS request for the root, and returns the
+// status of the reply. The kernel only issues SYNCFS for virtiofs, so
+// the request is injected through ProtocolServer.
+func serveSyncfs(t *testing.T, root InodeEmbedder) fuse.Status {
+	rawFS := NewNodeFS(root, &Options{})
+	ps := fuse.NewProtocolServer(rawFS, &fuse.MountOptions{})
+
+	in := fuse.SyncFsIn{
+		InHeader: fuse.InHeader{
+			Length: uint32(unsafe.Sizeof(fuse.SyncFsIn{})),
+			Opcode: fuse.FUSE_SYNCFS,
+			Unique: 2,
+			NodeId: fuse.FUSE_ROOT_ID,
+		},
+	}
+	var outHeader fuse.OutHeader
+	if _, st := ps.HandleRequest([][]byte{asBytes(&in)}, [][]byte{asBytes(&outHeader)}); st != fuse.OK {
+		t.Fatalf("HandleRequest: %v", st)
+	}
+	return fuse.Status(-outHeader.Status)
+}
+
+// asBytes returns the memory of the struct pointed to by v. It
+// mirrors the helper of the fuse package tests, which this package
+// cannot import.
+func asBytes(v interface{}) []byte {
+	p := reflect.ValueOf(v)
+	return unsafe.Slice((*byte)(p.UnsafePointer()), p.Type().Elem().Size())
+}
+
+func TestSyncfs(t *testing.T) {
+	root := &syncfsNode{}
+	if st := serveSyncfs(t, root); st != fuse.OK {
+		t.Fatalf("SYNCFS: %v", st)
+	}
+	if got := root.calls.Load(); got != 1 {
+		t.Errorf("got %d Syncfs calls, want 1", got)
+	}
+
+	if st := serveSyncfs(t, &Inode{}); st != fuse.ENOSYS {
+		t.Errorf("SYNCFS without NodeSyncfser: got %v, want ENOSYS", st)
+	}
+}
+
+func TestSyncfsLoopback(t *testing.T) {
+	root, err := NewLoopbackRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := serveSyncfs(t, root); st != fuse.OK {
+		t.Fatalf("SYNCFS: %v", st)
+	}
+}
+
+func TestTmpfile(t *testing.T) {
+	tc := newTestCase(t, &testOptions{attrCache: true, entryCache: true})
+
+	fd, err := syscall.Open(tc.mntDir, unix.O_TMPFILE|syscall.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Open(O_TMPFILE): %v", err)
+	}
+	defer syscall.Close(fd)
+
+	want := []byte("hello")
+	if _, err := syscall.Write(fd, want); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	entries, err := os.ReadDir(tc.origDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("got entries %v before link", entries)
+	}
+
+	if err := unix.Linkat(unix.AT_FDCWD, fmt.Sprintf("/proc/self/fd/%d", fd),
+		unix.AT_FDCWD, tc.mntDir+"/file", unix.AT_SYMLINK_FOLLOW); err != nil {
+		t.Fatalf("Linkat: %v", err)
+	}
+
+	var fst, st syscall.Stat_t
+	if err := syscall.Fstat(fd, &fst); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Stat(tc.mntDir+"/file", &st); err != nil {
+		t.Fatal(err)
+	}
+	if fst.Ino != st.Ino {
+		t.Errorf("linked file has inode %d, want %d", st.Ino, fst.Ino)
+	}
+	if got, err := os.ReadFile(tc.origDir + "/file"); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+}
+
+// TestTmpfileOwner checks that an O_TMPFILE file gets the owner of
+// the caller, like other new files, including the set-group-ID rules.
+func TestTmpfileOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("must run test as root")
+	}
+	dir := t.TempDir()
+	const gid = 4321
+	if err := os.Chown(dir, 0, gid); err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewLoopbackRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMount(t, root, nil)
+	ln := root.(*LoopbackNode)
+
+	for _, tc := range []struct {
+		name      string
+		setgidDir bool
+		gid       uint32
+		wantGid   uint32
+		setgid    bool
+	}{
+		{"plain", false, 1234, 1234, false},
+		{"member", true, gid, gid, true},
+		{"other", true, 1234, gid, false},
+	} {
+		mode := os.FileMode(0777)
+		if tc.setgidDir {
+			mode |= os.ModeSetgid
+		}
+		if err := os.Chmod(dir, mode); err != nil {
+			t.Fatal(err)
+		}
+		ctx := &fuse.Context{Caller: fuse.Caller{Owner: fuse.Owner{Uid: 1234, Gid: tc.gid}}}
+		var out fuse.EntryOut
+		_, fh, _, errno := ln.Tmpfile(ctx, syscall.O_RDWR, 0775|syscall.S_ISGID, &out)
+		if errno != 0 {
+			t.Fatalf("%s: Tmpfile: %v", tc.name, errno)
+		}
+		var st syscall.Stat_t
+		err := syscall.Fstat(fh.(*LoopbackFile).fd, &st)
+		fh.(*LoopbackFile).Release(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Uid != 1234 || st.Gid != tc.wantGid {
+			t.Errorf("%s: got owner %d:%d, want 1234:%d", tc.name, st.Uid, st.Gid, tc.wantGid)
+		}
+		if out.Uid != st.Uid || out.Gid != st.Gid {
+			t.Errorf("%s: entry has owner %d:%d, want %d:%d", tc.name, out.Uid, out.Gid, st.Uid, st.Gid)
+		}
+		if got := st.Mode&syscall.S_ISGID != 0; tc.setgidDir && got != tc.setgid {
+			t.Errorf("%s: got mode %o, want set-group-ID %v", tc.name, st.Mode, tc.setgid)
+		}
+	}
+}
+
+func TestCopyFileRangeResult(t *testing.T) {
+	orig := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	if err := os.WriteFile(filepath.Join(orig, "src"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := fuse.NewStats()
+	opts := &Options{}
+	opts.Stats = stats
+	mnt, server := testMount(t, root, opts)
+	if !server.KernelSettings().SupportsVersion(7, 28) {
+		t.Skip("need v7.28 for CopyFileRange")
+	}
+
+	src, err := os.Open(filepath.Join(mnt, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(mnt, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// The file system must report the number of bytes it copied.
+	// If it fails with ENOSYS instead, the kernel falls back to a
+	// generic copy, and stops sending COPY_FILE_RANGE requests.
+	copyRequests := func() int64 {
+		// Stats counts a request after it is answered, so
+		// also include the ones in flight.
+		var n int64
+		for _, op := range []string{"COPY_FILE_RANGE", "COPY_FILE_RANGE_64"} {
+			st := stats.Snapshot().Ops[op]
+			n += int64(st.Count) + st.InFlight
+		}
+		return n
+	}
+	half := len(data) / 2
+	for i := 0; i < 2; i++ {
+		before := copyRequests()
+		offIn := int64(i * half)
+		offOut := offIn
+		n, err := unix.CopyFileRange(int(src.Fd()), &offIn, int(dst.Fd()), &offOut, half, 0)
+		if err != nil || n != half {
+			t.Fatalf("CopyFileRange %d: got %d, %v, want %d", i, n, err, half)
+		}
+		if got := copyRequests() - before; got != 1 {
+			t.Errorf("CopyFileRange %d: got %d requests, want 1", i, got)
+		}
+	}
+
+	got, err := os.ReadFile(filepath.Join(orig, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("copied data differs: got %d bytes, want %d", len(got), len(data))
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// pollNode is a file that becomes readable once ready is set.
+type pollNode struct {
+	Inode
+
+	mu    sync.Mutex
+	ready bool
+	kh    []uint64
+}
+
+var _ = (NodeOpener)((*pollNode)(nil))
+var _ = (NodePoller)((*pollNode)(nil))
+
+func (n *pollNode) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *pollNode) Poll(ctx context.Context, f FileHandle, kh uint64, flags uint32, events uint32) (uint32, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ready {
+		return events & unix.POLLIN, 0
+	}
+	if flags&fuse.FUSE_POLL_SCHEDULE_NOTIFY != 0 {
+		n.kh = append(n.kh, kh)
+	}
+	return 0, 0
+}
+
+func (n *pollNode) setReady() {
+	n.mu.Lock()
+	n.ready = true
+	kh := n.kh
+	n.kh = nil
+	n.mu.Unlock()
+
+	for _, k := range kh {
+		n.NotifyPoll(k)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	root := &Inode{}
+	node := &pollNode{}
+	opts := &Options{
+		OnAdd: func(ctx context.Context) {
+			root.AddChild("file",
+				root.NewPersistentInode(ctx, node, StableAttr{}), false)
+			root.AddChild("plain",
+				root.NewPersistentInode(ctx, &MemRegularFile{}, StableAttr{}), false)
+		},
+	}
+	opts.EnablePoll = true
+	mnt, _ := testMount(t, root, opts)
+
+	fd, err := syscall.Open(filepath.Join(mnt, "plain"), syscall.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN | unix.POLLOUT}}
+	if n, err := unix.Poll(fds, 0); err != nil || n != 1 {
+		t.Fatalf("Poll(plain): %d, %v", n, err)
+	}
+
+	fd, err = syscall.Open(filepath.Join(mnt, "file"), syscall.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	fds = []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	if n, err := unix.Poll(fds, 0); err != nil || n != 0 {
+		t.Fatalf("Poll before ready: %d, %v (revents 0x%x)", n, err, fds[0].Revents)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		node.setReady()
+	}()
+
+	n, err := unix.Poll(fds, 5000)
+	if err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if n != 1 || fds[0].Revents&unix.POLLIN == 0 {
+		t.Fatalf("Poll: got %d, revents 0x%x, want POLLIN", n, fds[0].Revents)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func recordTestOptions(root *Inode, data string) *Options {
+	opts := &Options{
+		FirstAutomaticIno: 1,
+		OnAdd: func(ctx context.Context) {
+			root.AddChild("file", root.NewPersistentInode(ctx, &MemRegularFile{
+				Data: []byte(data),
+			}, StableAttr{Ino: 2}), false)
+		},
+	}
+	opts.DisableXAttrs = true
+	return opts
+}
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	root := &Inode{}
+	opts := recordTestOptions(root, "hello")
+	opts.RecordTraffic = &buf
+
+	dir := t.TempDir()
+	server, err := Mount(dir, root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "file")); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if err := server.Unmount(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := fuse.ReadRecording(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecording: %v", err)
+	}
+	if len(recs) < 2 || recs[0].Kind != fuse.RecordRequest {
+		t.Fatalf("got %d records, want INIT request first", len(recs))
+	}
+
+	replay := func(data string) []fuse.ReplayDiff {
+		root := &Inode{}
+		opts := recordTestOptions(root, data)
+		return fuse.Replay(recs, NewNodeFS(root, opts), &opts.MountOptions)
+	}
+	if diffs := replay("hello"); len(diffs) > 0 {
+		for _, d := range diffs {
+			t.Errorf("replay: %s", &d)
+		}
+	}
+
+	diffs := replay("world")
+	var read bool
+	for _, d := range diffs {
+		if d.Op == "READ" {
+			read = true
+		}
+	}
+	if !read {
+		t.Errorf("got diffs %v, want READ diff", diffs)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestResendPending(t *testing.T) {
+	// The first read hangs; the resent one is served.
+	node := newBlockingNode()
+	node.blockOnce = true
+	defer close(node.release)
+	mnt, server := mountBlockingNode(t, node, nil)
+	if server.KernelSettings().Flags64()&fuse.CAP_HAS_RESEND == 0 {
+		t.Skip("kernel does not support NOTIFY_RESEND")
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := os.ReadFile(filepath.Join(mnt, "file"))
+		done <- result{data, err}
+	}()
+
+	<-node.started
+	if st := server.ResendPending(); !st.Ok() {
+		t.Fatalf("ResendPending: %v", st)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("ReadFile: %v", res.err)
+	}
+	if got, want := string(res.data), "hello"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
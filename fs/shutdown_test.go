@@ -0,0 +1,132 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrains(t *testing.T) {
+	node := newBlockingNode()
+	mnt, server := mountBlockingNode(t, node, nil)
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	f, err := os.Open(filepath.Join(mnt, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan result, 1)
+	go func() {
+		data := make([]byte, 10)
+		n, err := f.Read(data)
+		f.Close()
+		read <- result{data[:n], err}
+	}()
+	<-node.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(ctx) }()
+
+	// New requests are refused once Shutdown has started.
+	for {
+		_, err := os.Lstat(filepath.Join(mnt, "other"))
+		if errors.Is(err, syscall.ENOTCONN) {
+			break
+		}
+		if !errors.Is(err, syscall.ENOENT) {
+			t.Fatalf("Lstat: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned with a request in flight: %v", err)
+	default:
+	}
+	close(node.release)
+
+	if r := <-read; r.err != nil || string(r.data) != "hello" {
+		t.Errorf("Read: %q, %v", r.data, r.err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(mnt, "file")); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Lstat after Shutdown: got %v, want ENOENT", err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	node := newBlockingNode()
+	mnt, server := mountBlockingNode(t, node, nil)
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := os.ReadFile(filepath.Join(mnt, "file"))
+		read <- err
+	}()
+	<-node.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown: got %v, want DeadlineExceeded", err)
+	}
+	// The request in flight was interrupted. The Go runtime retries
+	// reads that fail with EINTR, and the retry is refused.
+	if err := <-read; !errors.Is(err, syscall.ENOTCONN) {
+		t.Errorf("ReadFile: got %v, want ENOTCONN", err)
+	}
+}
+
+// TestShutdownConcurrent checks that a second Shutdown does not keep
+// the first from seeing the requests drain.
+func TestShutdownConcurrent(t *testing.T) {
+	node := newBlockingNode()
+	mnt, server := mountBlockingNode(t, node, nil)
+
+	f, err := os.Open(filepath.Join(mnt, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan error, 1)
+	go func() {
+		_, err := f.Read(make([]byte, 10))
+		f.Close()
+		read <- err
+	}()
+	<-node.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { shutdown <- server.Shutdown(ctx) }()
+	}
+	// Wait until both have started draining.
+	time.Sleep(50 * time.Millisecond)
+	close(node.release)
+
+	if err := <-read; err != nil {
+		t.Errorf("Read: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-shutdown; err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	}
+}
@@ -63,6 +63,8 @@ type testOptions struct {
 	directMountStrict bool // sets MountOptions.DirectMountStrict
 	disableSplice     bool // sets MountOptions.DisableSplice
 	idMappedMount     bool // sets MountOptions.IDMappedMount
+	enableIOUring     bool // sets MountOptions.EnableIOUring
+	numQueues         int  // sets MountOptions.NumQueues
 }
 
 // newTestCase creates the directories `orig` and `mnt` inside a temporary
@@ -115,6 +117,8 @@ func newTestCase(t *testing.T, opts *testOptions) *testCase {
 		EnableLocks:       opts.enableLocks,
 		DisableSplice:     opts.disableSplice,
 		IDMappedMount:     opts.idMappedMount,
+		EnableIOUring:     opts.enableIOUring,
+		NumQueues:         opts.numQueues,
 	}
 	if !opts.suppressDebug {
 		mOpts.Debug = testutil.VerboseTest()
@@ -228,6 +232,35 @@ func TestNotifyEntry(t *testing.T) {
 	}
 }
 
+func TestNotifyEntryExpire(t *testing.T) {
+	tc := newTestCase(t, &testOptions{attrCache: true, entryCache: true})
+
+	orig := tc.origDir + "/file"
+	fn := tc.mntDir + "/file"
+	tc.writeOrig("file", "hello", 0644)
+
+	st := syscall.Stat_t{}
+	if err := syscall.Lstat(fn, &st); err != nil {
+		t.Fatalf("Lstat before: %v", err)
+	}
+
+	if err := os.Remove(orig); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	errno := tc.loopback.EmbeddedInode().NotifyEntryExpire("file")
+	if errno == syscall.ENOSYS {
+		t.Skip("kernel does not support FUSE_EXPIRE_ONLY")
+	} else if errno != 0 {
+		t.Fatalf("notify failed: %v", errno)
+	}
+
+	after := syscall.Stat_t{}
+	if err := syscall.Lstat(fn, &after); err != syscall.ENOENT {
+		t.Fatalf("Lstat after: got %v, want ENOENT", err)
+	}
+}
+
 type forgetNode struct {
 	Inode
 	forgetCalled uint32
@@ -817,3 +850,36 @@ func TestLChown(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestNumQueues(t *testing.T) {
+	tc := newTestCase(t, &testOptions{numQueues: 4})
+	if got, want := tc.server.DebugData(), "queues: 4"; !strings.Contains(got, want) {
+		t.Errorf("DebugData: got %q, want %q", got, want)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn := fmt.Sprintf("%s/file%d", tc.mntDir, i)
+			for j := 0; j < 50; j++ {
+				want := fmt.Sprintf("content %d", j)
+				if err := os.WriteFile(fn, []byte(want), 0644); err != nil {
+					t.Errorf("WriteFile: %v", err)
+					return
+				}
+				got, err := os.ReadFile(fn)
+				if err != nil {
+					t.Errorf("ReadFile: %v", err)
+					return
+				}
+				if string(got) != want {
+					t.Errorf("got %q, want %q", got, want)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
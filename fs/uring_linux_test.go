@@ -0,0 +1,56 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestIOUring(t *testing.T) {
+	tc := newTestCase(t, &testOptions{enableIOUring: true})
+	if tc.server.KernelSettings().Flags64()&fuse.CAP_OVER_IO_URING == 0 {
+		t.Skip("kernel does not offer FUSE-over-io_uring")
+	}
+
+	content := bytes.Repeat([]byte("abcdefgh"), 64*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn := fmt.Sprintf("%s/file%d", tc.mntDir, i)
+			for j := 0; j < 20; j++ {
+				if err := os.WriteFile(fn, content, 0644); err != nil {
+					t.Errorf("WriteFile: %v", err)
+					return
+				}
+				got, err := os.ReadFile(fn)
+				if err != nil {
+					t.Errorf("ReadFile: %v", err)
+					return
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("ReadFile: got %d bytes, want %d", len(got), len(content))
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(tc.mntDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("got %d entries, want 4", len(entries))
+	}
+}
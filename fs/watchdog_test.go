@@ -0,0 +1,32 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchdogFailRequests(t *testing.T) {
+	node := newBlockingNode()
+	opts := &Options{}
+	opts.WatchdogTimeout = 50 * time.Millisecond
+	opts.WatchdogFailRequests = true
+	mnt, _ := mountBlockingNode(t, node, opts)
+
+	_, err := os.ReadFile(filepath.Join(mnt, "file"))
+	if !errors.Is(err, syscall.EIO) {
+		t.Fatalf("got %v, want EIO", err)
+	}
+	select {
+	case <-node.interrupted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not interrupted")
+	}
+}
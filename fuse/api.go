@@ -87,7 +87,7 @@
 // The NewServer() handles mounting the filesystem, which
 // involves opening `/dev/fuse` and calling the
 // `mount(2)` syscall. The latter needs root permissions.
-// This is handled in one of three ways:
+// This is handled in one of four ways:
 //
 // 1) go-fuse opens `/dev/fuse` and executes the `fusermount`
 // setuid-root helper to call `mount(2)` for us. This is the default.
@@ -96,7 +96,12 @@
 // 2) If `MountOptions.DirectMount` is set, go-fuse calls `mount(2)` itself.
 // Needs root permissions, but works without `fusermount`.
 //
-// 3) If `mountPoint` has the magic `/dev/fd/N` syntax, it means that that a
+// 3) If `MountOptions.MountBroker` is set, go-fuse asks a privileged
+// mount broker (see package fuse/mountbroker) over a unix socket to
+// mount the filesystem, and receives the `/dev/fuse` file descriptor
+// from it. Needs neither root permissions nor `fusermount`.
+//
+// 4) If `mountPoint` has the magic `/dev/fd/N` syntax, it means that that a
 // privileged parent process:
 //
 // * Opened /dev/fuse
@@ -117,6 +122,11 @@
 //
 //	$ sudo mount.fuse3 "/usr/local/bin/gocryptfs#/tmp/cipher" /tmp/mnt -o drop_privileges,setuid=$USER
 //
+// Under systemd, the file descriptor can also be passed through
+// socket activation or the file descriptor store (see
+// sd_listen_fds(3)). Such a descriptor can be named as /dev/fd/NAME,
+// where NAME is its FileDescriptorName=. See ServeSystemd.
+//
 // [1] https://github.com/libfuse/libfuse/commit/64e11073b9347fcf9c6d1eea143763ba9e946f70
 //
 // [2] https://sylabs.io/guides/3.7/user-guide/bind_paths_and_mounts.html#fuse-mounts
@@ -126,13 +136,19 @@
 // A caller that has an open file in a buggy or crashed FUSE
 // filesystem will be hung. The easiest way to clean up this situation
 // is through the fusectl filesystem. By writing into
-// /sys/fs/fuse/connection/$ID/abort, reads from the FUSE device fail,
+// /sys/fs/fuse/connections/$ID/abort, reads from the FUSE device fail,
 // and all callers receive ENOTCONN (transport endpoint not connected)
 // on their pending syscalls.  The FUSE connection ID can be found as
 // the Dev field in the Stat_t result for a file in the mount.
+// Server.Connection does this for you, and also gives access to the
+// other fusectl files.
 package fuse
 
-import "log"
+import (
+	"io"
+	"log"
+	"time"
+)
 
 // Types for users to implement.
 
@@ -301,9 +317,13 @@ type MountOptions struct {
 	// for more details.
 	SyncRead bool
 
-	// DirectMount, if set, makes go-fuse first attempt to use syscall.Mount instead of
-	// fusermount to mount the filesystem. This will not update /etc/mtab
-	// but might be needed if fusermount is not available.
+	// DirectMount, if set, makes go-fuse first attempt to mount the
+	// filesystem itself instead of using fusermount. On Linux,
+	// this uses the new mount API (fsopen, fsconfig, fsmount and
+	// move_mount) if the kernel supports it, so errors name the
+	// rejected option, and syscall.Mount otherwise. This will not
+	// update /etc/mtab but might be needed if fusermount is not
+	// available.
 	// Also, Server.Unmount will attempt syscall.Unmount before calling
 	// fusermount.
 	DirectMount bool
@@ -313,11 +333,37 @@ type MountOptions struct {
 	// DirectMountStrict wins.
 	DirectMountStrict bool
 
+	// MountBroker, if set, is the path of the unix socket of a
+	// mount broker (see package fuse/mountbroker). The broker
+	// mounts and unmounts the file system instead of fusermount,
+	// so this needs neither root permissions nor setuid
+	// binaries. Linux only.
+	MountBroker string
+
+	// DetachedMount, if set, creates the mount with the new mount
+	// API (fsopen, fsmount), but does not attach it to a
+	// directory: the mount point passed to NewServer is
+	// ignored. Attach the mount with Server.AttachMount, or use
+	// Server.DetachedMountFd. Like DirectMountStrict, this
+	// requires CAP_SYS_ADMIN. Linux only.
+	DetachedMount bool
+
+	// CleanStaleMount, if set, makes NewServer check whether the
+	// mount point holds a FUSE mount whose server has died,
+	// which fails with ENOTCONN. Such a mount is lazily unmounted
+	// before mounting, so a crashed file system can be restarted
+	// without manual cleanup. Linux only.
+	CleanStaleMount bool
+
 	// DirectMountFlags are the mountflags passed to syscall.Mount. If zero, the
 	// default value used by fusermount are used: syscall.MS_NOSUID|syscall.MS_NODEV.
 	//
 	// If you actually *want* zero flags, pass syscall.MS_MGC_VAL, which is ignored
 	// by the kernel. See `man 2 mount` for details about MS_MGC_VAL.
+	//
+	// With the new mount API, the flags are converted to the
+	// equivalent MOUNT_ATTR_* flags. If that is not possible,
+	// syscall.Mount is used.
 	DirectMountFlags uintptr
 
 	// EnableAcl, if set, enables kernel ACL support.
@@ -327,6 +373,20 @@ type MountOptions struct {
 	// for details.
 	EnableAcl bool
 
+	// EnableSecurityContext, if set, asks the kernel to send the
+	// security context, for example the SELinux label, that a new
+	// file should get along with CREATE, MKNOD, MKDIR, SYMLINK and
+	// TMPFILE requests. See SecurityContextFromContext.
+	EnableSecurityContext bool
+
+	// EnableCreateSupplementaryGroup, if set, asks the kernel to send
+	// the group of a set-group-ID parent directory along with
+	// requests that create files, if it is a supplementary group of
+	// the caller. File systems that create files on behalf of the
+	// caller need it to decide whether the new file keeps its
+	// set-group-ID bit. See GroupsFromContext.
+	EnableCreateSupplementaryGroup bool
+
 	// DisableReadDirPlus, if set, disables the ReadDirPlus capability so
 	// ReadDir is used instead. Simple directory queries (i.e. 'ls' without
 	// '-l') can be faster with ReadDir, as no per-file stat calls are needed.
@@ -360,6 +420,100 @@ type MountOptions struct {
 	// ExtraCapabilities is a bitmask of capabilities which
 	// must be enabled in addition to the defaults.
 	ExtraCapabilities uint64
+
+	// NumQueues is the number of FUSE device file descriptors
+	// that requests are read from. If larger than 1, the device is
+	// cloned (Linux only), and each reader goroutine is pinned to
+	// one of the clones. This reduces contention in the kernel on
+	// machines with many cores. The maximum number of reader
+	// goroutines is shared among the queues.
+	NumQueues int
+
+	// EnableIOUring, if set, negotiates the FUSE-over-io_uring
+	// transport (Linux 6.14 and later, with the fuse module
+	// parameter enable_uring set). Requests are then exchanged
+	// through a ring per CPU, avoiding a read and write syscall
+	// for each request. If the kernel refuses, the server falls
+	// back to reading /dev/fuse.
+	EnableIOUring bool
+
+	// IOUringQueueDepth is the number of requests that can be
+	// outstanding on each io_uring queue. Each slot holds a
+	// buffer of MaxWrite bytes. If unset, the default is 2.
+	IOUringQueueDepth int
+
+	// EnablePoll, if set, passes POLL requests to
+	// RawFileSystem.Poll, so files can support poll(2), select(2)
+	// and epoll(7). If unset, POLL is switched off while mounting
+	// (see WaitMount).
+	//
+	// The Go runtime registers files it opens with its own epoll
+	// instance, and the kernel sends POLL while holding the lock
+	// of that instance. If the process accesses its own mount,
+	// Poll hence must not block, open files or otherwise use the
+	// runtime's poller, as that deadlocks.
+	EnablePoll bool
+
+	// RequestTimeout, if set, asks the kernel to abort the
+	// connection if a request is not answered within this time.
+	// After that, all accesses to the mount fail with ENOTCONN.
+	// The kernel counts in whole seconds, and may lower the
+	// value to the fs.fuse.max_request_timeout sysctl. This
+	// needs CAP_REQUEST_TIMEOUT (Linux 6.14); older kernels
+	// ignore it.
+	RequestTimeout time.Duration
+
+	// WatchdogTimeout, if set, starts a watchdog that logs
+	// requests whose handler has been running for longer than
+	// this.
+	WatchdogTimeout time.Duration
+
+	// WatchdogDumpStacks, if set, makes the watchdog log the
+	// stacks of all goroutines when it finds stuck requests.
+	WatchdogDumpStacks bool
+
+	// WatchdogFailRequests, if set, makes the watchdog answer
+	// stuck requests with EIO, and interrupt their handlers. The
+	// reply of the handler is dropped when it eventually
+	// returns. Requests exchanged through io_uring can only be
+	// logged.
+	WatchdogFailRequests bool
+
+	// RecoverPanics, if set, recovers panics in the file system
+	// and in Interceptors while serving a request. The request is
+	// answered with EIO, and the panic is logged along with the
+	// request and the stack. Locks that were held by the
+	// panicking code are not released, so other requests may
+	// still hang.
+	RecoverPanics bool
+
+	// OnPanic, if set, is called for each panic recovered through
+	// RecoverPanics, with a description of the request, the
+	// panic value and the stack. If it returns true, the panic is
+	// raised again, aborting the process.
+	OnPanic func(request string, value interface{}, stack []byte) bool
+
+	// Interceptors, if set, are called around the handling of
+	// each request, for example to add logging, access checks or
+	// metrics. The first interceptor is the outermost one.
+	Interceptors []Interceptor
+
+	// Stats, if set, collects statistics of the requests
+	// served. See NewStats.
+	Stats *Stats
+
+	// Slog, if set, logs requests and replies as structured
+	// records through log/slog. Unlike Debug, it supports level
+	// filtering and sampling, so it can stay on in production.
+	Slog *SlogOptions
+
+	// RecordTraffic, if set, receives a recording of all
+	// requests read from the kernel and all replies written to
+	// it, starting with INIT. Notifications are not
+	// recorded. See ReadRecording for reading it back, and
+	// Replay for replaying it against a RawFileSystem. Splicing
+	// is not used for READ replies while recording.
+	RecordTraffic io.Writer
 }
 
 // RawFileSystem is an interface close to the FUSE wire protocol.
@@ -446,6 +600,14 @@ type RawFileSystem interface {
 
 	// File handling.
 	Create(cancel <-chan struct{}, input *CreateIn, name string, out *CreateOut) (code Status)
+
+	// TmpFile creates and opens an unnamed regular file in the
+	// directory input.NodeId, for open(2) with O_TMPFILE. The
+	// name is a placeholder chosen by the kernel. Unless
+	// input.Flags has O_EXCL, the node may later be given a name
+	// through Link. Returning ENOSYS makes O_TMPFILE fail with
+	// EOPNOTSUPP for the rest of the mount.
+	TmpFile(cancel <-chan struct{}, input *CreateIn, name string, out *CreateOut) (code Status)
 	Open(cancel <-chan struct{}, input *OpenIn, out *OpenOut) (status Status)
 	Read(cancel <-chan struct{}, input *ReadIn, buf []byte) (ReadResult, Status)
 	Lseek(cancel <-chan struct{}, in *LseekIn, out *LseekOut) Status
@@ -458,8 +620,23 @@ type RawFileSystem interface {
 	Release(cancel <-chan struct{}, input *ReleaseIn)
 	Write(cancel <-chan struct{}, input *WriteIn, data []byte) (written uint32, code Status)
 	CopyFileRange(cancel <-chan struct{}, input *CopyFileRangeIn) (written uint32, code Status)
+
+	// CopyFileRange64 is CopyFileRange for copies of 4 GiB and
+	// more. Newer kernels try it before CopyFileRange. If it
+	// returns ENOSYS, the request is served by CopyFileRange
+	// instead, with the length capped to 32 bits.
+	CopyFileRange64(cancel <-chan struct{}, input *CopyFileRangeIn) (written uint64, code Status)
 	Ioctl(cancel <-chan struct{}, input *IoctlIn, inbuf []byte, output *IoctlOut, outbuf []byte) (code Status)
 
+	// Poll reports the I/O readiness of an open file in
+	// out.Revents, see poll(2). It must not block. If
+	// FUSE_POLL_SCHEDULE_NOTIFY is set in input.Flags, the
+	// kernel wants to be woken up through Server.PollNotify
+	// (with input.Kh) when the readiness changes. Returning
+	// ENOSYS switches off POLL for the whole mount. Only called if
+	// MountOptions.EnablePoll is set.
+	Poll(cancel <-chan struct{}, input *PollIn, out *PollOut) (code Status)
+
 	Flush(cancel <-chan struct{}, input *FlushIn) Status
 	Fsync(cancel <-chan struct{}, input *FsyncIn) (code Status)
 	Fallocate(cancel <-chan struct{}, input *FallocateIn) (code Status)
@@ -473,6 +650,13 @@ type RawFileSystem interface {
 
 	StatFs(cancel <-chan struct{}, input *InHeader, out *StatfsOut) (code Status)
 
+	// SyncFs flushes all data of the file system to stable
+	// storage, see syncfs(2). It is sent for the root node.
+	// Returning ENOSYS stops the kernel from sending further
+	// SYNCFS requests. Linux only sends SYNCFS over virtiofs, as
+	// an unprivileged server could stall syncfs(2) indefinitely.
+	SyncFs(cancel <-chan struct{}, input *SyncFsIn) (code Status)
+
 	Statx(cancel <-chan struct{}, input *StatxIn, out *StatxOut) (code Status)
 	// This is called on processing the first request. The
 	// filesystem implementation can use the server argument to
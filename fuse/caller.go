@@ -0,0 +1,233 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// Request extensions are appended to the request by the kernel. They
+// are enabled with MountOptions.EnableSecurityContext and
+// MountOptions.EnableCreateSupplementaryGroup, and the size of the
+// extension area, in units of 8 bytes, is in the lower 16 bits of
+// InHeader.Padding.
+//
+// Each extension starts with a fuse_ext_header{size, type}. For the
+// security context, the type is the number of contexts, which is at
+// most _FUSE_MAX_NR_SECCTX.
+const (
+	_FUSE_MAX_NR_SECCTX = 31
+	_FUSE_EXT_GROUPS    = 32
+)
+
+// extLen returns the size of the extensions appended to the request.
+func (h *InHeader) extLen() int {
+	return int(h.Padding&0xffff) * 8
+}
+
+// SecurityContext is a security label that the kernel asks to apply
+// to a file that is created, for example the SELinux label of the
+// new file. It is stored as the extended attribute Name.
+type SecurityContext struct {
+	// Name is the extended attribute, for example "security.selinux".
+	Name string
+
+	// Value is the label, which is usually NUL-terminated.
+	Value []byte
+}
+
+// requestExtensions holds the extensions of a request.
+type requestExtensions struct {
+	// securityContexts is non-nil if the kernel sent a security
+	// context extension, even if it has no contexts.
+	securityContexts []SecurityContext
+
+	// groups are supplementary groups of the caller.
+	groups []uint32
+}
+
+// parseExtensions parses the extension area of a request.
+func parseExtensions(data []byte) (*requestExtensions, error) {
+	ext := &requestExtensions{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("short extension header: %d bytes", len(data))
+		}
+		size := binary.NativeEndian.Uint32(data)
+		typ := binary.NativeEndian.Uint32(data[4:])
+		if size < 8 || size%8 != 0 || int(size) > len(data) {
+			return nil, fmt.Errorf("extension type %d: bad size %d", typ, size)
+		}
+		payload := data[8:size]
+		data = data[size:]
+
+		switch {
+		case typ <= _FUSE_MAX_NR_SECCTX:
+			ctxs, err := parseSecurityContexts(payload, int(typ))
+			if err != nil {
+				return nil, err
+			}
+			ext.securityContexts = ctxs
+		case typ == _FUSE_EXT_GROUPS:
+			if len(payload) < 4 {
+				return nil, fmt.Errorf("short supplementary groups extension")
+			}
+			n := binary.NativeEndian.Uint32(payload)
+			payload = payload[4:]
+			if uint64(n)*4 > uint64(len(payload)) {
+				return nil, fmt.Errorf("supplementary groups extension: %d groups in %d bytes", n, len(payload))
+			}
+			for i := uint32(0); i < n; i++ {
+				ext.groups = append(ext.groups, binary.NativeEndian.Uint32(payload[4*i:]))
+			}
+		}
+		// Unknown extensions are skipped.
+	}
+	return ext, nil
+}
+
+// parseSecurityContexts parses n fuse_secctx entries. Each is a
+// {size, padding} header, followed by the NUL-terminated attribute
+// name and size bytes of value, padded to 8 bytes.
+func parseSecurityContexts(data []byte, n int) ([]SecurityContext, error) {
+	result := make([]SecurityContext, 0, n)
+	for i := 0; i < n; i++ {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("short security context")
+		}
+		size := int(binary.NativeEndian.Uint32(data))
+		data = data[8:]
+		end := bytes.IndexByte(data, 0)
+		if end < 0 || end+1+size > len(data) {
+			return nil, fmt.Errorf("security context %d: bad size %d", i, size)
+		}
+		sc := SecurityContext{
+			Name:  string(data[:end]),
+			Value: append([]byte(nil), data[end+1:end+1+size]...),
+		}
+		result = append(result, sc)
+		skip := (8+end+1+size+7)&^7 - 8
+		if skip > len(data) {
+			skip = len(data)
+		}
+		data = data[skip:]
+	}
+	return result, nil
+}
+
+// splitExtensions separates the extensions from the payload, and
+// parses them into r.ext.
+func (r *request) splitExtensions() Status {
+	n := r.inHeader().extLen()
+	if n == 0 {
+		return OK
+	}
+	if n > len(r.inPayload) {
+		return EINVAL
+	}
+	data := r.inPayload[len(r.inPayload)-n:]
+	r.inPayload = r.inPayload[:len(r.inPayload)-n]
+	ext, err := parseExtensions(data)
+	if err != nil {
+		return EINVAL
+	}
+	r.ext = ext
+	return OK
+}
+
+type groupsKeyType struct{}
+
+var groupsKey groupsKeyType
+
+type securityContextKeyType struct{}
+
+var securityContextKey securityContextKeyType
+
+// GroupsFromContext returns the supplementary groups of the process
+// that made the request ctx was derived from. They are read from
+// /proc/PID/status the first time they are asked for, and merged
+// with the groups the kernel sends along with the request if
+// MountOptions.EnableCreateSupplementaryGroup is set.
+//
+// The process may change its groups or exit while the request is
+// served, and its PID is zero if it is not visible in the PID
+// namespace of the server, so the result should be used as a hint
+// for permission checks, and not as proof of identity. Reading /proc
+// is only supported on Linux.
+func GroupsFromContext(ctx context.Context) ([]uint32, error) {
+	if c, ok := ctx.Value(groupsKey).(*Context); ok {
+		return c.supplementaryGroups()
+	}
+	caller, ok := FromContext(ctx)
+	if !ok {
+		return nil, syscall.ESRCH
+	}
+	return procGroups(caller.Pid)
+}
+
+// SecurityContextFromContext returns the security contexts that the
+// kernel asks to apply to the file created by the request ctx was
+// derived from. It returns false if the kernel did not send a
+// security context, which is the case for requests other than
+// CREATE, MKNOD, MKDIR, SYMLINK and TMPFILE, and if
+// MountOptions.EnableSecurityContext is not set. The list is empty if
+// no security module provides a label. The contexts are only passed
+// to file systems that implement RawFileSystemCtx, such as those of
+// package fs.
+func SecurityContextFromContext(ctx context.Context) ([]SecurityContext, bool) {
+	v, ok := ctx.Value(securityContextKey).([]SecurityContext)
+	return v, ok
+}
+
+// groupsState holds the supplementary groups of the caller of a
+// Context, which are resolved on first use.
+type groupsState struct {
+	once   sync.Once
+	groups []uint32
+	err    error
+}
+
+func (c *Context) supplementaryGroups() ([]uint32, error) {
+	p := atomic.LoadPointer(&c.groups)
+	if p == nil {
+		atomic.CompareAndSwapPointer(&c.groups, nil, unsafe.Pointer(&groupsState{}))
+		p = atomic.LoadPointer(&c.groups)
+	}
+	st := (*groupsState)(p)
+	st.once.Do(func() {
+		var ext []uint32
+		if c.ext != nil {
+			ext = c.ext.groups
+		}
+		groups, err := procGroups(c.Pid)
+		if err != nil && len(ext) == 0 {
+			st.err = err
+			return
+		}
+		for _, g := range ext {
+			if !containsGroup(groups, g) {
+				groups = append(groups, g)
+			}
+		}
+		st.groups = groups
+	})
+	return st.groups, st.err
+}
+
+func containsGroup(groups []uint32, g uint32) bool {
+	for _, x := range groups {
+		if x == g {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// procGroups reads the supplementary groups of pid from the Groups
+// line of /proc/PID/status.
+func procGroups(pid uint32) ([]uint32, error) {
+	if pid == 0 {
+		return nil, syscall.ESRCH
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcGroups(data)
+}
+
+func parseProcGroups(status []byte) ([]uint32, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		rest, ok := bytes.CutPrefix(scanner.Bytes(), []byte("Groups:"))
+		if !ok {
+			continue
+		}
+		groups := []uint32{}
+		for _, field := range bytes.Fields(rest) {
+			g, err := strconv.ParseUint(string(field), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("bad group %q: %v", field, err)
+			}
+			groups = append(groups, uint32(g))
+		}
+		return groups, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no Groups in process status")
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestParseExtensions(t *testing.T) {
+	ne := binary.NativeEndian
+	name := "security.selinux\x00"
+	value := "system_u:object_r:tmp_t:s0\x00"
+
+	// fuse_secctx_header, fuse_secctx, name, value, padding.
+	entry := (8 + len(name) + len(value) + 7) &^ 7
+	secctx := make([]byte, 8+entry)
+	ne.PutUint32(secctx, uint32(len(secctx)))
+	ne.PutUint32(secctx[4:], 1)
+	ne.PutUint32(secctx[8:], uint32(len(value)))
+	copy(secctx[16:], name)
+	copy(secctx[16+len(name):], value)
+
+	// fuse_ext_header, fuse_supp_groups.
+	groups := make([]byte, 24)
+	ne.PutUint32(groups, 24)
+	ne.PutUint32(groups[4:], _FUSE_EXT_GROUPS)
+	ne.PutUint32(groups[8:], 2)
+	ne.PutUint32(groups[12:], 5)
+	ne.PutUint32(groups[16:], 6)
+
+	ext, err := parseExtensions(append(secctx, groups...))
+	if err != nil {
+		t.Fatalf("parseExtensions: %v", err)
+	}
+	wantCtx := []SecurityContext{{Name: "security.selinux", Value: []byte(value)}}
+	if !reflect.DeepEqual(ext.securityContexts, wantCtx) {
+		t.Errorf("got security contexts %v, want %v", ext.securityContexts, wantCtx)
+	}
+	if want := []uint32{5, 6}; !reflect.DeepEqual(ext.groups, want) {
+		t.Errorf("got groups %v, want %v", ext.groups, want)
+	}
+
+	// A security context extension without contexts.
+	empty := make([]byte, 8)
+	ne.PutUint32(empty, 8)
+	ext, err = parseExtensions(empty)
+	if err != nil {
+		t.Fatalf("parseExtensions: %v", err)
+	}
+	if ext.securityContexts == nil || len(ext.securityContexts) != 0 {
+		t.Errorf("got security contexts %#v, want empty list", ext.securityContexts)
+	}
+
+	if _, err := parseExtensions(groups[:20]); err == nil {
+		t.Error("truncated extension should fail")
+	}
+}
+
+func TestGroupsFromContext(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs /proc")
+	}
+	want, err := os.Getgroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(want)
+
+	h := &InHeader{Caller: Caller{Pid: uint32(os.Getpid())}}
+	// Context can be copied; vet checks that it holds no locks.
+	copied := *newContext(nil, h)
+	for _, ctx := range []context.Context{
+		newContext(nil, h),
+		&copied,
+		NewContext(context.Background(), &h.Caller),
+	} {
+		groups, err := GroupsFromContext(ctx)
+		if err != nil {
+			t.Fatalf("GroupsFromContext: %v", err)
+		}
+		var got []int
+		for _, g := range groups {
+			got = append(got, int(g))
+		}
+		sort.Ints(got)
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("got groups %v, want %v", got, want)
+		}
+	}
+
+	if _, err := GroupsFromContext(newContext(nil, &InHeader{})); err == nil {
+		t.Error("GroupsFromContext for PID 0 should fail")
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package fuse
+
+import "syscall"
+
+func procGroups(pid uint32) ([]uint32, error) {
+	return nil, syscall.ENOSYS
+}
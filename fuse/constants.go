@@ -25,6 +25,8 @@ const (
 
 	FUSE_POLL_SCHEDULE_NOTIFY = (1 << 0)
 
+	FUSE_EXPIRE_ONLY = (1 << 0)
+
 	CUSE_INIT_INFO_MAX = 4096
 
 	S_IFDIR = syscall.S_IFDIR
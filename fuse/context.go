@@ -7,6 +7,7 @@ package fuse
 import (
 	"context"
 	"time"
+	"unsafe"
 )
 
 // Context passes along cancelation signal and request data (PID, GID,
@@ -14,11 +15,59 @@ import (
 // package from Go, but it does implement the context.Context
 // interface.
 //
+// The supplementary groups of the caller and the security context for
+// new files are available through GroupsFromContext and
+// SecurityContextFromContext.
+//
 // When a FUSE request is canceled, and the file system chooses to honor
 // the cancellation, the response should be EINTR.
 type Context struct {
 	Caller
 	Cancel <-chan struct{}
+
+	// Unique is the ID the kernel assigned to the request. It is
+	// zero if the Context does not belong to a request.
+	Unique uint64
+
+	// ext holds the request extensions, if the kernel sent any.
+	ext *requestExtensions
+
+	// groups points to the groupsState of the caller, which is
+	// allocated when the groups are first asked for. It is not
+	// stored by value, so a Context can be copied.
+	groups unsafe.Pointer
+}
+
+// newContext returns the Context for a request with the given
+// header.
+func newContext(cancel <-chan struct{}, h *InHeader) *Context {
+	return &Context{Caller: h.Caller, Cancel: cancel, Unique: h.Unique}
+}
+
+// cancelContext is the context for a RawFileSystem, which only uses
+// the cancel channel. Unlike a *Context, it is passed without
+// allocating.
+type cancelContext <-chan struct{}
+
+func (c cancelContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c cancelContext) Done() <-chan struct{} {
+	return c
+}
+
+func (c cancelContext) Err() error {
+	select {
+	case <-c:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+func (c cancelContext) Value(key interface{}) interface{} {
+	return nil
 }
 
 func (c *Context) Deadline() (time.Time, bool) {
@@ -42,6 +91,10 @@ type callerKeyType struct{}
 
 var callerKey callerKeyType
 
+type uniqueKeyType struct{}
+
+var uniqueKey uniqueKeyType
+
 func FromContext(ctx context.Context) (*Caller, bool) {
 	v, ok := ctx.Value(callerKey).(*Caller)
 	return v, ok
@@ -51,9 +104,25 @@ func NewContext(ctx context.Context, caller *Caller) context.Context {
 	return context.WithValue(ctx, callerKey, caller)
 }
 
+// UniqueFromContext returns the unique ID of the FUSE request that
+// ctx was derived from.
+func UniqueFromContext(ctx context.Context) (uint64, bool) {
+	v, ok := ctx.Value(uniqueKey).(uint64)
+	return v, ok && v != 0
+}
+
 func (c *Context) Value(key interface{}) interface{} {
-	if key == callerKey {
+	switch key {
+	case callerKey:
 		return &c.Caller
+	case uniqueKey:
+		return c.Unique
+	case groupsKey:
+		return c
+	case securityContextKey:
+		if c.ext != nil && c.ext.securityContexts != nil {
+			return c.ext.securityContexts
+		}
 	}
 	return nil
 }
@@ -109,6 +109,10 @@ func (fs *defaultRawFileSystem) Create(cancel <-chan struct{}, input *CreateIn,
 	return ENOSYS
 }
 
+func (fs *defaultRawFileSystem) TmpFile(cancel <-chan struct{}, input *CreateIn, name string, out *CreateOut) (code Status) {
+	return ENOSYS
+}
+
 func (fs *defaultRawFileSystem) OpenDir(cancel <-chan struct{}, input *OpenIn, out *OpenOut) (status Status) {
 	return ENOSYS
 }
@@ -167,10 +171,22 @@ func (fs *defaultRawFileSystem) CopyFileRange(cancel <-chan struct{}, input *Cop
 	return 0, ENOSYS
 }
 
+func (fs *defaultRawFileSystem) CopyFileRange64(cancel <-chan struct{}, input *CopyFileRangeIn) (written uint64, code Status) {
+	return 0, ENOSYS
+}
+
 func (fs *defaultRawFileSystem) Ioctl(cancel <-chan struct{}, input *IoctlIn, inbuf []byte, output *IoctlOut, outbuf []byte) (code Status) {
 	return ENOSYS
 }
 
+func (fs *defaultRawFileSystem) Poll(cancel <-chan struct{}, input *PollIn, out *PollOut) (code Status) {
+	return ENOSYS
+}
+
+func (fs *defaultRawFileSystem) SyncFs(cancel <-chan struct{}, input *SyncFsIn) (code Status) {
+	return ENOSYS
+}
+
 func (fs *defaultRawFileSystem) Lseek(cancel <-chan struct{}, in *LseekIn, out *LseekOut) Status {
 	return ENOSYS
 }
@@ -0,0 +1,97 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/internal/fsmount"
+	"golang.org/x/sys/unix"
+)
+
+// fsmountFuse creates a detached mount for the FUSE device fd with
+// the new mount API, and returns the mount's file descriptor. If the
+// API cannot be used, the error wraps fsmount.ErrUnavailable.
+func fsmountFuse(fd int, rootMode uint32, opts *MountOptions) (int, error) {
+	flags, params := directMountParams(fd, rootMode, opts)
+	attrs, sbFlags, ok := fsmount.Attrs(flags)
+	if !ok {
+		return -1, fmt.Errorf("%w: unsupported mount flags %#x", fsmount.ErrUnavailable, flags)
+	}
+	params = append(params, sbFlags...)
+
+	source := opts.FsName
+	if source == "" {
+		source = opts.Name
+	}
+	if opts.Debug {
+		opts.Logger.Printf("fsmountFuse: source %q, subtype %q, parameters %q, attributes %#x",
+			source, opts.Name, params, attrs)
+	}
+	return fsmount.Mount(source, opts.Name, params, attrs)
+}
+
+// mountDetached creates a detached mount for MountOptions.DetachedMount.
+// It returns the FUSE device and the mount file descriptors.
+func mountDetached(opts *MountOptions, ready chan<- error) (fd int, mntFd int, err error) {
+	fd, err = syscall.Open("/dev/fuse", os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, -1, err
+	}
+	mntFd, err = fsmountFuse(fd, syscall.S_IFDIR, opts)
+	if err != nil {
+		syscall.Close(fd)
+		return -1, -1, err
+	}
+	close(ready)
+	return fd, mntFd, nil
+}
+
+// AttachMount attaches a mount created with MountOptions.DetachedMount
+// at mountPoint, with move_mount(2). After that, Unmount unmounts it
+// from there.
+//
+// The mount point is looked up in the mount namespace of the calling
+// thread. To attach the mount in another namespace, call this from a
+// locked OS thread that has joined the namespace with setns(2), or
+// pass DetachedMountFd to a process in that namespace. The namespace
+// is recorded, and Unmount must be called from a thread in the same
+// namespace; it returns an error otherwise.
+func (ms *Server) AttachMount(mountPoint string) error {
+	if ms.detachedFd < 0 {
+		return fmt.Errorf("not a detached mount")
+	}
+	mountPoint, err := filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+
+	// The namespace must be read on the thread that does the
+	// move_mount.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ns, err := threadMountNamespace()
+	if err != nil {
+		return err
+	}
+	if err := unix.MoveMount(ms.detachedFd, "", unix.AT_FDCWD, mountPoint, unix.MOVE_MOUNT_F_EMPTY_PATH); err != nil {
+		return fmt.Errorf("move_mount: %w", err)
+	}
+	syscall.Close(ms.detachedFd)
+	ms.detachedFd = -1
+	ms.mountPoint = mountPoint
+	ms.mountNs = ns
+	return nil
+}
+
+// threadMountNamespace identifies the mount namespace of the calling
+// thread, for example "mnt:[4026531841]".
+func threadMountNamespace() (string, error) {
+	return os.Readlink("/proc/thread-self/ns/mnt")
+}
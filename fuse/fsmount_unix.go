@@ -0,0 +1,27 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package fuse
+
+import "syscall"
+
+// mountDetached is only supported on Linux.
+func mountDetached(opts *MountOptions, ready chan<- error) (fd int, mntFd int, err error) {
+	return -1, -1, syscall.ENOSYS
+}
+
+// AttachMount attaches a mount created with
+// MountOptions.DetachedMount. Detached mounts are only supported on
+// Linux.
+func (ms *Server) AttachMount(mountPoint string) error {
+	return syscall.ENOSYS
+}
+
+// threadMountNamespace is only used for mounts attached with
+// AttachMount.
+func threadMountNamespace() (string, error) {
+	return "", syscall.ENOSYS
+}
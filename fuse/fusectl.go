@@ -0,0 +1,84 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fusectlDir is where the fusectl file system is mounted.
+const fusectlDir = "/sys/fs/fuse/connections"
+
+// Connection controls a FUSE connection through the fusectl file
+// system. It is only supported on Linux, and requires fusectl to be
+// mounted at /sys/fs/fuse/connections.
+type Connection struct {
+	// ID is the kernel's number for the connection. It is the
+	// device number of the mount.
+	ID uint32
+
+	dir string
+}
+
+func newConnection(id uint32) (*Connection, error) {
+	c := &Connection{
+		ID:  id,
+		dir: filepath.Join(fusectlDir, strconv.FormatUint(uint64(id), 10)),
+	}
+	if _, err := os.Stat(c.dir); err != nil {
+		return nil, fmt.Errorf("fusectl: %w", err)
+	}
+	return c, nil
+}
+
+// Abort aborts the connection. Reads from the FUSE device fail, and
+// all callers receive ENOTCONN on their pending and future syscalls.
+func (c *Connection) Abort() error {
+	return os.WriteFile(filepath.Join(c.dir, "abort"), []byte("1"), 0)
+}
+
+// Waiting returns the number of requests that the kernel has queued
+// or sent to the server, but that have not been answered yet.
+func (c *Connection) Waiting() (int, error) {
+	return c.readInt("waiting")
+}
+
+// MaxBackground returns the maximum number of background requests,
+// see MountOptions.MaxBackground.
+func (c *Connection) MaxBackground() (int, error) {
+	return c.readInt("max_background")
+}
+
+// SetMaxBackground sets the maximum number of background requests.
+func (c *Connection) SetMaxBackground(n int) error {
+	return c.writeInt("max_background", n)
+}
+
+// CongestionThreshold returns the number of background requests
+// beyond which the kernel considers the connection congested.
+func (c *Connection) CongestionThreshold() (int, error) {
+	return c.readInt("congestion_threshold")
+}
+
+// SetCongestionThreshold sets the congestion threshold.
+func (c *Connection) SetCongestionThreshold(n int) error {
+	return c.writeInt("congestion_threshold", n)
+}
+
+func (c *Connection) readInt(name string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func (c *Connection) writeInt(name string, n int) error {
+	return os.WriteFile(filepath.Join(c.dir, name), []byte(strconv.Itoa(n)), 0)
+}
@@ -0,0 +1,31 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"fmt"
+)
+
+// Connection returns the fusectl connection of the mount. It finds
+// the connection in /proc/self/mountinfo, like ListMounts, and does
+// not access the mount, so it can be used to abort a hung file
+// system.
+func (ms *Server) Connection() (*Connection, error) {
+	if ms.mountPoint == "" {
+		return nil, fmt.Errorf("not mounted")
+	}
+	if parseFuseFd(ms.mountPoint) >= 0 {
+		return nil, fmt.Errorf("cannot find connection of magic mountpoint %q", ms.mountPoint)
+	}
+
+	found, err := findFuseMount(ms.mountPoint)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no FUSE mount at %q", ms.mountPoint)
+	}
+	return found.Connection()
+}
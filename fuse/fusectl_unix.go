@@ -0,0 +1,15 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package fuse
+
+import "syscall"
+
+// Connection returns the fusectl connection of the mount. fusectl is
+// only available on Linux.
+func (ms *Server) Connection() (*Connection, error) {
+	return nil, syscall.ENOSYS
+}
@@ -0,0 +1,763 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fusetest emulates the kernel side of the FUSE protocol, so
+// file systems can be tested without /dev/fuse, fusermount or
+// mounting privileges.
+//
+// A VFS turns path-based calls into FUSE requests, which are served
+// in-process through a fuse.ProtocolServer:
+//
+//	root := &fs.Inode{}
+//	opts := &fs.Options{}
+//	v, err := fusetest.New(fs.NewNodeFS(root, opts), &opts.MountOptions)
+//	...
+//	err = v.WriteFile("dir/file", []byte("hello"), 0644)
+//
+// Like the kernel, the VFS caches directory entries, counts the
+// lookups it receives for each node, and sends FORGET once a node is
+// no longer referenced by a directory entry or open file.
+//
+// The tests in package posixtest make system calls on a mounted
+// directory, so they cannot run against a VFS. Tests in their style
+// are written against the VFS methods instead.
+package fusetest
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// renameExchange is RENAME_EXCHANGE from renameat2(2).
+const renameExchange = 1 << 1
+
+// The protocol version announced in INIT.
+const (
+	kernelMajor = 7
+	kernelMinor = 31
+)
+
+// VFS is a minimal virtual file system layer on top of a
+// fuse.ProtocolServer. Paths are slash-separated and relative to the
+// root of the file system; symlinks in paths are not followed.
+// Errors returned from the file system are syscall.Errno values.
+//
+// Directory entries are cached for as long as the file system allows,
+// but negative entries and attributes are not cached. Calls are
+// serialized.
+type VFS struct {
+	mu     sync.Mutex
+	ps     *fuse.ProtocolServer
+	unique uint64
+	caller fuse.Caller
+
+	// maxWrite is the maximum size of READ and WRITE requests.
+	maxWrite int
+
+	root   *dentry
+	inodes map[uint64]*inode
+}
+
+// inode is a node that the file system returned a lookup for.
+type inode struct {
+	nodeID  uint64
+	nlookup uint64
+
+	// refs counts the directory entries and open files for the
+	// inode.
+	refs int
+}
+
+type dentry struct {
+	name     string
+	parent   *dentry
+	children map[string]*dentry
+	inode    *inode
+	expires  time.Time
+
+	// open counts the open files for the entry. They keep it
+	// and its parents in the cache.
+	open int
+}
+
+// New initializes fs, and returns a VFS for it. Requests are sent on
+// behalf of the calling process. If opts is nil, defaults are used.
+func New(fs fuse.RawFileSystem, opts *fuse.MountOptions) (*VFS, error) {
+	if opts == nil {
+		opts = &fuse.MountOptions{}
+	}
+	v := &VFS{
+		ps: fuse.NewProtocolServer(fs, opts),
+		caller: fuse.Caller{
+			Owner: fuse.Owner{
+				Uid: uint32(os.Getuid()),
+				Gid: uint32(os.Getgid()),
+			},
+			Pid: uint32(os.Getpid()),
+		},
+		root: &dentry{
+			inode: &inode{nodeID: fuse.FUSE_ROOT_ID, refs: 1},
+		},
+		inodes: make(map[uint64]*inode),
+	}
+
+	in := fuse.InitIn{
+		Major:        kernelMajor,
+		Minor:        kernelMinor,
+		MaxReadAhead: 128 << 10,
+	}
+	var out fuse.InitOut
+	if _, errno := v.call(fuse.FUSE_INIT, 0, &in.InHeader, unsafe.Sizeof(in), nil, bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		return nil, errno
+	}
+	v.maxWrite = int(out.MaxWrite)
+	if v.maxWrite == 0 {
+		v.maxWrite = 4096
+	}
+	return v, nil
+}
+
+// SetCaller sets the credentials that requests are sent with.
+func (v *VFS) SetCaller(c fuse.Caller) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.caller = c
+}
+
+// Inodes returns the number of outstanding lookups, by node ID, for
+// the nodes that have not been forgotten. The root is not included.
+func (v *VFS) Inodes() map[uint64]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	r := make(map[uint64]uint64, len(v.inodes))
+	for id, ino := range v.inodes {
+		r[id] = ino.nlookup
+	}
+	return r
+}
+
+// DropCaches drops the cached directory entries that are not in use
+// by open files, as writing to /proc/sys/vm/drop_caches does. Nodes
+// without entries are forgotten.
+func (v *VFS) DropCaches() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prune(v.root)
+}
+
+// prune drops the unused entries below d. It returns whether d is
+// still in use.
+func (v *VFS) prune(d *dentry) bool {
+	busy := d.open > 0
+	for _, ch := range d.children {
+		if v.prune(ch) {
+			busy = true
+		} else {
+			v.drop(ch)
+		}
+	}
+	return busy
+}
+
+func bytesOf(p unsafe.Pointer, size uintptr) []byte {
+	return unsafe.Slice((*byte)(p), size)
+}
+
+func cstring(names ...string) []byte {
+	var b []byte
+	for _, n := range names {
+		b = append(b, n...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// call sends a request for nodeID to the file system. The input
+// struct starts with hdr and is inSize bytes long; it is followed by
+// payload. The reply struct is put in out, which must have the size
+// that the protocol specifies for the opcode, and the reply payload
+// in data. It returns the size of the reply payload.
+func (v *VFS) call(op uint32, nodeID uint64, hdr *fuse.InHeader, inSize uintptr, payload []byte, out []byte, data []byte) (int, syscall.Errno) {
+	v.unique++
+	*hdr = fuse.InHeader{
+		Length: uint32(int(inSize) + len(payload)),
+		Opcode: op,
+		Unique: v.unique,
+		NodeId: nodeID,
+		Caller: v.caller,
+	}
+	in := make([]byte, 0, hdr.Length)
+	in = append(in, bytesOf(unsafe.Pointer(hdr), inSize)...)
+	in = append(in, payload...)
+
+	if op == fuse.FUSE_FORGET {
+		_, st := v.ps.HandleRequest([][]byte{in}, nil)
+		return 0, syscall.Errno(st)
+	}
+
+	var outHeader fuse.OutHeader
+	iov := [][]byte{bytesOf(unsafe.Pointer(&outHeader), unsafe.Sizeof(outHeader))}
+	if len(out) > 0 {
+		iov = append(iov, out)
+	}
+	if len(data) > 0 {
+		iov = append(iov, data)
+	}
+	n, st := v.ps.HandleRequest([][]byte{in}, iov)
+	if st != fuse.OK {
+		return 0, syscall.Errno(st)
+	}
+	if outHeader.Status != 0 {
+		return 0, syscall.Errno(-outHeader.Status)
+	}
+	return n - len(iov[0]) - len(out), 0
+}
+
+// split splits name into its components.
+func split(name string) []string {
+	var r []string
+	for _, c := range strings.Split(name, "/") {
+		if c != "" && c != "." {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// walk returns the entry for name.
+func (v *VFS) walk(name string) (*dentry, error) {
+	d := v.root
+	for _, c := range split(name) {
+		if c == ".." {
+			if d.parent != nil {
+				d = d.parent
+			}
+			continue
+		}
+		ch, errno := v.lookup(d, c)
+		if errno != 0 {
+			return nil, errno
+		}
+		d = ch
+	}
+	return d, nil
+}
+
+// walkParent returns the entry for the directory containing name,
+// and the last component of name.
+func (v *VFS) walkParent(name string) (*dentry, string, error) {
+	comps := split(name)
+	if len(comps) == 0 {
+		return nil, "", syscall.EBUSY
+	}
+	last := comps[len(comps)-1]
+	if last == ".." {
+		return nil, "", syscall.EINVAL
+	}
+	parent, err := v.walk(strings.Join(comps[:len(comps)-1], "/"))
+	return parent, last, err
+}
+
+// lookup returns the child entry of parent, sending LOOKUP if it is
+// not cached, or no longer valid.
+func (v *VFS) lookup(parent *dentry, name string) (*dentry, syscall.Errno) {
+	if ch := parent.children[name]; ch != nil && time.Now().Before(ch.expires) {
+		return ch, 0
+	}
+
+	var hdr fuse.InHeader
+	var out fuse.EntryOut
+	_, errno := v.call(fuse.FUSE_LOOKUP, parent.inode.nodeID, &hdr, unsafe.Sizeof(hdr), cstring(name),
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil)
+	if errno == 0 && out.NodeId == 0 {
+		// A negative entry.
+		errno = syscall.ENOENT
+	}
+	if errno != 0 {
+		v.drop(parent.children[name])
+		return nil, errno
+	}
+	return v.enter(parent, name, &out), 0
+}
+
+// enter records a successful lookup of name in parent.
+func (v *VFS) enter(parent *dentry, name string, out *fuse.EntryOut) *dentry {
+	ino := v.inodes[out.NodeId]
+	if ino == nil {
+		ino = &inode{nodeID: out.NodeId}
+		v.inodes[out.NodeId] = ino
+	}
+	ino.nlookup++
+
+	d := parent.children[name]
+	if d == nil || d.inode != ino {
+		v.drop(d)
+		d = &dentry{name: name, parent: parent, inode: ino}
+		ino.refs++
+		if parent.children == nil {
+			parent.children = make(map[string]*dentry)
+		}
+		parent.children[name] = d
+	}
+	d.expires = time.Now().Add(out.EntryTimeout())
+	return d
+}
+
+// drop removes d and its children from the cache.
+func (v *VFS) drop(d *dentry) {
+	if d == nil {
+		return
+	}
+	for _, ch := range d.children {
+		v.drop(ch)
+	}
+	if d.parent != nil && d.parent.children[d.name] == d {
+		delete(d.parent.children, d.name)
+	}
+	v.unref(d.inode)
+}
+
+// unref drops a reference to ino, and sends FORGET if it was the
+// last one.
+func (v *VFS) unref(ino *inode) {
+	ino.refs--
+	if ino.refs > 0 || ino.nodeID == fuse.FUSE_ROOT_ID {
+		return
+	}
+	in := fuse.ForgetIn{Nlookup: ino.nlookup}
+	v.call(fuse.FUSE_FORGET, ino.nodeID, &in.InHeader, unsafe.Sizeof(in), nil, nil, nil)
+	delete(v.inodes, ino.nodeID)
+}
+
+// Stat returns the attributes of name.
+func (v *VFS) Stat(name string) (*fuse.Attr, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	d, err := v.walk(name)
+	if err != nil {
+		return nil, err
+	}
+	return v.getattr(d.inode, 0)
+}
+
+func (v *VFS) getattr(ino *inode, fh uint64) (*fuse.Attr, error) {
+	in := fuse.GetAttrIn{Fh_: fh}
+	if fh != 0 {
+		in.Flags_ = fuse.FUSE_GETATTR_FH
+	}
+	var out fuse.AttrOut
+	if _, errno := v.call(fuse.FUSE_GETATTR, ino.nodeID, &in.InHeader, unsafe.Sizeof(in), nil,
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		return nil, errno
+	}
+	return &out.Attr, nil
+}
+
+// Truncate changes the size of name.
+func (v *VFS) Truncate(name string, size uint64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	d, err := v.walk(name)
+	if err != nil {
+		return err
+	}
+	in := fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_SIZE
+	in.Size = size
+	var out fuse.AttrOut
+	if _, errno := v.call(fuse.FUSE_SETATTR, d.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil,
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// newEntry sends a request that creates name, and enters the
+// resulting entry.
+func (v *VFS) newEntry(op uint32, name string, hdr *fuse.InHeader, inSize uintptr, extra ...string) error {
+	parent, base, err := v.walkParent(name)
+	if err != nil {
+		return err
+	}
+	var out fuse.EntryOut
+	if _, errno := v.call(op, parent.inode.nodeID, hdr, inSize, cstring(append([]string{base}, extra...)...),
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		return errno
+	}
+	v.enter(parent, base, &out)
+	return nil
+}
+
+// Mkdir creates the directory name.
+func (v *VFS) Mkdir(name string, mode uint32) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	in := fuse.MkdirIn{Mode: mode}
+	return v.newEntry(fuse.FUSE_MKDIR, name, &in.InHeader, unsafe.Sizeof(in))
+}
+
+// Symlink creates name as a symlink to target.
+func (v *VFS) Symlink(target, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var hdr fuse.InHeader
+	return v.newEntry(fuse.FUSE_SYMLINK, name, &hdr, unsafe.Sizeof(hdr), target)
+}
+
+// Readlink returns the target of the symlink name.
+func (v *VFS) Readlink(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	d, err := v.walk(name)
+	if err != nil {
+		return "", err
+	}
+	var hdr fuse.InHeader
+	buf := make([]byte, 4096)
+	n, errno := v.call(fuse.FUSE_READLINK, d.inode.nodeID, &hdr, unsafe.Sizeof(hdr), nil, nil, buf)
+	if errno != 0 {
+		return "", errno
+	}
+	return string(buf[:n]), nil
+}
+
+// remove sends UNLINK or RMDIR for name.
+func (v *VFS) remove(op uint32, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	parent, base, err := v.walkParent(name)
+	if err != nil {
+		return err
+	}
+	var hdr fuse.InHeader
+	if _, errno := v.call(op, parent.inode.nodeID, &hdr, unsafe.Sizeof(hdr), cstring(base), nil, nil); errno != 0 {
+		return errno
+	}
+	v.drop(parent.children[base])
+	return nil
+}
+
+// Unlink removes the file name.
+func (v *VFS) Unlink(name string) error {
+	return v.remove(fuse.FUSE_UNLINK, name)
+}
+
+// Rmdir removes the empty directory name.
+func (v *VFS) Rmdir(name string) error {
+	return v.remove(fuse.FUSE_RMDIR, name)
+}
+
+// Rename renames oldName to newName, replacing newName if it exists.
+// Flags are the flags of renameat2(2).
+func (v *VFS) Rename(oldName, newName string, flags uint32) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	src, err := v.walk(oldName)
+	if err != nil {
+		return err
+	}
+	if src.parent == nil {
+		return syscall.EBUSY
+	}
+	dstParent, base, err := v.walkParent(newName)
+	if err != nil {
+		return err
+	}
+	in := fuse.RenameIn{Newdir: dstParent.inode.nodeID, Flags: flags}
+	if _, errno := v.call(fuse.FUSE_RENAME2, src.parent.inode.nodeID, &in.InHeader, unsafe.Sizeof(in),
+		cstring(src.name, base), nil, nil); errno != 0 {
+		return errno
+	}
+
+	dst := dstParent.children[base]
+	if dst == src {
+		return nil
+	}
+	delete(src.parent.children, src.name)
+	if dst != nil {
+		delete(dstParent.children, base)
+		if flags&renameExchange != 0 {
+			dst.parent, dst.name = src.parent, src.name
+			src.parent.children[src.name] = dst
+		} else {
+			v.drop(dst)
+		}
+	}
+	src.parent, src.name = dstParent, base
+	if dstParent.children == nil {
+		dstParent.children = make(map[string]*dentry)
+	}
+	dstParent.children[base] = src
+	return nil
+}
+
+// File is an open file or directory.
+type File struct {
+	v      *VFS
+	dentry *dentry
+	inode  *inode
+	fh     uint64
+	dir    bool
+	off    int64
+}
+
+// Open opens name. Flags are the flags of open(2); see Create for
+// O_CREATE.
+func (v *VFS) Open(name string, flags int) (*File, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	d, err := v.walk(name)
+	if err != nil {
+		return nil, err
+	}
+	return v.openFile(d, flags)
+}
+
+// openFile opens a file. Like the kernel without
+// CAP_ATOMIC_O_TRUNC, it truncates through SETATTR after opening.
+func (v *VFS) openFile(d *dentry, flags int) (*File, error) {
+	f, err := v.open(d, fuse.FUSE_OPEN, uint32(flags&^(os.O_CREATE|os.O_EXCL|os.O_TRUNC)))
+	if err != nil || flags&os.O_TRUNC == 0 {
+		return f, err
+	}
+	in := fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_SIZE | fuse.FATTR_FH
+	in.Fh = f.fh
+	var out fuse.AttrOut
+	if _, errno := v.call(fuse.FUSE_SETATTR, d.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil,
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		v.release(f)
+		return nil, errno
+	}
+	return f, nil
+}
+
+func (v *VFS) open(d *dentry, op uint32, flags uint32) (*File, error) {
+	in := fuse.OpenIn{Flags: flags}
+	var out fuse.OpenOut
+	if _, errno := v.call(op, d.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil,
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		return nil, errno
+	}
+	return v.newFile(d, out.Fh, op == fuse.FUSE_OPENDIR), nil
+}
+
+func (v *VFS) newFile(d *dentry, fh uint64, dir bool) *File {
+	d.open++
+	d.inode.refs++
+	return &File{v: v, dentry: d, inode: d.inode, fh: fh, dir: dir}
+}
+
+// Create opens name with O_CREATE, creating it with mode if it does
+// not exist. Like the kernel, it looks up name first, and sends
+// CREATE if it is not found.
+func (v *VFS) Create(name string, flags int, mode uint32) (*File, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	parent, base, err := v.walkParent(name)
+	if err != nil {
+		return nil, err
+	}
+	d, errno := v.lookup(parent, base)
+	if errno == 0 {
+		if flags&os.O_EXCL != 0 {
+			return nil, syscall.EEXIST
+		}
+		return v.openFile(d, flags)
+	} else if errno != syscall.ENOENT {
+		return nil, errno
+	}
+
+	in := fuse.CreateIn{Flags: uint32(flags | os.O_CREATE), Mode: mode | syscall.S_IFREG}
+	var out fuse.CreateOut
+	if _, errno := v.call(fuse.FUSE_CREATE, parent.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), cstring(base),
+		bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+		return nil, errno
+	}
+	d = v.enter(parent, base, &out.EntryOut)
+	return v.newFile(d, out.Fh, false), nil
+}
+
+// Read reads from the current offset.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+// ReadAt reads at offset off. It returns io.EOF if fewer than
+// len(p) bytes could be read.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	f.v.mu.Lock()
+	defer f.v.mu.Unlock()
+	var total int
+	for total < len(p) {
+		chunk := p[total:min(len(p), total+f.v.maxWrite)]
+		in := fuse.ReadIn{Fh: f.fh, Offset: uint64(off) + uint64(total), Size: uint32(len(chunk))}
+		n, errno := f.v.call(fuse.FUSE_READ, f.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil, nil, chunk)
+		if errno != 0 {
+			return total, errno
+		}
+		total += n
+		if n < len(chunk) {
+			return total, io.EOF
+		}
+	}
+	return total, nil
+}
+
+// Write writes at the current offset.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+// WriteAt writes at offset off.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	f.v.mu.Lock()
+	defer f.v.mu.Unlock()
+	var total int
+	for total < len(p) {
+		chunk := p[total:min(len(p), total+f.v.maxWrite)]
+		in := fuse.WriteIn{Fh: f.fh, Offset: uint64(off) + uint64(total), Size: uint32(len(chunk))}
+		var out fuse.WriteOut
+		if _, errno := f.v.call(fuse.FUSE_WRITE, f.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), chunk,
+			bytesOf(unsafe.Pointer(&out), unsafe.Sizeof(out)), nil); errno != 0 {
+			return total, errno
+		}
+		total += int(out.Size)
+		if int(out.Size) < len(chunk) {
+			return total, io.ErrShortWrite
+		}
+	}
+	return total, nil
+}
+
+// Stat returns the attributes of the file.
+func (f *File) Stat() (*fuse.Attr, error) {
+	f.v.mu.Lock()
+	defer f.v.mu.Unlock()
+	return f.v.getattr(f.inode, f.fh)
+}
+
+// Close sends FLUSH, for files, and RELEASE. It returns the error of
+// FLUSH, which is what close(2) returns.
+func (f *File) Close() error {
+	f.v.mu.Lock()
+	defer f.v.mu.Unlock()
+	if f.inode == nil {
+		return os.ErrClosed
+	}
+	var err error
+	if !f.dir {
+		in := fuse.FlushIn{Fh: f.fh}
+		if _, errno := f.v.call(fuse.FUSE_FLUSH, f.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil, nil, nil); errno != 0 && errno != syscall.ENOSYS {
+			err = errno
+		}
+	}
+	f.v.release(f)
+	return err
+}
+
+// release sends RELEASE or RELEASEDIR for f.
+func (v *VFS) release(f *File) {
+	op := fuse.FUSE_RELEASE
+	if f.dir {
+		op = fuse.FUSE_RELEASEDIR
+	}
+	in := fuse.ReleaseIn{Fh: f.fh}
+	v.call(op, f.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil, nil, nil)
+	f.dentry.open--
+	v.unref(f.inode)
+	f.inode = nil
+}
+
+// dirent is the header of an entry in a READDIR reply.
+type dirent struct {
+	Ino     uint64
+	Off     uint64
+	NameLen uint32
+	Typ     uint32
+}
+
+// ReadDir returns the entries of the directory name, except "." and
+// "..".
+func (v *VFS) ReadDir(name string) ([]fuse.DirEntry, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	d, err := v.walk(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := v.open(d, fuse.FUSE_OPENDIR, syscall.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer v.release(f)
+
+	var entries []fuse.DirEntry
+	buf := make([]byte, 4096)
+	var off uint64
+	for {
+		in := fuse.ReadIn{Fh: f.fh, Offset: off, Size: uint32(len(buf))}
+		n, errno := v.call(fuse.FUSE_READDIR, d.inode.nodeID, &in.InHeader, unsafe.Sizeof(in), nil, nil, buf)
+		if errno != 0 {
+			return entries, errno
+		}
+		if n == 0 {
+			return entries, nil
+		}
+		data := buf[:n]
+		hdrSize := int(unsafe.Sizeof(dirent{}))
+		for len(data) >= hdrSize {
+			de := (*dirent)(unsafe.Pointer(&data[0]))
+			end := hdrSize + int(de.NameLen)
+			if end > len(data) {
+				return entries, syscall.EIO
+			}
+			if name := string(data[hdrSize:end]); name != "." && name != ".." {
+				entries = append(entries, fuse.DirEntry{
+					Name: name,
+					Ino:  de.Ino,
+					Off:  de.Off,
+					Mode: de.Typ << 12,
+				})
+			}
+			off = de.Off
+			data = data[min(len(data), (end+7)&^7):]
+		}
+	}
+}
+
+// ReadFile returns the contents of name.
+func (v *VFS) ReadFile(name string) ([]byte, error) {
+	f, err := v.Open(name, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile writes data to name, creating it with mode if necessary,
+// and truncating it otherwise.
+func (v *VFS) WriteFile(name string, data []byte, mode uint32) error {
+	f, err := v.Create(name, os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
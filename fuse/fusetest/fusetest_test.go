@@ -0,0 +1,194 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fusetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func newLoopback(t *testing.T) (*VFS, string, *fs.Inode) {
+	dir := t.TempDir()
+	root, err := fs.NewLoopbackRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &fs.Options{}
+	v, err := New(fs.NewNodeFS(root, opts), &opts.MountOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, dir, root.EmbeddedInode()
+}
+
+func TestFileOperations(t *testing.T) {
+	v, dir, _ := newLoopback(t)
+
+	if err := v.Mkdir("dir", 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := v.WriteFile("dir/file", []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "dir/file")); err != nil || string(data) != "hello" {
+		t.Fatalf("backing file: %q, %v", data, err)
+	}
+	if data, err := v.ReadFile("dir/file"); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if attr, err := v.Stat("dir/file"); err != nil || attr.Size != 5 || attr.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		t.Fatalf("Stat: %v, %v", attr, err)
+	}
+
+	if err := v.Rename("dir/file", "dir/file2", 0); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := v.Stat("dir/file"); err != syscall.ENOENT {
+		t.Fatalf("Stat old name: got %v, want ENOENT", err)
+	}
+	if err := v.Symlink("file2", "dir/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := v.Readlink("dir/link"); err != nil || target != "file2" {
+		t.Fatalf("Readlink: %q, %v", target, err)
+	}
+
+	entries, err := v.ReadDir("dir")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	got := map[string]uint32{}
+	for _, e := range entries {
+		got[e.Name] = e.Mode
+	}
+	if want := map[string]uint32{"file2": syscall.S_IFREG, "link": syscall.S_IFLNK}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir: got %v, want %v", got, want)
+	}
+
+	if err := v.Truncate("dir/file2", 2); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if data, err := v.ReadFile("dir/file2"); err != nil || string(data) != "he" {
+		t.Fatalf("ReadFile after Truncate: %q, %v", data, err)
+	}
+
+	if err := v.Rmdir("dir"); err != syscall.ENOTEMPTY {
+		t.Fatalf("Rmdir non-empty: got %v, want ENOTEMPTY", err)
+	}
+	for _, n := range []string{"dir/file2", "dir/link"} {
+		if err := v.Unlink(n); err != nil {
+			t.Fatalf("Unlink(%q): %v", n, err)
+		}
+	}
+	if err := v.Rmdir("dir"); err != nil {
+		t.Fatalf("Rmdir: %v", err)
+	}
+}
+
+func TestRenameOverwrite(t *testing.T) {
+	v, _, _ := newLoopback(t)
+	for _, n := range []string{"a", "b"} {
+		if err := v.WriteFile(n, []byte(n), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Rename("a", "b", 0); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if data, err := v.ReadFile("b"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if got := len(v.Inodes()); got != 1 {
+		t.Errorf("got %d inodes, want 1", got)
+	}
+}
+
+func TestForget(t *testing.T) {
+	v, _, root := newLoopback(t)
+	if err := v.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := v.Create("dir/file", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := v.Stat("dir/file"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(v.Inodes()); got != 2 {
+		t.Fatalf("got %d inodes, want 2", got)
+	}
+
+	// The open file keeps its entry, and that of its parent.
+	v.DropCaches()
+	if got := v.Inodes(); len(got) != 2 {
+		t.Errorf("got inodes %v after DropCaches, want 2", got)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	v.DropCaches()
+	if got := v.Inodes(); len(got) != 0 {
+		t.Errorf("got inodes %v after Close, want none", got)
+	}
+	if got := len(root.Children()); got != 0 {
+		t.Errorf("root has %d children after DropCaches, want 0", got)
+	}
+
+	// Lookups after forgetting start from scratch.
+	if attr, err := v.Stat("dir/file"); err != nil || attr.Size != 5 {
+		t.Fatalf("Stat: %v, %v", attr, err)
+	}
+	for id, n := range v.Inodes() {
+		if n != 1 {
+			t.Errorf("node %d: got nlookup %d, want 1", id, n)
+		}
+	}
+}
+
+func TestCaller(t *testing.T) {
+	var got []fuse.Caller
+	root := &callerNode{got: &got}
+	opts := &fs.Options{}
+	v, err := New(fs.NewNodeFS(root, opts), &opts.MountOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fuse.Caller{Owner: fuse.Owner{Uid: 42, Gid: 43}, Pid: 44}
+	v.SetCaller(want)
+	if _, err := v.Stat(""); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got callers %v, want %v", got, want)
+	}
+}
+
+type callerNode struct {
+	fs.Inode
+	got *[]fuse.Caller
+}
+
+var _ = (fs.NodeGetattrer)((*callerNode)(nil))
+
+func (n *callerNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if c, ok := fuse.FromContext(ctx); ok {
+		*n.got = append(*n.got, *c)
+	}
+	out.Mode = fuse.S_IFDIR | 0755
+	return 0
+}
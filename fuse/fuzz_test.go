@@ -0,0 +1,111 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"io"
+	"log"
+	"testing"
+	"unsafe"
+)
+
+// maxFuzzPayload bounds the reply payload that fuzzed requests may
+// ask for, so the fuzzer does not run out of memory.
+const maxFuzzPayload = 1 << 20
+
+// fuzzInit returns an INIT request announcing all capabilities.
+func fuzzInit() []byte {
+	in := InitIn{
+		InHeader: InHeader{
+			Length: uint32(unsafe.Sizeof(InitIn{})),
+			Opcode: _OP_INIT,
+			Unique: 1,
+		},
+		Major:        _FUSE_KERNEL_VERSION,
+		Minor:        _OUR_MINOR_VERSION,
+		MaxReadAhead: 1 << 20,
+	}
+	in.Flags = ^uint32(0)
+	in.Flags2 = ^uint32(0)
+	return append([]byte{}, unsafe.Slice((*byte)(unsafe.Pointer(&in)), unsafe.Sizeof(in))...)
+}
+
+// fuzzSeeds returns a request for every opcode with a zero input
+// struct, followed by two file names.
+func fuzzSeeds() [][]byte {
+	seeds := [][]byte{fuzzInit()}
+	for op := uint32(1); op < _OPCODE_COUNT; op++ {
+		h := getHandler(op)
+		if h == nil || op == _OP_INIT {
+			continue
+		}
+		size := int(unsafe.Sizeof(InHeader{}))
+		if int(h.InputSize) > size {
+			size = int(h.InputSize)
+		}
+		buf := make([]byte, size)
+		hdr := (*InHeader)(unsafe.Pointer(&buf[0]))
+		hdr.Opcode = op
+		hdr.Unique = 2
+		hdr.NodeId = FUSE_ROOT_ID
+		buf = append(buf, "file\x00file2\x00"...)
+		hdr.Length = uint32(len(buf))
+		seeds = append(seeds, buf)
+	}
+	return seeds
+}
+
+// fuzzRequest runs INIT and then the request in data through ps.
+// The request is split at split into two input buffers, as
+// virtiofs does.
+func fuzzRequest(t *testing.T, ps *ProtocolServer, data []byte, split uint16) {
+	initReq := fuzzInit()
+	initOut := make([]byte, unsafe.Sizeof(InitOut{}))
+	if _, st := ps.HandleRequest([][]byte{initReq}, [][]byte{make([]byte, sizeOfOutHeader), initOut}); st != OK {
+		t.Fatalf("INIT: %v", st)
+	}
+
+	h, _, outSize, outPayloadSize, st := parseRequest(data, &ps.kernelSettings)
+	if st != OK || outPayloadSize > maxFuzzPayload {
+		return
+	}
+	var out [][]byte
+	if !h.SuppressReply {
+		out = append(out, make([]byte, sizeOfOutHeader))
+		if outSize > 0 {
+			out = append(out, make([]byte, outSize))
+		}
+		if outPayloadSize > 0 {
+			out = append(out, make([]byte, outPayloadSize))
+		}
+	}
+
+	in := [][]byte{data}
+	if s := int(split); s > 0 && s < len(data) {
+		in = [][]byte{data[:s], data[s:]}
+	}
+	n, st := ps.HandleRequest(in, out)
+	if st != OK || n == 0 {
+		return
+	}
+	if n < int(sizeOfOutHeader) || n > iovLen(out) {
+		t.Fatalf("%s: reply size %d out of range", h.Name, n)
+	}
+	if got := (*OutHeader)(unsafe.Pointer(&out[0][0])).Length; int(got) != n {
+		t.Fatalf("%s: OutHeader.Length %d, want %d", h.Name, got, n)
+	}
+}
+
+func FuzzHandleRequest(f *testing.F) {
+	for _, s := range fuzzSeeds() {
+		f.Add(s, uint16(0))
+	}
+	f.Fuzz(func(t *testing.T, data []byte, split uint16) {
+		ps := NewProtocolServer(NewDefaultRawFileSystem(), &MountOptions{
+			Logger: log.New(io.Discard, "", 0),
+		})
+		fuzzRequest(t, ps, data, split)
+	})
+}
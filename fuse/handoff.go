@@ -0,0 +1,346 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// handoffWakeName is looked up by Handoff to wake up readers blocked
+// on the device. While a handoff is in progress, the lookup is
+// answered with ENOENT without consulting the file system.
+const handoffWakeName = ".go-fuse-handoff-wakeup"
+
+// maxHandoffSize bounds the size of the state that Resume accepts.
+const maxHandoffSize = 1 << 30
+
+// StateHandoffer is implemented by file systems that can carry their
+// state over to another process in a live upgrade; see
+// Server.Handoff and Resume. The fs package implements it for
+// its node table.
+type StateHandoffer interface {
+	// ExportState is called by Handoff once the server has stopped
+	// handling requests. It should return what the successor needs
+	// to interpret the node IDs and file handles the kernel holds.
+	ExportState() ([]byte, error)
+
+	// ImportState is called by Resume with the result of
+	// ExportState, after Init, and before any request is served.
+	// If it fails, the handoff fails, and the original server
+	// continues serving.
+	ImportState(data []byte) error
+}
+
+// stateHandoffer returns the StateHandoffer implemented by fs, or nil.
+func stateHandoffer(fs RawFileSystem) StateHandoffer {
+	if h, ok := fs.(StateHandoffer); ok {
+		return h
+	}
+	if r, ok := fs.(*rawFromCtx); ok {
+		if h, ok := r.fs.(StateHandoffer); ok {
+			return h
+		}
+	}
+	return nil
+}
+
+// handoffState is sent from Handoff to Resume.
+type handoffState struct {
+	MountPoint string
+
+	// InitIn is the INIT request of the kernel. It describes
+	// what the kernel supports; see Server.KernelSettings.
+	InitIn InitIn
+
+	// MaxWrite is the negotiated maximum write size, which
+	// determines the size of the read buffers.
+	MaxWrite int
+
+	RetrieveNext uint64
+
+	// FileSystem is the result of StateHandoffer.ExportState, if
+	// the file system implements it.
+	FileSystem []byte `json:",omitempty"`
+}
+
+// Handoff passes the mount to another process, so the file system
+// can be upgraded without unmounting it. It stops reading requests,
+// waits for the requests in flight to complete, and sends the FUSE
+// device and the negotiated settings over conn. If the file system
+// implements StateHandoffer, its exported state is sent along. The
+// other side of conn should call Resume, and Handoff returns once
+// Resume has accepted the mount.
+//
+// Requests that the kernel sends in the meantime are queued, and
+// served by the successor. Serve returns once the handoff has
+// succeeded; the file system's OnUnmount is not called. After a
+// successful Handoff, Unmount is a no-op, and the Server should be
+// discarded. If the state cannot be exported or the successor does
+// not accept the mount, the readers are restarted, and the Server
+// continues serving.
+//
+// Handoff must be called while Serve is running. It is not
+// supported for mounts without a known mount point (magic /dev/fd/N
+// mount points and detached mounts), with io_uring, or on platforms
+// that use a single reader.
+func (ms *Server) Handoff(conn *net.UnixConn) error {
+	if ms.singleReader {
+		return fmt.Errorf("handoff: %w", syscall.ENOSYS)
+	}
+	if ms.mountPoint == "" || parseFuseFd(ms.mountPoint) >= 0 || ms.detachedFd >= 0 {
+		return errors.New("handoff: mount point unknown")
+	}
+	if ms.ioUringNegotiated() {
+		return errors.New("handoff: not supported with io_uring")
+	}
+
+	ms.reqMu.Lock()
+	if !ms.serving || ms.handingOff {
+		ms.reqMu.Unlock()
+		return errors.New("handoff: server is not serving")
+	}
+	// Serve closes the device once the readers exit.
+	fd, err := syscall.Dup(ms.mountFd)
+	if err != nil {
+		ms.reqMu.Unlock()
+		return fmt.Errorf("handoff: %w", err)
+	}
+	defer syscall.Close(fd)
+	ms.handingOff = true
+	ms.handoffWaking.Store(true)
+	// Keep Serve from returning until we know whether the
+	// handoff succeeded.
+	ms.loops.Add(1)
+	defer ms.loops.Done()
+	ms.reqMu.Unlock()
+	ms.wakeReaders()
+
+	// The state is exported once the readers have stopped, so it
+	// covers every node ID and file handle the kernel got.
+	if err := ms.sendState(conn, fd); err != nil {
+		ms.reqMu.Lock()
+		ms.handingOff = false
+		ms.handoffWaking.Store(false)
+		for _, q := range ms.queues {
+			ms.loops.Add(1)
+			go ms.loop(q)
+		}
+		ms.reqMu.Unlock()
+		return fmt.Errorf("handoff: %w", err)
+	}
+	ms.mountPoint = ""
+	return nil
+}
+
+// sendState exports the state, sends it with fd over conn, and waits
+// for the successor to accept it.
+func (ms *Server) sendState(conn *net.UnixConn, fd int) error {
+	ms.retrieveMu.Lock()
+	retrieveNext := ms.retrieveNext
+	ms.retrieveMu.Unlock()
+	state := handoffState{
+		MountPoint:   ms.mountPoint,
+		InitIn:       ms.kernelSettings,
+		MaxWrite:     ms.opts.MaxWrite,
+		RetrieveNext: retrieveNext,
+	}
+	if h := stateHandoffer(ms.fileSystem); h != nil {
+		var err error
+		state.FileSystem, err = h.ExportState()
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	}
+	if err := sendHandoff(conn, fd, &state); err != nil {
+		return err
+	}
+	return receiveHandoffReply(conn)
+}
+
+// wakeReaders unblocks the readers waiting on the device by looking
+// up handoffWakeName, until all of them have exited. Readers that
+// wake up serve the request they read before exiting.
+//
+// Only one lookup is in flight at a time. The last one may stay
+// queued until the successor serves it, or this server if the
+// handoff fails; the goroutine that does it exits then.
+func (ms *Server) wakeReaders() {
+	name := filepath.Join(ms.mountPoint, handoffWakeName)
+	var poking atomic.Bool
+	delay := time.Millisecond
+	for {
+		ms.reqMu.Lock()
+		active := ms.activeLoops
+		ms.reqMu.Unlock()
+		if active == 0 {
+			return
+		}
+		if poking.CompareAndSwap(false, true) {
+			go func() {
+				var st syscall.Stat_t
+				syscall.Lstat(name, &st)
+				poking.Store(false)
+			}()
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// sendHandoff sends state, prefixed with its length, and fd over conn.
+func sendHandoff(conn *net.UnixConn, fd int, state *handoffState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	msg := binary.BigEndian.AppendUint64(nil, uint64(len(data)))
+	msg = append(msg, data...)
+	n, _, err := conn.WriteMsgUnix(msg, syscall.UnixRights(fd), nil)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(msg[n:])
+	return err
+}
+
+// sendHandoffReply tells Handoff whether Resume accepted the mount.
+// An empty message means it did; otherwise the message is the error.
+func sendHandoffReply(conn *net.UnixConn, err error) error {
+	var msg string
+	if err != nil {
+		msg = err.Error()
+		if msg == "" {
+			msg = "unknown error"
+		}
+	}
+	data := binary.BigEndian.AppendUint64(nil, uint64(len(msg)))
+	_, werr := conn.Write(append(data, msg...))
+	return werr
+}
+
+// receiveHandoffReply reads the message written by sendHandoffReply.
+func receiveHandoffReply(conn *net.UnixConn) error {
+	var hdr [8]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("no reply from successor: %w", err)
+	}
+	size := binary.BigEndian.Uint64(hdr[:])
+	if size == 0 {
+		return nil
+	}
+	if size > maxHandoffSize {
+		return fmt.Errorf("reply of %d bytes too large", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return fmt.Errorf("no reply from successor: %w", err)
+	}
+	return fmt.Errorf("successor: %s", msg)
+}
+
+// receiveHandoff reads the message written by sendHandoff.
+func receiveHandoff(conn *net.UnixConn) (fd int, state *handoffState, err error) {
+	var hdr [8]byte
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(hdr[:], oob)
+	if err != nil {
+		return -1, nil, err
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return -1, nil, err
+	}
+	var fds []int
+	for _, m := range msgs {
+		rights, err := syscall.ParseUnixRights(&m)
+		if err == nil {
+			fds = append(fds, rights...)
+		}
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return -1, nil, fmt.Errorf("got %d file descriptors, want 1", len(fds))
+	}
+	fd = fds[0]
+
+	if _, err = io.ReadFull(conn, hdr[n:]); err == nil {
+		size := binary.BigEndian.Uint64(hdr[:])
+		if size > maxHandoffSize {
+			err = fmt.Errorf("state of %d bytes too large", size)
+		} else {
+			data := make([]byte, size)
+			if _, err = io.ReadFull(conn, data); err == nil {
+				state = &handoffState{}
+				err = json.Unmarshal(data, state)
+			}
+		}
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return -1, nil, err
+	}
+	return fd, state, nil
+}
+
+// Resume picks up serving a mount that another process passed on
+// with Server.Handoff over conn. The options should match those of
+// the original server; the negotiated maximum write size is taken
+// over from it. If fs implements StateHandoffer and the original file
+// system exported its state, it is imported before Resume returns.
+// If Resume fails, the original server is told so, and continues
+// serving. The returned Server is ready to Serve, and can be
+// unmounted like a server returned by NewServer.
+func Resume(conn *net.UnixConn, fs RawFileSystem, opts *MountOptions) (*Server, error) {
+	fd, state, err := receiveHandoff(conn)
+	if err != nil {
+		sendHandoffReply(conn, err)
+		return nil, fmt.Errorf("resume: %w", err)
+	}
+
+	o := serverOptions(fs, opts)
+	if state.MaxWrite > 0 {
+		o.MaxWrite = state.MaxWrite
+	}
+	ms := newServer(fs, &o)
+	ms.mountPoint = state.MountPoint
+	ms.mountFd = fd
+	ms.queues = []*readQueue{{fd: fd}}
+	ms.kernelSettings = state.InitIn
+	ms.retrieveNext = state.RetrieveNext
+	if ms.kernelSettings.Minor >= 13 {
+		ms.setSplice()
+	}
+	ms.fileSystem.Init(ms)
+	if h := stateHandoffer(fs); h != nil && state.FileSystem != nil {
+		if err := h.ImportState(state.FileSystem); err != nil {
+			syscall.Close(fd)
+			sendHandoffReply(conn, fmt.Errorf("import: %w", err))
+			return nil, fmt.Errorf("resume: import: %w", err)
+		}
+	}
+	// If the reply is lost, the original server resumes serving.
+	if err := sendHandoffReply(conn, nil); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("resume: %w", err)
+	}
+	ms.cloneQueues()
+	ms.ready <- nil
+
+	ms.loops.Add(1)
+	return ms, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+// RequestInfo describes a request to an Interceptor. It, and the
+// data it points to, may only be used during the call to the
+// Interceptor.
+type RequestInfo struct {
+	// Opcode is the FUSE opcode of the request, and Name its
+	// name, for example "LOOKUP".
+	Opcode uint32
+	Name   string
+
+	Header *InHeader
+
+	// Input is the decoded input, a pointer to the input struct
+	// of the opcode, for example *GetAttrIn. It is Header if the
+	// opcode has no other input.
+	Input interface{}
+
+	// Names holds the file names passed with the request, for
+	// example the name to look up, or the old and new name of a
+	// rename.
+	Names []string
+
+	// Output is a pointer to the output struct of the opcode, for
+	// example *EntryOut. It is filled in once the handler returns
+	// OK, and is nil for opcodes without structured output.
+	Output interface{}
+}
+
+// Interceptor is called around the handling of each request. It
+// should call handler at most once to serve the request, and return
+// its status. It may also change the status, or return an error
+// without calling handler, for example to deny access.
+//
+// Interceptors also see requests for opcodes that the file system
+// does not implement, for which handler returns ENOSYS, and requests
+// that are refused during Server.Shutdown, for which it returns
+// ENOTCONN. They do not see malformed requests, which are answered
+// with EINVAL, nor the lookups that the server answers itself: those
+// of the poll hack (see MountOptions.EnablePoll) and of
+// Server.Handoff.
+type Interceptor func(info *RequestInfo, handler func() Status) Status
+
+// intercept runs the handler for the opcode through the interceptors
+// in MountOptions.Interceptors.
+func (ms *protocolServer) intercept(h *operationHandler, req *request) {
+	// Interceptors may panic too.
+	if ms.opts.RecoverPanics {
+		defer ms.recoverPanic(req)
+	}
+	hdr := req.inHeader()
+	info := &RequestInfo{
+		Opcode: hdr.Opcode,
+		Name:   h.Name,
+		Header: hdr,
+		Input:  hdr,
+	}
+	if h.InType != nil {
+		info.Input = asType(req.inData(), h.InType)
+	}
+	if h.OutType != nil && len(req.outDataBuf) > 0 {
+		info.Output = asType(req.outData(), h.OutType)
+	}
+	switch h.FileNames {
+	case 1:
+		info.Names = []string{req.filename()}
+	case 2:
+		n1, n2 := req.filenames()
+		info.Names = []string{n1, n2}
+	}
+
+	call := func() Status {
+		ms.serve(h, req)
+		return req.status
+	}
+	for i := len(ms.opts.Interceptors) - 1; i >= 0; i-- {
+		ic, next := ms.opts.Interceptors[i], call
+		call = func() Status {
+			return ic(info, next)
+		}
+	}
+	req.status = call()
+}
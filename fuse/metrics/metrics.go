@@ -0,0 +1,158 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics exports the request statistics collected by
+// fuse.Stats over HTTP, in the Prometheus text exposition format.
+//
+//	stats := fuse.NewStats()
+//	opts := &fs.Options{}
+//	opts.Stats = stats
+//	server, err := fs.Mount(dir, root, opts)
+//	...
+//	h := metrics.NewHandler()
+//	h.Add(dir, stats)
+//	http.Handle("/metrics", h)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// Handler serves the statistics of a set of mounts. Each sample has
+// a "mountpoint" label.
+type Handler struct {
+	mu     sync.Mutex
+	mounts map[string]*fuse.Stats
+}
+
+// NewHandler returns a Handler without mounts.
+func NewHandler() *Handler {
+	return &Handler{mounts: make(map[string]*fuse.Stats)}
+}
+
+// Add exports the statistics of the mount at mountpoint.
+func (h *Handler) Add(mountpoint string, s *fuse.Stats) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mounts[mountpoint] = s
+}
+
+// Remove stops exporting the statistics of mountpoint.
+func (h *Handler) Remove(mountpoint string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.mounts, mountpoint)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteText(w)
+}
+
+type mountSnapshot struct {
+	mountpoint string
+	fuse.StatsSnapshot
+}
+
+// WriteText writes the statistics of all mounts to w.
+func (h *Handler) WriteText(w io.Writer) error {
+	h.mu.Lock()
+	var snaps []mountSnapshot
+	for mnt, s := range h.mounts {
+		snaps = append(snaps, mountSnapshot{mnt, s.Snapshot()})
+	}
+	h.mu.Unlock()
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].mountpoint < snaps[j].mountpoint
+	})
+
+	b := bufio.NewWriter(w)
+	header(b, "fuse_request_duration_seconds", "histogram", "Time spent serving FUSE requests.")
+	for _, s := range snaps {
+		mnt := label("mountpoint", s.mountpoint)
+		for _, op := range sortedKeys(s.Ops) {
+			o := s.Ops[op]
+			labels := mnt + "," + label("op", op)
+			var cum uint64
+			for i, n := range o.Buckets {
+				cum += n
+				le := "+Inf"
+				if i < len(s.Bounds) {
+					le = strconv.FormatFloat(s.Bounds[i].Seconds(), 'g', -1, 64)
+				}
+				fmt.Fprintf(b, "fuse_request_duration_seconds_bucket{%s,%s} %d\n", labels, label("le", le), cum)
+			}
+			fmt.Fprintf(b, "fuse_request_duration_seconds_sum{%s} %g\n", labels, o.Sum.Seconds())
+			fmt.Fprintf(b, "fuse_request_duration_seconds_count{%s} %d\n", labels, o.Count)
+		}
+	}
+
+	header(b, "fuse_requests_in_flight", "gauge", "Number of FUSE requests being served.")
+	for _, s := range snaps {
+		mnt := label("mountpoint", s.mountpoint)
+		for _, op := range sortedKeys(s.Ops) {
+			fmt.Fprintf(b, "fuse_requests_in_flight{%s,%s} %d\n", mnt, label("op", op), s.Ops[op].InFlight)
+		}
+	}
+
+	header(b, "fuse_request_errors_total", "counter", "Number of FUSE requests that failed, by errno.")
+	for _, s := range snaps {
+		mnt := label("mountpoint", s.mountpoint)
+		codes := make([]fuse.Status, 0, len(s.Errors))
+		for code := range s.Errors {
+			codes = append(codes, code)
+		}
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+		for _, code := range codes {
+			fmt.Fprintf(b, "fuse_request_errors_total{%s,%s} %d\n", mnt, label("errno", errnoName(code)), s.Errors[code])
+		}
+	}
+
+	header(b, "fuse_read_bytes_total", "counter", "Number of bytes returned by READ requests.")
+	for _, s := range snaps {
+		fmt.Fprintf(b, "fuse_read_bytes_total{%s} %d\n", label("mountpoint", s.mountpoint), s.BytesRead)
+	}
+	header(b, "fuse_written_bytes_total", "counter", "Number of bytes accepted by WRITE requests.")
+	for _, s := range snaps {
+		fmt.Fprintf(b, "fuse_written_bytes_total{%s} %d\n", label("mountpoint", s.mountpoint), s.BytesWritten)
+	}
+	return b.Flush()
+}
+
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func errnoName(code fuse.Status) string {
+	if name := unix.ErrnoName(syscall.Errno(code)); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
+
+func sortedKeys(m map[string]fuse.OpStats) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
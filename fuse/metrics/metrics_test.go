@@ -0,0 +1,66 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestHandler(t *testing.T) {
+	stats := fuse.NewStats()
+	root := &fs.Inode{}
+	opts := &fs.Options{
+		OnAdd: func(ctx context.Context) {
+			root.AddChild("file", root.NewPersistentInode(ctx, &fs.MemRegularFile{
+				Data: []byte("hello"),
+			}, fs.StableAttr{}), false)
+		},
+	}
+	opts.Stats = stats
+	dir := t.TempDir()
+	server, err := fs.Mount(dir, root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Unmount()
+
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want ENOENT", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "file")); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	h := NewHandler()
+	h.Add(dir, stats)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	text := string(body)
+
+	mnt := `mountpoint="` + dir + `"`
+	for _, want := range []string{
+		"# TYPE fuse_request_duration_seconds histogram\n",
+		`fuse_request_duration_seconds_bucket{` + mnt + `,op="LOOKUP",le="+Inf"} `,
+		`fuse_request_duration_seconds_count{` + mnt + `,op="READ"} `,
+		`fuse_request_errors_total{` + mnt + `,errno="ENOENT"} 1` + "\n",
+		`fuse_read_bytes_total{` + mnt + `} 5` + "\n",
+		`fuse_written_bytes_total{` + mnt + `} 0` + "\n",
+		`fuse_requests_in_flight{` + mnt + `,op="READ"} 0` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output misses %q:\n%s", want, text)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package fuse
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,6 +14,10 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse/mountbroker"
+	"github.com/hanwen/go-fuse/v2/internal/fsmount"
+	"golang.org/x/sys/unix"
 )
 
 func unixgramSocketpair() (l, r *os.File, err error) {
@@ -27,30 +32,69 @@ func unixgramSocketpair() (l, r *os.File, err error) {
 }
 
 // Create a FUSE FS on the specified mount point without using
-// fusermount.
+// fusermount. This uses the new mount API if the kernel supports it,
+// and mount(2) otherwise.
 func mountDirect(mountPoint string, opts *MountOptions, ready chan<- error) (fd int, err error) {
 	fd, err = syscall.Open("/dev/fuse", os.O_RDWR, 0) // use syscall.Open since we want an int fd
 	if err != nil {
 		return
 	}
 
+	var st syscall.Stat_t
+	err = syscall.Stat(mountPoint, &st)
+	if err != nil {
+		syscall.Close(fd)
+		return
+	}
+
+	mntFd, err := fsmountFuse(fd, st.Mode&syscall.S_IFMT, opts)
+	if err == nil {
+		err = unix.MoveMount(mntFd, "", unix.AT_FDCWD, mountPoint, unix.MOVE_MOUNT_F_EMPTY_PATH)
+		syscall.Close(mntFd)
+		if err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("move_mount: %w", err)
+		}
+		close(ready)
+		return fd, nil
+	}
+	if !errors.Is(err, fsmount.ErrUnavailable) {
+		syscall.Close(fd)
+		return -1, err
+	}
+	if opts.Debug {
+		opts.Logger.Printf("mountDirect: %v, falling back to mount(2)", err)
+	}
+
 	// managed to open dev/fuse, attempt to mount
 	source := opts.FsName
 	if source == "" {
 		source = opts.Name
 	}
-
-	var flags uintptr = syscall.MS_NOSUID | syscall.MS_NODEV
-	if opts.DirectMountFlags != 0 {
-		flags = opts.DirectMountFlags
+	flags, r := directMountParams(fd, st.Mode&syscall.S_IFMT, opts)
+	if opts.Debug {
+		opts.Logger.Printf("mountDirect: calling syscall.Mount(%q, %q, %q, %#x, %q)",
+			source, mountPoint, "fuse."+opts.Name, flags, strings.Join(r, ","))
 	}
-
-	var st syscall.Stat_t
-	err = syscall.Stat(mountPoint, &st)
+	err = syscall.Mount(source, mountPoint, "fuse."+opts.Name, flags, strings.Join(r, ","))
 	if err != nil {
+		syscall.Close(fd)
 		return
 	}
 
+	// success
+	close(ready)
+	return
+}
+
+// directMountParams returns the mount flags and the file system
+// parameters for mounting the FUSE device fd directly.
+func directMountParams(fd int, rootMode uint32, opts *MountOptions) (flags uintptr, r []string) {
+	flags = syscall.MS_NOSUID | syscall.MS_NODEV
+	if opts.DirectMountFlags != 0 {
+		flags = opts.DirectMountFlags
+	}
+
 	// some values we need to pass to mount - we do as fusermount does.
 	// override possible since opts.Options comes after.
 	//
@@ -60,9 +104,9 @@ func mountDirect(mountPoint string, opts *MountOptions, ready chan<- error) (fd
 	// https://elixir.bootlin.com/linux/v6.14.2/source/fs/fs_context.c#L50
 	// Everything else will cause an EINVAL error from syscall.Mount() and
 	// a corresponding error message in the kernel logs.
-	var r = []string{
+	r = []string{
 		fmt.Sprintf("fd=%d", fd),
-		fmt.Sprintf("rootmode=%o", st.Mode&syscall.S_IFMT),
+		fmt.Sprintf("rootmode=%o", rootMode),
 		fmt.Sprintf("user_id=%d", os.Geteuid()),
 		fmt.Sprintf("group_id=%d", os.Getegid()),
 		// match what we do with fusermount
@@ -96,20 +140,7 @@ func mountDirect(mountPoint string, opts *MountOptions, ready chan<- error) (fd
 	if opts.IDMappedMount && !opts.containsOption("default_permissions") {
 		r = append(r, "default_permissions")
 	}
-
-	if opts.Debug {
-		opts.Logger.Printf("mountDirect: calling syscall.Mount(%q, %q, %q, %#x, %q)",
-			source, mountPoint, "fuse."+opts.Name, flags, strings.Join(r, ","))
-	}
-	err = syscall.Mount(source, mountPoint, "fuse."+opts.Name, flags, strings.Join(r, ","))
-	if err != nil {
-		syscall.Close(fd)
-		return
-	}
-
-	// success
-	close(ready)
-	return
+	return flags, r
 }
 
 // callFusermount calls the `fusermount` suid helper with the right options so
@@ -188,6 +219,14 @@ func mount(mountPoint string, opts *MountOptions, ready chan<- error) (fd int, e
 		if opts.Debug {
 			opts.Logger.Printf("mount: magic mountpoint %q, using fd %d", mountPoint, fd)
 		}
+	} else if opts.MountBroker != "" {
+		if opts.Debug {
+			opts.Logger.Printf("mount: asking mount broker %q", opts.MountBroker)
+		}
+		fd, err = mountbroker.Mount(opts.MountBroker, mountPoint, opts.optionsList())
+		if err != nil {
+			return
+		}
 	} else {
 		// Usual case: mount via the `fusermount` suid helper
 		fd, err = callFusermount(mountPoint, opts)
@@ -204,17 +243,24 @@ func mount(mountPoint string, opts *MountOptions, ready chan<- error) (fd int, e
 }
 
 func unmount(mountPoint string, opts *MountOptions) (err error) {
-	if opts.DirectMount || opts.DirectMountStrict {
+	if opts.DirectMount || opts.DirectMountStrict || opts.DetachedMount {
 		// Attempt to directly unmount, if fails fallback to fusermount method
 		err := syscall.Unmount(mountPoint, 0)
 		if err == nil {
 			return nil
 		}
-		if opts.DirectMountStrict {
+		if opts.DirectMountStrict || opts.DetachedMount {
 			return err
 		}
 	}
 
+	if opts.MountBroker != "" {
+		if opts.Debug {
+			opts.Logger.Printf("unmount: asking mount broker %q", opts.MountBroker)
+		}
+		return mountbroker.Unmount(opts.MountBroker, mountPoint)
+	}
+
 	bin, err := fusermountBinary()
 	if err != nil {
 		return err
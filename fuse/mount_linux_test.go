@@ -1,13 +1,20 @@
 package fuse
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"testing"
 
+	"github.com/hanwen/go-fuse/v2/fuse/mountbroker"
 	"github.com/hanwen/go-fuse/v2/internal/testutil"
 	"github.com/moby/sys/mountinfo"
+	"golang.org/x/sys/unix"
 )
 
 // TestMountDevFd tests the special `/dev/fd/N` mountpoint syntax, where a
@@ -261,3 +268,319 @@ func TestEscapedMountOption(t *testing.T) {
 		t.Errorf("mountinfo(%q): got %q want %q", mnt, m.Source, fsname)
 	}
 }
+
+// skipNoMountAPI skips tests that need the new mount API.
+func skipNoMountAPI(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("this test requires root permissions")
+	}
+	fd, err := unix.Fsopen("fuse", unix.FSOPEN_CLOEXEC)
+	if err != nil {
+		t.Skipf("fsopen: %v", err)
+	}
+	unix.Close(fd)
+}
+
+// TestDirectMountOptionError checks that the new mount API reports
+// the rejected option.
+func TestDirectMountOptionError(t *testing.T) {
+	skipNoMountAPI(t)
+	opts := &MountOptions{
+		DirectMountStrict: true,
+		Options:           []string{"bogus_option"},
+	}
+	_, err := NewServer(NewDefaultRawFileSystem(), t.TempDir(), opts)
+	if err == nil {
+		t.Fatal("NewServer succeeded")
+	}
+	if !strings.Contains(err.Error(), "bogus_option") {
+		t.Errorf("error %q does not name the option", err)
+	}
+}
+
+func TestDetachedMount(t *testing.T) {
+	skipNoMountAPI(t)
+	opts := &MountOptions{
+		DetachedMount: true,
+		Debug:         testutil.VerboseTest(),
+	}
+	srv, err := NewServer(NewDefaultRawFileSystem(), "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	if err := srv.WaitMount(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.DetachedMountFd() < 0 {
+		t.Fatal("no detached mount fd")
+	}
+
+	mnt := t.TempDir()
+	var st syscall.Stat_t
+	if err := syscall.Stat(mnt, &st); err != nil {
+		t.Fatalf("Stat before attaching: %v", err)
+	}
+	if err := srv.AttachMount(mnt); err != nil {
+		t.Fatalf("AttachMount: %v", err)
+	}
+	// The default file system does not implement GetAttr.
+	if err := syscall.Stat(mnt, &st); err != syscall.ENOSYS {
+		t.Errorf("Stat after attaching: got %v, want ENOSYS", err)
+	}
+	if err := srv.Unmount(); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+	if err := syscall.Stat(mnt, &st); err != nil {
+		t.Errorf("Stat after Unmount: %v", err)
+	}
+}
+
+// TestDetachedMountUnmount checks that a mount that was never
+// attached can be unmounted.
+func TestDetachedMountUnmount(t *testing.T) {
+	skipNoMountAPI(t)
+	srv, err := NewServer(NewDefaultRawFileSystem(), "", &MountOptions{DetachedMount: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.Serve()
+		close(done)
+	}()
+	if err := srv.Unmount(); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+	<-done
+}
+
+// TestDetachedMountReferenced checks that Unmount does not hang if
+// the detached mount is still referenced elsewhere.
+func TestDetachedMountReferenced(t *testing.T) {
+	skipNoMountAPI(t)
+	srv, err := NewServer(NewDefaultRawFileSystem(), "", &MountOptions{DetachedMount: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.Serve()
+		close(done)
+	}()
+	dup, err := syscall.Dup(srv.DetachedMountFd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Unmount(); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("Unmount with a reference: got %v, want EBUSY", err)
+	}
+	syscall.Close(dup)
+	if err := srv.Unmount(); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+	<-done
+}
+
+// TestAttachMountNamespace checks that Unmount refuses to unmount a
+// mount that was attached in another mount namespace.
+func TestAttachMountNamespace(t *testing.T) {
+	skipNoMountAPI(t)
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to create a mount namespace")
+	}
+	srv, err := NewServer(NewDefaultRawFileSystem(), "", &MountOptions{DetachedMount: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.Serve()
+		close(done)
+	}()
+
+	// In this namespace, another file system is mounted at the
+	// mount point, which Unmount should leave alone.
+	mnt := t.TempDir()
+	if err := syscall.Mount("tmpfs", mnt, "tmpfs", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Unmount(mnt, syscall.MNT_DETACH) })
+	var want syscall.Statfs_t
+	if err := syscall.Statfs(mnt, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	attached := make(chan error)
+	release := make(chan struct{})
+	go func() {
+		// The goroutine may run on the main thread, which is
+		// never discarded, so the namespace is restored before
+		// the thread is unlocked.
+		runtime.LockOSThread()
+		orig, err := os.Open("/proc/thread-self/ns/mnt")
+		if err != nil {
+			attached <- err
+			return
+		}
+		defer orig.Close()
+		if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+			attached <- err
+			return
+		}
+		err = syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+		if err == nil {
+			err = srv.AttachMount(mnt)
+		}
+		attached <- err
+		<-release
+		syscall.Unmount(mnt, syscall.MNT_DETACH)
+		if unix.Setns(int(orig.Fd()), syscall.CLONE_NEWNS) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	if err := <-attached; err != nil {
+		close(release)
+		srv.Unmount()
+		<-done
+		t.Fatalf("AttachMount in new namespace: %v", err)
+	}
+
+	if err := srv.Unmount(); err == nil {
+		t.Errorf("Unmount from another namespace succeeded")
+	}
+	var got syscall.Statfs_t
+	if err := syscall.Statfs(mnt, &got); err != nil {
+		t.Errorf("Statfs: %v", err)
+	} else if got.Type != want.Type {
+		t.Errorf("Unmount removed the tmpfs in this namespace")
+	}
+	close(release)
+	<-done
+}
+
+func TestMountBroker(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("the mount broker needs root permissions")
+	}
+	sock := filepath.Join(t.TempDir(), "broker.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&mountbroker.Broker{}).Serve(l)
+
+	mnt := t.TempDir()
+	srv, err := NewServer(NewDefaultRawFileSystem(), mnt, &MountOptions{
+		MountBroker: sock,
+		Debug:       testutil.VerboseTest(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	if err := srv.WaitMount(); err != nil {
+		t.Fatal(err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(mnt, &st); err != syscall.ENOSYS {
+		t.Errorf("Stat: got %v, want ENOSYS", err)
+	}
+	if err := srv.Unmount(); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+}
+
+// findMount returns the FUSE mount at mnt.
+func findMount(t *testing.T, mnt string) *MountInfo {
+	t.Helper()
+	mounts, err := ListMounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range mounts {
+		if mounts[i].MountPoint == mnt {
+			return &mounts[i]
+		}
+	}
+	t.Fatalf("mount %q not in %v", mnt, mounts)
+	return nil
+}
+
+func TestListMounts(t *testing.T) {
+	mnt := t.TempDir()
+	srv, err := NewServer(NewDefaultRawFileSystem(), mnt, &MountOptions{
+		FsName: "listmounts",
+		Name:   "test",
+		Debug:  testutil.VerboseTest(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	if err := srv.WaitMount(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Unmount()
+
+	m := findMount(t, mnt)
+	if m.Name != "test" || m.FsName != "listmounts" {
+		t.Errorf("got Name %q, FsName %q, want test, listmounts", m.Name, m.FsName)
+	}
+	c, err := m.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Waiting(); err != nil {
+		t.Errorf("Waiting: %v", err)
+	}
+}
+
+func TestCleanStaleMount(t *testing.T) {
+	mnt := t.TempDir()
+	opts := &MountOptions{
+		Debug: testutil.VerboseTest(),
+	}
+	srv, err := NewServer(NewDefaultRawFileSystem(), mnt, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	if err := srv.WaitMount(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash of the server.
+	c, err := findMount(t, mnt).Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Wait()
+	var st syscall.Stat_t
+	if err := syscall.Stat(mnt, &st); err != syscall.ENOTCONN {
+		t.Fatalf("Stat on dead mount: got %v, want ENOTCONN", err)
+	}
+
+	if srv, err := NewServer(NewDefaultRawFileSystem(), mnt, opts); err == nil {
+		srv.Unmount()
+		t.Fatal("NewServer on dead mount succeeded without CleanStaleMount")
+	}
+
+	opts.CleanStaleMount = true
+	srv, err = NewServer(NewDefaultRawFileSystem(), mnt, opts)
+	if err != nil {
+		t.Fatalf("NewServer with CleanStaleMount: %v", err)
+	}
+	go srv.Serve()
+	if err := srv.WaitMount(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Unmount()
+	if err := syscall.Stat(mnt, &st); err != syscall.ENOSYS {
+		t.Errorf("Stat: got %v, want ENOSYS", err)
+	}
+}
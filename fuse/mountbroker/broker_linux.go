@@ -0,0 +1,524 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mountbroker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/internal/fsmount"
+	"github.com/moby/sys/mountinfo"
+	"golang.org/x/sys/unix"
+)
+
+// maxRequestSize bounds the size of requests that the broker reads.
+const maxRequestSize = 1 << 16
+
+// MountRequest describes a mount request that the broker has parsed.
+type MountRequest struct {
+	// UID, GID and PID identify the client process.
+	UID uint32
+	GID uint32
+	PID int32
+
+	// MountPoint is the mount point, with symlinks resolved in
+	// the mount namespace of the client.
+	MountPoint string
+
+	// Options are the mount options.
+	Options []string
+}
+
+// Policy decides which mount requests the broker accepts. The zero
+// Policy lets any user mount on directories and files they own,
+// without allow_other, dev or suid.
+type Policy struct {
+	// AllowedUIDs, if non-nil, lists the users that may use the
+	// broker.
+	AllowedUIDs []uint32
+
+	// MountPrefixes, if non-empty, restricts mount points to
+	// these directories and their descendants.
+	MountPrefixes []string
+
+	// AllowOther permits the allow_other option, like
+	// user_allow_other in /etc/fuse.conf does for fusermount.
+	AllowOther bool
+
+	// Check, if set, is called for mount requests that pass the
+	// other checks. A non-nil error rejects the request.
+	Check func(req *MountRequest) error
+}
+
+func (p *Policy) allowUser(uid uint32) error {
+	if p.AllowedUIDs == nil {
+		return nil
+	}
+	for _, u := range p.AllowedUIDs {
+		if u == uid {
+			return nil
+		}
+	}
+	return fmt.Errorf("user %d may not mount: %w", uid, syscall.EPERM)
+}
+
+func (p *Policy) allowMountPoint(mountPoint string) error {
+	if len(p.MountPrefixes) == 0 {
+		return nil
+	}
+	for _, prefix := range p.MountPrefixes {
+		prefix = filepath.Clean(prefix)
+		if prefix == "/" || mountPoint == prefix || strings.HasPrefix(mountPoint, prefix+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("mount point %q outside of allowed prefixes: %w", mountPoint, syscall.EPERM)
+}
+
+// Broker mounts and unmounts FUSE file systems for its clients. It
+// must run with CAP_SYS_ADMIN, and access to /dev/fuse.
+type Broker struct {
+	Policy Policy
+
+	// Logger, if set, logs each request and its outcome.
+	Logger *log.Logger
+}
+
+// Serve accepts connections on l, and handles each of them in its
+// own goroutine. It returns when Accept fails, for example because l
+// was closed.
+func (b *Broker) Serve(l *net.UnixListener) error {
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			return err
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *Broker) handle(conn *net.UnixConn) {
+	defer conn.Close()
+
+	fd := -1
+	cred, pidfd, err := peerCred(conn)
+	if pidfd >= 0 {
+		defer syscall.Close(pidfd)
+	}
+	var req request
+	if err == nil {
+		// Read up to EOF: closing the connection with unread
+		// data would reset it, and lose the response.
+		var data []byte
+		data, err = io.ReadAll(io.LimitReader(conn, maxRequestSize))
+		if err == nil {
+			err = json.Unmarshal(data, &req)
+		}
+	}
+	if err == nil {
+		switch req.Op {
+		case opMount:
+			fd, err = b.mount(cred, pidfd, &req)
+		case opUnmount:
+			err = b.unmount(cred, pidfd, &req)
+		default:
+			err = fmt.Errorf("unknown operation %q: %w", req.Op, syscall.EINVAL)
+		}
+	}
+	if b.Logger != nil {
+		var uid uint32
+		if cred != nil {
+			uid = cred.Uid
+		}
+		b.Logger.Printf("%s %q for uid %d: %v", req.Op, req.MountPoint, uid, err)
+	}
+
+	data, _ := json.Marshal(newResponse(err))
+	var oob []byte
+	if fd >= 0 {
+		oob = syscall.UnixRights(fd)
+		defer syscall.Close(fd)
+	}
+	conn.WriteMsgUnix(data, oob, nil)
+}
+
+// peerCred returns the credentials of the process at the other end
+// of conn, and a pidfd for it. Unlike the PID, the pidfd cannot
+// refer to another process if the client exits. It is -1 if the
+// kernel does not support SO_PEERPIDFD.
+func peerCred(conn *net.UnixConn) (*unix.Ucred, int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, -1, err
+	}
+	var cred *unix.Ucred
+	pidfd := -1
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+		if credErr != nil {
+			return
+		}
+		pidfd, credErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PEERPIDFD)
+		if credErr == unix.ENOPROTOOPT {
+			pidfd, credErr = -1, nil
+		}
+	}); err != nil {
+		return nil, -1, err
+	}
+	if credErr != nil {
+		return nil, -1, credErr
+	}
+	return cred, pidfd, nil
+}
+
+func (b *Broker) mount(cred *unix.Ucred, pidfd int, req *request) (int, error) {
+	if err := b.Policy.allowUser(cred.Uid); err != nil {
+		return -1, err
+	}
+	if !filepath.IsAbs(req.MountPoint) {
+		return -1, fmt.Errorf("mount point %q is not absolute: %w", req.MountPoint, syscall.EINVAL)
+	}
+	opts, err := parseOptions(req.Options, cred.Uid, b.Policy.AllowOther)
+	if err != nil {
+		return -1, err
+	}
+
+	// Open the device in our own namespace: the client's
+	// namespace may not have it.
+	fd, err := syscall.Open("/dev/fuse", os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	err = inMountNamespace(int(cred.Pid), pidfd, func() error {
+		mountPoint, err := filepath.EvalSymlinks(req.MountPoint)
+		if err != nil {
+			return err
+		}
+		if err := b.Policy.allowMountPoint(mountPoint); err != nil {
+			return err
+		}
+		// Check and mount on the file that was opened, so the
+		// client cannot swap in another one in between.
+		dirFd, err := openNoFollow(mountPoint)
+		if err != nil {
+			return err
+		}
+		defer syscall.Close(dirFd)
+		var st syscall.Stat_t
+		if err := syscall.Fstat(dirFd, &st); err != nil {
+			return err
+		}
+		if t := st.Mode & syscall.S_IFMT; t != syscall.S_IFDIR && t != syscall.S_IFREG {
+			return fmt.Errorf("mount point %q is not a directory or file: %w", mountPoint, syscall.EINVAL)
+		}
+		if cred.Uid != 0 && st.Uid != cred.Uid {
+			return fmt.Errorf("mount point %q not owned by user %d: %w", mountPoint, cred.Uid, syscall.EPERM)
+		}
+		if b.Policy.Check != nil {
+			if err := b.Policy.Check(&MountRequest{
+				UID:        cred.Uid,
+				GID:        cred.Gid,
+				PID:        cred.Pid,
+				MountPoint: mountPoint,
+				Options:    req.Options,
+			}); err != nil {
+				return err
+			}
+		}
+
+		data := append([]string{
+			fmt.Sprintf("fd=%d", fd),
+			fmt.Sprintf("rootmode=%o", st.Mode&syscall.S_IFMT),
+			fmt.Sprintf("user_id=%d", cred.Uid),
+			fmt.Sprintf("group_id=%d", cred.Gid),
+		}, opts.data...)
+		return mountAt(dirFd, opts, data)
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// openNoFollow opens path with O_PATH, without following symlinks in
+// any of its components.
+func openNoFollow(path string) (int, error) {
+	fd, err := syscall.Open("/", unix.O_PATH|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		next, err := unix.Openat(fd, name, unix.O_PATH|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+		syscall.Close(fd)
+		if err != nil {
+			return -1, fmt.Errorf("open %q: %w", path, err)
+		}
+		fd = next
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil || st.Mode&syscall.S_IFMT == syscall.S_IFLNK {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("mount point %q is a symlink: %w", path, syscall.ELOOP)
+	}
+	return fd, nil
+}
+
+// mountAt mounts the file system on the file opened at dirFd. It
+// uses fsmount(2) and move_mount(2), and falls back to mount(2) on
+// the file's /proc/thread-self/fd entry on kernels without them.
+func mountAt(dirFd int, o *mountOptions, data []string) error {
+	attrs, sbFlags, ok := fsmount.Attrs(o.flags)
+	mntFd, err := -1, fsmount.ErrUnavailable
+	if ok {
+		mntFd, err = fsmount.Mount(o.source, o.subtype, append(sbFlags, data...), attrs)
+	}
+	if errors.Is(err, fsmount.ErrUnavailable) {
+		target := fmt.Sprintf("/proc/thread-self/fd/%d", dirFd)
+		return syscall.Mount(o.source, target, o.fsType, o.flags, strings.Join(data, ","))
+	}
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(mntFd)
+	if err := unix.MoveMount(mntFd, "", dirFd, "", unix.MOVE_MOUNT_F_EMPTY_PATH|unix.MOVE_MOUNT_T_EMPTY_PATH); err != nil {
+		return fmt.Errorf("move_mount: %w", err)
+	}
+	return nil
+}
+
+func (b *Broker) unmount(cred *unix.Ucred, pidfd int, req *request) error {
+	if err := b.Policy.allowUser(cred.Uid); err != nil {
+		return err
+	}
+	mountPoint := filepath.Clean(req.MountPoint)
+	if !filepath.IsAbs(mountPoint) {
+		return fmt.Errorf("mount point %q is not absolute: %w", req.MountPoint, syscall.EINVAL)
+	}
+	return inMountNamespace(int(cred.Pid), pidfd, func() error {
+		// Do not stat the mount point: the file system may be
+		// hung.
+		m, err := findMount(mountPoint)
+		if err != nil {
+			return err
+		}
+		if cred.Uid != 0 && !hasOption(m, fmt.Sprintf("user_id=%d", cred.Uid)) {
+			return fmt.Errorf("%q not mounted by user %d: %w", mountPoint, cred.Uid, syscall.EPERM)
+		}
+		// Like fusermount, do not follow a symlink that was
+		// put in place after the check.
+		return syscall.Unmount(mountPoint, unix.UMOUNT_NOFOLLOW)
+	})
+}
+
+// mountOptions are the parsed options of a mount request.
+type mountOptions struct {
+	flags   uintptr
+	source  string
+	fsType  string
+	subtype string
+
+	// data holds the options that are passed to the kernel
+	// driver.
+	data []string
+}
+
+// parseOptions parses the mount options of a request by user uid.
+// Like fusermount, it only accepts known options, and always sets
+// nosuid and nodev for users other than root.
+func parseOptions(options []string, uid uint32, allowOther bool) (*mountOptions, error) {
+	o := &mountOptions{
+		flags: syscall.MS_NOSUID | syscall.MS_NODEV,
+	}
+	flags := map[string]struct {
+		set, clear uintptr
+	}{
+		"ro":          {set: syscall.MS_RDONLY},
+		"rw":          {clear: syscall.MS_RDONLY},
+		"nosuid":      {set: syscall.MS_NOSUID},
+		"nodev":       {set: syscall.MS_NODEV},
+		"noexec":      {set: syscall.MS_NOEXEC},
+		"exec":        {clear: syscall.MS_NOEXEC},
+		"sync":        {set: syscall.MS_SYNCHRONOUS},
+		"async":       {clear: syscall.MS_SYNCHRONOUS},
+		"dirsync":     {set: syscall.MS_DIRSYNC},
+		"noatime":     {set: syscall.MS_NOATIME},
+		"atime":       {clear: syscall.MS_NOATIME},
+		"nodiratime":  {set: syscall.MS_NODIRATIME},
+		"diratime":    {clear: syscall.MS_NODIRATIME},
+		"relatime":    {set: syscall.MS_RELATIME},
+		"norelatime":  {clear: syscall.MS_RELATIME},
+		"strictatime": {set: syscall.MS_STRICTATIME},
+	}
+	for _, opt := range options {
+		// The options are joined with commas for the kernel.
+		if strings.Contains(opt, ",") {
+			return nil, fmt.Errorf("option %q: %w", opt, syscall.EINVAL)
+		}
+		key, value, hasValue := strings.Cut(opt, "=")
+		if f, ok := flags[opt]; ok {
+			o.flags = o.flags&^f.clear | f.set
+			continue
+		}
+		switch key {
+		case "suid", "dev", "allow_other", "default_permissions":
+			if hasValue {
+				return nil, fmt.Errorf("option %q takes no value: %w", opt, syscall.EINVAL)
+			}
+		}
+		switch key {
+		case "suid", "dev":
+			if uid != 0 {
+				return nil, fmt.Errorf("option %q: %w", opt, syscall.EPERM)
+			}
+			if key == "suid" {
+				o.flags &^= syscall.MS_NOSUID
+			} else {
+				o.flags &^= syscall.MS_NODEV
+			}
+		case "fsname":
+			o.source = value
+		case "subtype":
+			o.subtype = value
+		case "max_read":
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+				return nil, fmt.Errorf("option %q: %w", opt, syscall.EINVAL)
+			}
+			o.data = append(o.data, opt)
+		case "allow_other":
+			if uid != 0 && !allowOther {
+				return nil, fmt.Errorf("option %q: %w", opt, syscall.EPERM)
+			}
+			o.data = append(o.data, opt)
+		case "default_permissions":
+			o.data = append(o.data, opt)
+		default:
+			return nil, fmt.Errorf("unknown option %q: %w", opt, syscall.EINVAL)
+		}
+	}
+	o.fsType = "fuse"
+	if o.subtype != "" {
+		o.fsType += "." + o.subtype
+	}
+	if o.source == "" {
+		o.source = o.fsType
+	}
+	return o, nil
+}
+
+// inMountNamespace runs f in the mount namespace of process pid. If
+// pidfd is not -1, the namespace is taken from it instead.
+func inMountNamespace(pid, pidfd int, f func() error) error {
+	nsFd := pidfd
+	if nsFd < 0 {
+		// The PID may be reused once the client exits. Check
+		// that it still refers to the same process once the
+		// namespace is open.
+		start, err := procStartTime(pid)
+		if err != nil {
+			return err
+		}
+		ns, err := os.Open(fmt.Sprintf("/proc/%d/ns/mnt", pid))
+		if err != nil {
+			return err
+		}
+		defer ns.Close()
+		if again, err := procStartTime(pid); err != nil || again != start {
+			return fmt.Errorf("client process %d exited: %w", pid, syscall.ESRCH)
+		}
+		nsFd = int(ns.Fd())
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		// The thread is never unlocked, so it exits with this
+		// goroutine, and no other goroutine runs in the
+		// client's namespace.
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_FS); err != nil {
+			errc <- err
+			return
+		}
+		if err := unix.Setns(nsFd, unix.CLONE_NEWNS); err != nil {
+			errc <- err
+			return
+		}
+		errc <- f()
+	}()
+	return <-errc
+}
+
+// procStartTime returns the start time of process pid, which
+// together with the PID identifies the process.
+func procStartTime(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", err
+	}
+	// The command name, in parentheses, may contain spaces.
+	i := strings.LastIndexByte(string(data), ')')
+	fields := strings.Fields(string(data[i+1:]))
+	// The start time is field 22; fields[0] is field 3.
+	if i < 0 || len(fields) < 20 {
+		return "", fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	return fields[19], nil
+}
+
+// hasOption returns whether opt is one of the per-superblock options
+// of the mount, which include the FUSE user_id.
+func hasOption(m *mountinfo.Info, opt string) bool {
+	for _, o := range strings.Split(m.VFSOptions, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// findMount returns the FUSE mount at mountPoint in the mount
+// namespace of the calling thread.
+func findMount(mountPoint string) (*mountinfo.Info, error) {
+	f, err := os.Open("/proc/thread-self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return findMountIn(f, mountPoint)
+}
+
+// findMountIn returns the FUSE mount at mountPoint in the mountinfo
+// table r.
+func findMountIn(r io.Reader, mountPoint string) (*mountinfo.Info, error) {
+	infos, err := mountinfo.GetMountsFromReader(r, func(m *mountinfo.Info) (skip, stop bool) {
+		return m.Mountpoint != mountPoint, false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("no FUSE mount at %q: %w", mountPoint, syscall.EINVAL)
+	}
+	// The last entry is the mount on top.
+	m := infos[len(infos)-1]
+	if m.FSType != "fuse" && !strings.HasPrefix(m.FSType, "fuse.") {
+		return nil, fmt.Errorf("no FUSE mount at %q: %w", mountPoint, syscall.EINVAL)
+	}
+	return m, nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mountbroker
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	for _, tc := range []struct {
+		options    []string
+		uid        uint32
+		allowOther bool
+		want       *mountOptions
+		err        error
+	}{
+		{
+			options: nil,
+			uid:     1000,
+			want: &mountOptions{
+				flags:  syscall.MS_NOSUID | syscall.MS_NODEV,
+				source: "fuse",
+				fsType: "fuse",
+			},
+		},
+		{
+			options: []string{"fsname=src", "subtype=test", "max_read=4096", "noexec", "ro"},
+			uid:     1000,
+			want: &mountOptions{
+				flags:   syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_RDONLY,
+				source:  "src",
+				fsType:  "fuse.test",
+				subtype: "test",
+				data:    []string{"max_read=4096"},
+			},
+		},
+		{
+			options: []string{"suid", "dev"},
+			uid:     0,
+			want: &mountOptions{
+				source: "fuse",
+				fsType: "fuse",
+			},
+		},
+		{options: []string{"suid"}, uid: 1000, err: syscall.EPERM},
+		{options: []string{"dev"}, uid: 1000, err: syscall.EPERM},
+		{options: []string{"allow_other"}, uid: 1000, err: syscall.EPERM},
+		{
+			options:    []string{"allow_other"},
+			uid:        1000,
+			allowOther: true,
+			want: &mountOptions{
+				flags:  syscall.MS_NOSUID | syscall.MS_NODEV,
+				source: "fuse",
+				fsType: "fuse",
+				data:   []string{"allow_other"},
+			},
+		},
+		{options: []string{"max_read=1,fd=3"}, uid: 1000, err: syscall.EINVAL},
+		{options: []string{"fd=3"}, uid: 1000, err: syscall.EINVAL},
+		{options: []string{"fsname=a,fd=3"}, uid: 1000, err: syscall.EINVAL},
+		{options: []string{"allow_other=1"}, uid: 0, err: syscall.EINVAL},
+		{options: []string{"default_permissions=0"}, uid: 1000, err: syscall.EINVAL},
+		{options: []string{"suid=1"}, uid: 0, err: syscall.EINVAL},
+	} {
+		got, err := parseOptions(tc.options, tc.uid, tc.allowOther)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%q: got %v, want %v", tc.options, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.options, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %+v, want %+v", tc.options, got, tc.want)
+		}
+	}
+}
+
+func TestFindMountIn(t *testing.T) {
+	table := `47 28 0:43 / /tmp/a\040b rw,nosuid,nodev,relatime shared:1 - fuse.test src rw,user_id=1000,group_id=1000
+48 28 0:44 / /tmp/c rw,relatime shared:2 - fuse.test src rw,user_id=1000,group_id=1000
+49 48 0:45 / /tmp/c rw,relatime shared:3 - tmpfs tmpfs rw
+`
+	m, err := findMountIn(strings.NewReader(table), "/tmp/a b")
+	if err != nil {
+		t.Fatalf("findMountIn: %v", err)
+	}
+	if m.FSType != "fuse.test" {
+		t.Errorf("got file system type %q", m.FSType)
+	}
+	if !hasOption(m, "user_id=1000") {
+		t.Error("hasOption(user_id=1000) = false")
+	}
+	// The mount on top is not a FUSE mount.
+	if _, err := findMountIn(strings.NewReader(table), "/tmp/c"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("findMountIn(/tmp/c): got %v, want EINVAL", err)
+	}
+}
+
+// startBroker runs a broker with policy p, and returns its socket.
+func startBroker(t *testing.T, p Policy) string {
+	if os.Geteuid() != 0 {
+		t.Skip("the broker needs root permissions")
+	}
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip(err)
+	}
+	sock := filepath.Join(t.TempDir(), "broker.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	b := &Broker{Policy: p}
+	go b.Serve(l)
+	return sock
+}
+
+func TestMountUnmount(t *testing.T) {
+	sock := startBroker(t, Policy{})
+	dir := t.TempDir()
+	fd, err := Mount(sock, dir, []string{"subtype=brokertest"})
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	m, err := findMount(dir)
+	if err != nil {
+		t.Fatalf("findMount: %v", err)
+	}
+	if m.FSType != "fuse.brokertest" {
+		t.Errorf("got file system type %q", m.FSType)
+	}
+	if err := Unmount(sock, dir); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+	if _, err := findMount(dir); err == nil {
+		t.Error("still mounted after Unmount")
+	}
+	if err := Unmount(sock, dir); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Unmount of unmounted dir: got %v, want EINVAL", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	allowed := t.TempDir()
+	sock := startBroker(t, Policy{
+		MountPrefixes: []string{allowed},
+		Check: func(req *MountRequest) error {
+			if filepath.Base(req.MountPoint) == "forbidden" {
+				return syscall.EACCES
+			}
+			return nil
+		},
+	})
+
+	if _, err := Mount(sock, t.TempDir(), nil); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("Mount outside prefix: got %v, want EPERM", err)
+	}
+	forbidden := filepath.Join(allowed, "forbidden")
+	if err := os.Mkdir(forbidden, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Mount(sock, forbidden, nil); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("Mount rejected by Check: got %v, want EACCES", err)
+	}
+	if _, err := Mount(sock, "relative", nil); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Mount relative path: got %v, want EINVAL", err)
+	}
+}
+
+func TestOpenNoFollow(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "real/sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := openNoFollow(filepath.Join(dir, "real"))
+	if err != nil {
+		t.Fatalf("openNoFollow: %v", err)
+	}
+	syscall.Close(fd)
+	for _, p := range []string{"link", "link/sub"} {
+		if fd, err := openNoFollow(filepath.Join(dir, p)); err == nil {
+			syscall.Close(fd)
+			t.Errorf("openNoFollow(%q) followed the symlink", p)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mountbroker implements a privileged daemon that mounts FUSE
+// file systems on behalf of unprivileged processes, and the client
+// side of its protocol. It replaces the setuid fusermount helper in
+// environments that have no setuid binaries, such as containers.
+//
+// The broker listens on a unix socket. For each connection, the
+// client sends one JSON-encoded request and the broker answers with a
+// JSON-encoded response. For a successful mount, the response carries
+// the /dev/fuse file descriptor as SCM_RIGHTS ancillary data. The
+// broker identifies clients with SO_PEERCRED and SO_PEERPIDFD, checks
+// the request against its Policy, and mounts in the mount namespace of
+// the client. The mount point is opened without following symlinks,
+// and the checks and the mount apply to the opened file.
+//
+// To use a broker from go-fuse, set fuse.MountOptions.MountBroker to
+// the path of its socket.
+package mountbroker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+const (
+	opMount   = "mount"
+	opUnmount = "unmount"
+)
+
+// request is sent from the client to the broker.
+type request struct {
+	Op         string `json:"op"`
+	MountPoint string `json:"mount_point"`
+
+	// Options are mount options, as for fusermount's -o flag,
+	// but unescaped and not joined.
+	Options []string `json:"options,omitempty"`
+}
+
+// response is sent from the broker to the client.
+type response struct {
+	Errno int    `json:"errno,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Mount asks the broker listening on socket to mount a FUSE file
+// system at mountPoint, which must be absolute. It returns the
+// /dev/fuse file descriptor for the mount.
+func Mount(socket, mountPoint string, options []string) (int, error) {
+	fds, err := call(socket, &request{
+		Op:         opMount,
+		MountPoint: mountPoint,
+		Options:    options,
+	})
+	if err != nil {
+		return -1, err
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return -1, fmt.Errorf("mount broker: got %d file descriptors, want 1", len(fds))
+	}
+	return fds[0], nil
+}
+
+// Unmount asks the broker listening on socket to unmount the FUSE file
+// system at mountPoint.
+func Unmount(socket, mountPoint string) error {
+	fds, err := call(socket, &request{
+		Op:         opUnmount,
+		MountPoint: mountPoint,
+	})
+	for _, fd := range fds {
+		syscall.Close(fd)
+	}
+	return err
+}
+
+// call sends req to the broker, and returns the file descriptors
+// that came with the response.
+func call(socket string, req *request) (fds []int, err error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("mount broker: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("mount broker: %w", err)
+	}
+	if err := conn.CloseWrite(); err != nil {
+		return nil, fmt.Errorf("mount broker: %w", err)
+	}
+
+	buf := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, fmt.Errorf("mount broker: %w", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("mount broker: %w", err)
+	}
+	for _, m := range msgs {
+		rights, err := syscall.ParseUnixRights(&m)
+		if err == nil {
+			fds = append(fds, rights...)
+		}
+	}
+
+	// The broker closes the connection after the response.
+	rest, err := io.ReadAll(conn)
+	if err == nil {
+		var resp response
+		err = json.Unmarshal(append(buf[:n], rest...), &resp)
+		if err == nil {
+			err = resp.err()
+		}
+	}
+	if err != nil {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, fmt.Errorf("mount broker: %w", err)
+	}
+	return fds, nil
+}
+
+// newResponse returns the response for err.
+func newResponse(err error) *response {
+	if err == nil {
+		return &response{}
+	}
+	resp := &response{Error: err.Error()}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		resp.Errno = int(errno)
+	}
+	return resp
+}
+
+// err returns the error carried by the response, which wraps the
+// errno if there is one.
+func (r *response) err() error {
+	if r.Error == "" && r.Errno == 0 {
+		return nil
+	}
+	if r.Errno != 0 {
+		return &brokerError{msg: r.Error, errno: syscall.Errno(r.Errno)}
+	}
+	return errors.New(r.Error)
+}
+
+// brokerError is an error reported by the broker.
+type brokerError struct {
+	msg   string
+	errno syscall.Errno
+}
+
+func (e *brokerError) Error() string {
+	return e.msg
+}
+
+func (e *brokerError) Unwrap() error {
+	return e.errno
+}
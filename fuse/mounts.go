@@ -0,0 +1,32 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+// MountInfo describes a FUSE mount, as returned by ListMounts.
+type MountInfo struct {
+	// MountPoint is the directory where the file system is
+	// mounted.
+	MountPoint string
+
+	// FsName is the source of the mount, see MountOptions.FsName.
+	FsName string
+
+	// Name is the subtype of the mount, see MountOptions.Name. It
+	// is empty if the mount has no subtype.
+	Name string
+
+	// Options are the options of the mount that are specific to
+	// FUSE, such as user_id and max_read.
+	Options []string
+
+	// ConnectionID is the number of the FUSE connection, see
+	// Connection.
+	ConnectionID uint32
+}
+
+// Connection returns the fusectl connection of the mount.
+func (m *MountInfo) Connection() (*Connection, error) {
+	return newConnection(m.ConnectionID)
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse/mountbroker"
+	"github.com/moby/sys/mountinfo"
+)
+
+// ListMounts returns the FUSE mounts in the mount namespace of the
+// process, in the order in which they were mounted. File systems can
+// recognize their own mounts by MountInfo.Name and MountInfo.FsName.
+func ListMounts() ([]MountInfo, error) {
+	infos, err := mountinfo.GetMounts(func(info *mountinfo.Info) (skip, stop bool) {
+		return !isFuseType(info.FSType), false
+	})
+	if err != nil {
+		return nil, err
+	}
+	var r []MountInfo
+	for _, info := range infos {
+		m := MountInfo{
+			MountPoint:   info.Mountpoint,
+			FsName:       info.Source,
+			ConnectionID: uint32(info.Major)<<20 | uint32(info.Minor),
+		}
+		if _, name, ok := strings.Cut(info.FSType, "."); ok {
+			m.Name = name
+		}
+		if info.VFSOptions != "" {
+			m.Options = strings.Split(info.VFSOptions, ",")
+		}
+		r = append(r, m)
+	}
+	return r, nil
+}
+
+// isFuseType returns true for the file system types of FUSE mounts:
+// "fuse" and "fuseblk", optionally followed by "." and a subtype.
+func isFuseType(fsType string) bool {
+	t, _, _ := strings.Cut(fsType, ".")
+	return t == "fuse" || t == "fuseblk"
+}
+
+// findFuseMount returns the FUSE mount on top of mountPoint, or nil
+// if there is none. It does not access the mount, so it works if the
+// file system is dead or hung.
+func findFuseMount(mountPoint string) (*MountInfo, error) {
+	// mountinfo lists mount points with symlinks resolved.
+	// Resolving the mount point itself would access the mount.
+	dir, err := filepath.EvalSymlinks(filepath.Dir(mountPoint))
+	if err != nil {
+		return nil, err
+	}
+	resolved := filepath.Join(dir, filepath.Base(mountPoint))
+	mounts, err := ListMounts()
+	if err != nil {
+		return nil, err
+	}
+	var found *MountInfo
+	for i := range mounts {
+		// The last entry is the mount on top.
+		if mounts[i].MountPoint == resolved {
+			found = &mounts[i]
+		}
+	}
+	return found, nil
+}
+
+// cleanStaleMount lazily unmounts the FUSE mount at mountPoint if
+// its server has died.
+func cleanStaleMount(mountPoint string, opts *MountOptions) error {
+	var st syscall.Stat_t
+	if err := syscall.Stat(mountPoint, &st); err != syscall.ENOTCONN {
+		return nil
+	}
+
+	found, err := findFuseMount(mountPoint)
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		// Not a FUSE mount; let the mount report the error.
+		return nil
+	}
+
+	if opts.Debug {
+		opts.Logger.Printf("cleanStaleMount: unmounting dead mount at %q", mountPoint)
+	}
+	if err := lazyUnmount(mountPoint, opts); err != nil {
+		return fmt.Errorf("unmounting stale mount at %q: %w", mountPoint, err)
+	}
+	return nil
+}
+
+// lazyUnmount detaches the mount at mountPoint, with umount2(2) if we
+// have the permissions, and through the mount broker or fusermount
+// otherwise.
+func lazyUnmount(mountPoint string, opts *MountOptions) error {
+	err := syscall.Unmount(mountPoint, syscall.MNT_DETACH)
+	if err != syscall.EPERM {
+		return err
+	}
+	if opts.MountBroker != "" {
+		return mountbroker.Unmount(opts.MountBroker, mountPoint)
+	}
+
+	bin, err := fusermountBinary()
+	if err != nil {
+		return err
+	}
+	errBuf := bytes.Buffer{}
+	cmd := exec.Command(bin, "-u", "-z", mountPoint)
+	cmd.Stderr = &errBuf
+	if opts.Debug {
+		opts.Logger.Printf("lazyUnmount: executing %q", cmd.Args)
+	}
+	err = cmd.Run()
+	if errBuf.Len() > 0 {
+		return fmt.Errorf("%s (code %v)", strings.TrimSpace(errBuf.String()), err)
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package fuse
+
+import "syscall"
+
+// ListMounts returns the FUSE mounts of the process. It is only
+// supported on Linux.
+func ListMounts() ([]MountInfo, error) {
+	return nil, syscall.ENOSYS
+}
+
+// cleanStaleMount is a no-op: MountOptions.CleanStaleMount is only
+// supported on Linux.
+func cleanStaleMount(mountPoint string, opts *MountOptions) error {
+	return nil
+}
@@ -500,6 +500,22 @@ func (c *rawBridge) CopyFileRange(cancel <-chan struct{}, input *fuse.CopyFileRa
 	return 0, fuse.ENOSYS
 }
 
+func (fs *rawBridge) Poll(cancel <-chan struct{}, input *fuse.PollIn, out *fuse.PollOut) fuse.Status {
+	return fuse.ENOSYS
+}
+
+func (c *rawBridge) CopyFileRange64(cancel <-chan struct{}, input *fuse.CopyFileRangeIn) (written uint64, code fuse.Status) {
+	return 0, fuse.ENOSYS
+}
+
+func (fs *rawBridge) TmpFile(cancel <-chan struct{}, input *fuse.CreateIn, name string, out *fuse.CreateOut) fuse.Status {
+	return fuse.ENOSYS
+}
+
+func (fs *rawBridge) SyncFs(cancel <-chan struct{}, input *fuse.SyncFsIn) fuse.Status {
+	return fuse.ENOSYS
+}
+
 func (fs *rawBridge) Lseek(cancel <-chan struct{}, in *fuse.LseekIn, out *fuse.LseekOut) fuse.Status {
 	return fuse.ENOSYS
 }
@@ -8,8 +8,10 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"math"
 	"runtime"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -74,8 +76,10 @@ const (
 	_OP_NOTIFY_RETRIEVE_CACHE = uint32(103)
 	_OP_NOTIFY_DELETE         = uint32(104) // protocol version 18
 	_OP_NOTIFY_PRUNE          = uint32(105) // protocol version 45
+	_OP_NOTIFY_POLL           = uint32(106) // protocol version 11
+	_OP_NOTIFY_RESEND         = uint32(107) // protocol version 40
 
-	_OPCODE_COUNT = uint32(106)
+	_OPCODE_COUNT = uint32(108)
 
 	// Constants from Linux kernel fs/fuse/fuse_i.h
 	// Default MaxPages value in all kernel versions
@@ -84,10 +88,70 @@ const (
 	_FUSE_MAX_MAX_PAGES = 256
 )
 
+// Opcodes of the requests the kernel sends, named as in enum
+// fuse_opcode of include/uapi/linux/fuse.h. They go in
+// InHeader.Opcode of requests passed to ProtocolServer.HandleRequest.
+const (
+	FUSE_LOOKUP             = _OP_LOOKUP
+	FUSE_FORGET             = _OP_FORGET
+	FUSE_GETATTR            = _OP_GETATTR
+	FUSE_SETATTR            = _OP_SETATTR
+	FUSE_READLINK           = _OP_READLINK
+	FUSE_SYMLINK            = _OP_SYMLINK
+	FUSE_MKNOD              = _OP_MKNOD
+	FUSE_MKDIR              = _OP_MKDIR
+	FUSE_UNLINK             = _OP_UNLINK
+	FUSE_RMDIR              = _OP_RMDIR
+	FUSE_RENAME             = _OP_RENAME
+	FUSE_LINK               = _OP_LINK
+	FUSE_OPEN               = _OP_OPEN
+	FUSE_READ               = _OP_READ
+	FUSE_WRITE              = _OP_WRITE
+	FUSE_STATFS             = _OP_STATFS
+	FUSE_RELEASE            = _OP_RELEASE
+	FUSE_FSYNC              = _OP_FSYNC
+	FUSE_SETXATTR           = _OP_SETXATTR
+	FUSE_GETXATTR           = _OP_GETXATTR
+	FUSE_LISTXATTR          = _OP_LISTXATTR
+	FUSE_REMOVEXATTR        = _OP_REMOVEXATTR
+	FUSE_FLUSH              = _OP_FLUSH
+	FUSE_INIT               = _OP_INIT
+	FUSE_OPENDIR            = _OP_OPENDIR
+	FUSE_READDIR            = _OP_READDIR
+	FUSE_RELEASEDIR         = _OP_RELEASEDIR
+	FUSE_FSYNCDIR           = _OP_FSYNCDIR
+	FUSE_GETLK              = _OP_GETLK
+	FUSE_SETLK              = _OP_SETLK
+	FUSE_SETLKW             = _OP_SETLKW
+	FUSE_ACCESS             = _OP_ACCESS
+	FUSE_CREATE             = _OP_CREATE
+	FUSE_INTERRUPT          = _OP_INTERRUPT
+	FUSE_BMAP               = _OP_BMAP
+	FUSE_DESTROY            = _OP_DESTROY
+	FUSE_IOCTL              = _OP_IOCTL
+	FUSE_POLL               = _OP_POLL
+	FUSE_NOTIFY_REPLY       = _OP_NOTIFY_REPLY
+	FUSE_BATCH_FORGET       = _OP_BATCH_FORGET
+	FUSE_FALLOCATE          = _OP_FALLOCATE
+	FUSE_READDIRPLUS        = _OP_READDIRPLUS
+	FUSE_RENAME2            = _OP_RENAME2
+	FUSE_LSEEK              = _OP_LSEEK
+	FUSE_COPY_FILE_RANGE    = _OP_COPY_FILE_RANGE
+	FUSE_SETUPMAPPING       = uint32(_OP_SETUPMAPPING)
+	FUSE_REMOVEMAPPING      = uint32(_OP_REMOVEMAPPING)
+	FUSE_SYNCFS             = uint32(_OP_SYNCFS)
+	FUSE_TMPFILE            = uint32(_OP_TMPFILE)
+	FUSE_STATX              = uint32(_OP_STATX)
+	FUSE_COPY_FILE_RANGE_64 = uint32(_OP_COPY_FILE_RANGE_64)
+)
+
 ////////////////////////////////////////////////////////////////
 
 func doInit(server *protocolServer, req *request) {
-	input := (*InitIn)(req.inData())
+	// Before minor version 36, InitIn ends before Flags2, so copy
+	// the request rather than reading past its end.
+	input := &InitIn{}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(input)), unsafe.Sizeof(*input)), req.inputBuf)
 	if input.Major != _FUSE_KERNEL_VERSION {
 		log.Printf("Major versions does not match. Given %d, want %d\n", input.Major, _FUSE_KERNEL_VERSION)
 		req.status = EIO
@@ -114,6 +178,18 @@ func doInit(server *protocolServer, req *request) {
 	if server.opts.EnableAcl {
 		kernelFlags |= input.Flags64() & CAP_POSIX_ACL
 	}
+	if server.opts.EnableSecurityContext {
+		kernelFlags |= input.Flags64() & CAP_SECURITY_CTX
+	}
+	if server.opts.EnableCreateSupplementaryGroup {
+		kernelFlags |= input.Flags64() & CAP_CREATE_SUPP_GROUP
+	}
+	if server.opts.EnableIOUring {
+		kernelFlags |= input.Flags64() & CAP_OVER_IO_URING
+	}
+	if server.opts.RequestTimeout > 0 {
+		kernelFlags |= input.Flags64() & CAP_REQUEST_TIMEOUT
+	}
 
 	if server.opts.ExplicitDataCacheControl {
 		// we don't want CAP_AUTO_INVAL_DATA even if we cannot go into fully explicit mode
@@ -122,6 +198,13 @@ func doInit(server *protocolServer, req *request) {
 		kernelFlags |= input.Flags64() & CAP_AUTO_INVAL_DATA
 	}
 
+	// COPY_FILE_RANGE_64 has no capability flag. The kernel tries
+	// it first, and falls back to COPY_FILE_RANGE if we answer
+	// ENOSYS. doCopyFileRange64 serves it through CopyFileRange if
+	// CopyFileRange64 is not implemented, so it only answers ENOSYS
+	// if neither is, as by default. The fallback then fails too, and
+	// the kernel copies the data itself.
+
 	kernelFlags = kernelFlags &^ server.opts.DisabledCapabilities
 
 	// maxPages is the maximum request size we want the kernel to use, in units of
@@ -141,6 +224,12 @@ func doInit(server *protocolServer, req *request) {
 		MaxStackDepth:       uint32(server.opts.MaxStackDepth),
 	}
 	out.setFlags(kernelFlags)
+	if kernelFlags&CAP_REQUEST_TIMEOUT != 0 {
+		// The kernel counts in seconds; round up so short
+		// timeouts are not switched off.
+		secs := (server.opts.RequestTimeout + time.Second - 1) / time.Second
+		out.RequestTimeout = uint16(min(secs, math.MaxUint16))
+	}
 	if server.opts.MaxReadAhead != 0 && uint32(server.opts.MaxReadAhead) < out.MaxReadAhead {
 		out.MaxReadAhead = uint32(server.opts.MaxReadAhead)
 	}
@@ -153,7 +242,7 @@ func doInit(server *protocolServer, req *request) {
 
 func doOpen(server *protocolServer, req *request) {
 	out := (*OpenOut)(req.outData())
-	status := server.fileSystem.Open(req.cancel, (*OpenIn)(req.inData()), out)
+	status := server.fileSystemCtx.Open(server.context(req), (*OpenIn)(req.inData()), out)
 	req.status = status
 	if status != OK {
 		return
@@ -162,14 +251,19 @@ func doOpen(server *protocolServer, req *request) {
 
 func doCreate(server *protocolServer, req *request) {
 	out := (*CreateOut)(req.outData())
-	status := server.fileSystem.Create(req.cancel, (*CreateIn)(req.inData()), req.filename(), out)
+	status := server.fileSystemCtx.Create(server.context(req), (*CreateIn)(req.inData()), req.filename(), out)
 	req.status = status
 }
 
+func doTmpFile(server *protocolServer, req *request) {
+	out := (*CreateOut)(req.outData())
+	req.status = server.fileSystemCtx.TmpFile(server.context(req), (*CreateIn)(req.inData()), req.filename(), out)
+}
+
 func doReadDir(server *protocolServer, req *request) {
 	in := (*ReadIn)(req.inData())
 	out := NewDirEntryList(req.outPayload, uint64(in.Offset))
-	code := server.fileSystem.ReadDir(req.cancel, in, out)
+	code := server.fileSystemCtx.ReadDir(server.context(req), in, out)
 	req.outPayload = out.bytes()
 	req.status = code
 }
@@ -178,24 +272,24 @@ func doReadDirPlus(server *protocolServer, req *request) {
 	in := (*ReadIn)(req.inData())
 	out := NewDirEntryList(req.outPayload, uint64(in.Offset))
 
-	code := server.fileSystem.ReadDirPlus(req.cancel, in, out)
+	code := server.fileSystemCtx.ReadDirPlus(server.context(req), in, out)
 	req.outPayload = out.bytes()
 	req.status = code
 }
 
 func doOpenDir(server *protocolServer, req *request) {
 	out := (*OpenOut)(req.outData())
-	status := server.fileSystem.OpenDir(req.cancel, (*OpenIn)(req.inData()), out)
+	status := server.fileSystemCtx.OpenDir(server.context(req), (*OpenIn)(req.inData()), out)
 	req.status = status
 }
 
 func doSetattr(server *protocolServer, req *request) {
 	out := (*AttrOut)(req.outData())
-	req.status = server.fileSystem.SetAttr(req.cancel, (*SetAttrIn)(req.inData()), out)
+	req.status = server.fileSystemCtx.SetAttr(server.context(req), (*SetAttrIn)(req.inData()), out)
 }
 
 func doWrite(server *protocolServer, req *request) {
-	n, status := server.fileSystem.Write(req.cancel, (*WriteIn)(req.inData()), req.inPayload)
+	n, status := server.fileSystemCtx.Write(server.context(req), (*WriteIn)(req.inData()), req.inPayload)
 	o := (*WriteOut)(req.outData())
 	o.Size = n
 	req.status = status
@@ -262,9 +356,9 @@ func doGetXAttr(server *protocolServer, req *request) {
 	var n uint32
 	switch req.inHeader().Opcode {
 	case _OP_GETXATTR:
-		n, req.status = server.fileSystem.GetXAttr(req.cancel, req.inHeader(), req.filename(), req.outPayload)
+		n, req.status = server.fileSystemCtx.GetXAttr(server.context(req), req.inHeader(), req.filename(), req.outPayload)
 	case _OP_LISTXATTR:
-		n, req.status = server.fileSystem.ListXAttr(req.cancel, req.inHeader(), req.outPayload)
+		n, req.status = server.fileSystemCtx.ListXAttr(server.context(req), req.inHeader(), req.outPayload)
 	default:
 		req.status = ENOSYS
 	}
@@ -290,7 +384,7 @@ func doGetXAttr(server *protocolServer, req *request) {
 
 func doGetAttr(server *protocolServer, req *request) {
 	out := (*AttrOut)(req.outData())
-	s := server.fileSystem.GetAttr(req.cancel, (*GetAttrIn)(req.inData()), out)
+	s := server.fileSystemCtx.GetAttr(server.context(req), (*GetAttrIn)(req.inData()), out)
 	req.status = s
 }
 
@@ -310,7 +404,7 @@ func doBatchForget(server *protocolServer, req *request) {
 		server.opts.Logger.Printf("Too few bytes for batch forget. Got %d bytes enough for %d entries (want %d entries)",
 			len(req.inPayload), gotCount, in.Count)
 	}
-	in.Count = uint32(gotCount)
+	in.Count = min(in.Count, uint32(gotCount))
 	if in.Count == 0 {
 		return
 	}
@@ -329,53 +423,53 @@ func doBatchForget(server *protocolServer, req *request) {
 }
 
 func doReadlink(server *protocolServer, req *request) {
-	req.outPayload, req.status = server.fileSystem.Readlink(req.cancel, req.inHeader())
+	req.outPayload, req.status = server.fileSystemCtx.Readlink(server.context(req), req.inHeader())
 }
 
 func doLookup(server *protocolServer, req *request) {
 	out := (*EntryOut)(req.outData())
-	req.status = server.fileSystem.Lookup(req.cancel, req.inHeader(), req.filename(), out)
+	req.status = server.fileSystemCtx.Lookup(server.context(req), req.inHeader(), req.filename(), out)
 }
 
 func doMknod(server *protocolServer, req *request) {
 	out := (*EntryOut)(req.outData())
 
-	req.status = server.fileSystem.Mknod(req.cancel, (*MknodIn)(req.inData()), req.filename(), out)
+	req.status = server.fileSystemCtx.Mknod(server.context(req), (*MknodIn)(req.inData()), req.filename(), out)
 }
 
 func doMkdir(server *protocolServer, req *request) {
 	out := (*EntryOut)(req.outData())
-	req.status = server.fileSystem.Mkdir(req.cancel, (*MkdirIn)(req.inData()), req.filename(), out)
+	req.status = server.fileSystemCtx.Mkdir(server.context(req), (*MkdirIn)(req.inData()), req.filename(), out)
 }
 
 func doUnlink(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Unlink(req.cancel, req.inHeader(), req.filename())
+	req.status = server.fileSystemCtx.Unlink(server.context(req), req.inHeader(), req.filename())
 }
 
 func doRmdir(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Rmdir(req.cancel, req.inHeader(), req.filename())
+	req.status = server.fileSystemCtx.Rmdir(server.context(req), req.inHeader(), req.filename())
 }
 
 func doLink(server *protocolServer, req *request) {
 	out := (*EntryOut)(req.outData())
-	req.status = server.fileSystem.Link(req.cancel, (*LinkIn)(req.inData()), req.filename(), out)
+	req.status = server.fileSystemCtx.Link(server.context(req), (*LinkIn)(req.inData()), req.filename(), out)
 }
 
 func doRead(server *protocolServer, req *request) {
 	in := (*ReadIn)(req.inData())
-	req.readResult, req.status = server.fileSystem.Read(req.cancel, in, req.outPayload)
+	req.readResult, req.status = server.fileSystemCtx.Read(server.context(req), in, req.outPayload)
 }
 
 func doFlush(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Flush(req.cancel, (*FlushIn)(req.inData()))
+	req.status = server.fileSystemCtx.Flush(server.context(req), (*FlushIn)(req.inData()))
 }
 
 func doRelease(server *protocolServer, req *request) {
-	server.fileSystem.Release(req.cancel, (*ReleaseIn)(req.inData()))
+	server.fileSystemCtx.Release(server.context(req), (*ReleaseIn)(req.inData()))
 }
 
 func doFsync(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Fsync(req.cancel, (*FsyncIn)(req.inData()))
+	req.status = server.fileSystemCtx.Fsync(server.context(req), (*FsyncIn)(req.inData()))
 }
 
 func doReleaseDir(server *protocolServer, req *request) {
@@ -383,7 +477,7 @@ func doReleaseDir(server *protocolServer, req *request) {
 }
 
 func doFsyncDir(server *protocolServer, req *request) {
-	req.status = server.fileSystem.FsyncDir(req.cancel, (*FsyncIn)(req.inData()))
+	req.status = server.fileSystemCtx.FsyncDir(server.context(req), (*FsyncIn)(req.inData()))
 }
 
 func doSetXAttr(server *protocolServer, req *request) {
@@ -392,22 +486,22 @@ func doSetXAttr(server *protocolServer, req *request) {
 		req.status = EINVAL
 		return
 	}
-	req.status = server.fileSystem.SetXAttr(req.cancel, (*SetXAttrIn)(req.inData()), string(req.inPayload[:i]), req.inPayload[i+1:])
+	req.status = server.fileSystemCtx.SetXAttr(server.context(req), (*SetXAttrIn)(req.inData()), string(req.inPayload[:i]), req.inPayload[i+1:])
 }
 
 func doRemoveXAttr(server *protocolServer, req *request) {
-	req.status = server.fileSystem.RemoveXAttr(req.cancel, req.inHeader(), req.filename())
+	req.status = server.fileSystemCtx.RemoveXAttr(server.context(req), req.inHeader(), req.filename())
 }
 
 func doAccess(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Access(req.cancel, (*AccessIn)(req.inData()))
+	req.status = server.fileSystemCtx.Access(server.context(req), (*AccessIn)(req.inData()))
 }
 
 func doSymlink(server *protocolServer, req *request) {
 	out := (*EntryOut)(req.outData())
 	n1, n2 := req.filenames()
 
-	req.status = server.fileSystem.Symlink(req.cancel, req.inHeader(), n2, n1, out)
+	req.status = server.fileSystemCtx.Symlink(server.context(req), req.inHeader(), n2, n1, out)
 }
 
 func doRename(server *protocolServer, req *request) {
@@ -421,17 +515,17 @@ func doRename(server *protocolServer, req *request) {
 		Newdir:   in1.Newdir,
 	}
 	n1, n2 := req.filenames()
-	req.status = server.fileSystem.Rename(req.cancel, &in, n1, n2)
+	req.status = server.fileSystemCtx.Rename(server.context(req), &in, n1, n2)
 }
 
 func doRename2(server *protocolServer, req *request) {
 	n1, n2 := req.filenames()
-	req.status = server.fileSystem.Rename(req.cancel, (*RenameIn)(req.inData()), n1, n2)
+	req.status = server.fileSystemCtx.Rename(server.context(req), (*RenameIn)(req.inData()), n1, n2)
 }
 
 func doStatFs(server *protocolServer, req *request) {
 	out := (*StatfsOut)(req.outData())
-	req.status = server.fileSystem.StatFs(req.cancel, req.inHeader(), out)
+	req.status = server.fileSystemCtx.StatFs(server.context(req), req.inHeader(), out)
 	if req.status == ENOSYS && runtime.GOOS == "darwin" {
 		// OSX FUSE requires Statfs to be implemented for the
 		// mount to succeed.
@@ -440,8 +534,12 @@ func doStatFs(server *protocolServer, req *request) {
 	}
 }
 
+func doSyncFs(server *protocolServer, req *request) {
+	req.status = server.fileSystemCtx.SyncFs(server.context(req), (*SyncFsIn)(req.inData()))
+}
+
 func doIoctl(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Ioctl(req.cancel, (*IoctlIn)(req.inData()), req.inPayload, (*IoctlOut)(req.outData()),
+	req.status = server.fileSystemCtx.Ioctl(server.context(req), (*IoctlIn)(req.inData()), req.inPayload, (*IoctlOut)(req.outData()),
 		req.outPayload)
 }
 
@@ -450,32 +548,55 @@ func doDestroy(server *protocolServer, req *request) {
 }
 
 func doFallocate(server *protocolServer, req *request) {
-	req.status = server.fileSystem.Fallocate(req.cancel, (*FallocateIn)(req.inData()))
+	req.status = server.fileSystemCtx.Fallocate(server.context(req), (*FallocateIn)(req.inData()))
 }
 
 func doGetLk(server *protocolServer, req *request) {
-	req.status = server.fileSystem.GetLk(req.cancel, (*LkIn)(req.inData()), (*LkOut)(req.outData()))
+	req.status = server.fileSystemCtx.GetLk(server.context(req), (*LkIn)(req.inData()), (*LkOut)(req.outData()))
 }
 
 func doSetLk(server *protocolServer, req *request) {
-	req.status = server.fileSystem.SetLk(req.cancel, (*LkIn)(req.inData()))
+	req.status = server.fileSystemCtx.SetLk(server.context(req), (*LkIn)(req.inData()))
 }
 
 func doSetLkw(server *protocolServer, req *request) {
-	req.status = server.fileSystem.SetLkw(req.cancel, (*LkIn)(req.inData()))
+	req.status = server.fileSystemCtx.SetLkw(server.context(req), (*LkIn)(req.inData()))
 }
 
 func doLseek(server *protocolServer, req *request) {
 	in := (*LseekIn)(req.inData())
 	out := (*LseekOut)(req.outData())
-	req.status = server.fileSystem.Lseek(req.cancel, in, out)
+	req.status = server.fileSystemCtx.Lseek(server.context(req), in, out)
+}
+
+func doPoll(server *protocolServer, req *request) {
+	in := (*PollIn)(req.inData())
+	out := (*PollOut)(req.outData())
+	req.status = server.fileSystemCtx.Poll(server.context(req), in, out)
 }
 
 func doCopyFileRange(server *protocolServer, req *request) {
 	in := (*CopyFileRangeIn)(req.inData())
 	out := (*WriteOut)(req.outData())
 
-	out.Size, req.status = server.fileSystem.CopyFileRange(req.cancel, in)
+	out.Size, req.status = server.fileSystemCtx.CopyFileRange(server.context(req), in)
+}
+
+func doCopyFileRange64(server *protocolServer, req *request) {
+	in := (*CopyFileRangeIn)(req.inData())
+	out := (*CopyFileRangeOut)(req.outData())
+
+	ctx := server.context(req)
+	out.BytesCopied, req.status = server.fileSystemCtx.CopyFileRange64(ctx, in)
+	if req.status == ENOSYS {
+		// Serve through the 32-bit variant, so the kernel
+		// does not need another roundtrip.
+		in32 := *in
+		in32.Len = min(in.Len, math.MaxUint32)
+		var written uint32
+		written, req.status = server.fileSystemCtx.CopyFileRange(ctx, &in32)
+		out.BytesCopied = uint64(written)
+	}
 }
 
 func doInterrupt(server *protocolServer, req *request) {
@@ -585,6 +706,8 @@ func init() {
 		_OP_NOTIFY_STORE_CACHE:    "NOTIFY_STORE",
 		_OP_NOTIFY_RETRIEVE_CACHE: "NOTIFY_RETRIEVE",
 		_OP_NOTIFY_DELETE:         "NOTIFY_DELETE",
+		_OP_NOTIFY_POLL:           "NOTIFY_POLL",
+		_OP_NOTIFY_RESEND:         "NOTIFY_RESEND",
 		_OP_FALLOCATE:             "FALLOCATE",
 		_OP_READDIRPLUS:           "READDIRPLUS",
 		_OP_RENAME2:               "RENAME2",
@@ -600,49 +723,53 @@ func init() {
 	}
 
 	for op, v := range map[uint32]operationFunc{
-		_OP_OPEN:            doOpen,
-		_OP_READDIR:         doReadDir,
-		_OP_WRITE:           doWrite,
-		_OP_OPENDIR:         doOpenDir,
-		_OP_CREATE:          doCreate,
-		_OP_SETATTR:         doSetattr,
-		_OP_GETXATTR:        doGetXAttr,
-		_OP_LISTXATTR:       doGetXAttr,
-		_OP_GETATTR:         doGetAttr,
-		_OP_FORGET:          doForget,
-		_OP_BATCH_FORGET:    doBatchForget,
-		_OP_READLINK:        doReadlink,
-		_OP_INIT:            doInit,
-		_OP_LOOKUP:          doLookup,
-		_OP_MKNOD:           doMknod,
-		_OP_MKDIR:           doMkdir,
-		_OP_UNLINK:          doUnlink,
-		_OP_RMDIR:           doRmdir,
-		_OP_LINK:            doLink,
-		_OP_READ:            doRead,
-		_OP_FLUSH:           doFlush,
-		_OP_RELEASE:         doRelease,
-		_OP_FSYNC:           doFsync,
-		_OP_RELEASEDIR:      doReleaseDir,
-		_OP_FSYNCDIR:        doFsyncDir,
-		_OP_SETXATTR:        doSetXAttr,
-		_OP_REMOVEXATTR:     doRemoveXAttr,
-		_OP_GETLK:           doGetLk,
-		_OP_SETLK:           doSetLk,
-		_OP_SETLKW:          doSetLkw,
-		_OP_ACCESS:          doAccess,
-		_OP_SYMLINK:         doSymlink,
-		_OP_RENAME:          doRename,
-		_OP_STATFS:          doStatFs,
-		_OP_IOCTL:           doIoctl,
-		_OP_DESTROY:         doDestroy,
-		_OP_NOTIFY_REPLY:    doNotifyReply,
-		_OP_FALLOCATE:       doFallocate,
-		_OP_READDIRPLUS:     doReadDirPlus,
-		_OP_RENAME2:         doRename2,
-		_OP_INTERRUPT:       doInterrupt,
-		_OP_COPY_FILE_RANGE: doCopyFileRange,
-		_OP_LSEEK:           doLseek,
+		_OP_OPEN:               doOpen,
+		_OP_READDIR:            doReadDir,
+		_OP_WRITE:              doWrite,
+		_OP_OPENDIR:            doOpenDir,
+		_OP_CREATE:             doCreate,
+		_OP_SETATTR:            doSetattr,
+		_OP_GETXATTR:           doGetXAttr,
+		_OP_LISTXATTR:          doGetXAttr,
+		_OP_GETATTR:            doGetAttr,
+		_OP_FORGET:             doForget,
+		_OP_BATCH_FORGET:       doBatchForget,
+		_OP_READLINK:           doReadlink,
+		_OP_INIT:               doInit,
+		_OP_LOOKUP:             doLookup,
+		_OP_MKNOD:              doMknod,
+		_OP_MKDIR:              doMkdir,
+		_OP_UNLINK:             doUnlink,
+		_OP_RMDIR:              doRmdir,
+		_OP_LINK:               doLink,
+		_OP_READ:               doRead,
+		_OP_FLUSH:              doFlush,
+		_OP_RELEASE:            doRelease,
+		_OP_FSYNC:              doFsync,
+		_OP_RELEASEDIR:         doReleaseDir,
+		_OP_FSYNCDIR:           doFsyncDir,
+		_OP_SETXATTR:           doSetXAttr,
+		_OP_REMOVEXATTR:        doRemoveXAttr,
+		_OP_GETLK:              doGetLk,
+		_OP_SETLK:              doSetLk,
+		_OP_SETLKW:             doSetLkw,
+		_OP_ACCESS:             doAccess,
+		_OP_SYMLINK:            doSymlink,
+		_OP_RENAME:             doRename,
+		_OP_STATFS:             doStatFs,
+		_OP_IOCTL:              doIoctl,
+		_OP_DESTROY:            doDestroy,
+		_OP_NOTIFY_REPLY:       doNotifyReply,
+		_OP_FALLOCATE:          doFallocate,
+		_OP_READDIRPLUS:        doReadDirPlus,
+		_OP_RENAME2:            doRename2,
+		_OP_INTERRUPT:          doInterrupt,
+		_OP_COPY_FILE_RANGE:    doCopyFileRange,
+		_OP_COPY_FILE_RANGE_64: doCopyFileRange64,
+		_OP_LSEEK:              doLseek,
+		_OP_POLL:               doPoll,
+		_OP_SYNCFS:             doSyncFs,
+		_OP_TMPFILE:            doTmpFile,
 	} {
 		operationHandlers[op].Func = v
 	}
@@ -669,12 +796,14 @@ func init() {
 		_OP_NOTIFY_RETRIEVE_CACHE: NotifyRetrieveOut{},
 		_OP_NOTIFY_STORE_CACHE:    NotifyStoreOut{},
 		_OP_NOTIFY_PRUNE:          NotifyPruneOut{},
+		_OP_NOTIFY_POLL:           NotifyPollWakeupOut{},
 		_OP_OPEN:                  OpenOut{},
 		_OP_OPENDIR:               OpenOut{},
-		_OP_POLL:                  _PollOut{},
+		_OP_POLL:                  PollOut{},
 		_OP_SETATTR:               AttrOut{},
 		_OP_STATFS:                StatfsOut{},
 		_OP_SYMLINK:               EntryOut{},
+		_OP_TMPFILE:               CreateOut{},
 		_OP_WRITE:                 WriteOut{},
 		_OP_COPY_FILE_RANGE_64:    CopyFileRangeOut{},
 	} {
@@ -708,7 +837,7 @@ func init() {
 		_OP_NOTIFY_REPLY:       NotifyRetrieveIn{},
 		_OP_OPEN:               OpenIn{},
 		_OP_OPENDIR:            OpenIn{},
-		_OP_POLL:               _PollIn{},
+		_OP_POLL:               PollIn{},
 		_OP_READ:               ReadIn{},
 		_OP_READDIR:            ReadIn{},
 		_OP_READDIRPLUS:        ReadIn{},
@@ -720,6 +849,8 @@ func init() {
 		_OP_SETLK:              LkIn{},
 		_OP_SETLKW:             LkIn{},
 		_OP_SETXATTR:           SetXAttrIn{},
+		_OP_SYNCFS:             SyncFsIn{},
+		_OP_TMPFILE:            CreateIn{},
 		_OP_WRITE:              WriteIn{},
 		_OP_COPY_FILE_RANGE_64: CopyFileRangeIn{},
 	} {
@@ -745,6 +876,7 @@ func init() {
 		_OP_RENAME2:     2,
 		_OP_RMDIR:       1,
 		_OP_SYMLINK:     2,
+		_OP_TMPFILE:     1,
 		_OP_UNLINK:      1,
 	} {
 		operationHandlers[op].FileNames = count
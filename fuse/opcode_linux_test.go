@@ -0,0 +1,51 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"math"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestInitRequestTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeout    time.Duration
+		kernelCaps uint64
+		want       uint16
+	}{
+		{0, CAP_REQUEST_TIMEOUT, 0},
+		{1500 * time.Millisecond, CAP_REQUEST_TIMEOUT, 2},
+		{24 * time.Hour, CAP_REQUEST_TIMEOUT, math.MaxUint16},
+		{time.Minute, 0, 0},
+	} {
+		ps := NewProtocolServer(NewDefaultRawFileSystem(), &MountOptions{
+			RequestTimeout: tc.timeout,
+		})
+		flags := tc.kernelCaps
+		in := InitIn{
+			InHeader: InHeader{
+				Length: uint32(unsafe.Sizeof(InitIn{})),
+				Opcode: _OP_INIT,
+				Unique: 1,
+			},
+			Major:  _FUSE_KERNEL_VERSION,
+			Minor:  _OUR_MINOR_VERSION,
+			Flags:  uint32(flags),
+			Flags2: uint32(flags >> 32),
+		}
+		var out InitOut
+		if outHeader := serveRequest(t, ps, &in, &out); outHeader.Status != 0 {
+			t.Fatalf("INIT: status %d", outHeader.Status)
+		}
+		if out.RequestTimeout != tc.want {
+			t.Errorf("timeout %v, caps %x: got RequestTimeout %d, want %d", tc.timeout, tc.kernelCaps, out.RequestTimeout, tc.want)
+		}
+		if got := out.Flags64()&CAP_REQUEST_TIMEOUT != 0; got != (tc.want != 0) {
+			t.Errorf("timeout %v, caps %x: got CAP_REQUEST_TIMEOUT %v", tc.timeout, tc.kernelCaps, got)
+		}
+	}
+}
@@ -307,7 +307,7 @@ func (o *StatfsOut) string() string {
 }
 
 func (o *NotifyInvalEntryOut) string() string {
-	return fmt.Sprintf("{parent i%d sz %d}", o.Parent, o.NameLen)
+	return fmt.Sprintf("{parent i%d sz %d flags 0x%x}", o.Parent, o.NameLen, o.Flags)
 }
 
 func (o *NotifyInvalInodeOut) string() string {
@@ -352,6 +352,10 @@ func (i *CopyFileRangeIn) string() string {
 		i.FhIn, i.OffIn, i.Len, i.NodeIdOut, i.FhOut, i.OffOut, i.Len)
 }
 
+func (o *CopyFileRangeOut) string() string {
+	return fmt.Sprintf("{%db}", o.BytesCopied)
+}
+
 func (in *InterruptIn) string() string {
 	return fmt.Sprintf("{ix %d}", in.Unique)
 }
@@ -373,8 +377,16 @@ func (o *LseekOut) string() string {
 	return fmt.Sprintf("{%d}", o.Offset)
 }
 
-func (p *_PollIn) string() string {
-	return fmt.Sprintf("Fh %d Kh %d Flags 0x%x", p.Fh, p.Kh, p.Flags)
+func (p *PollIn) string() string {
+	return fmt.Sprintf("{Fh %d Kh %d Flags 0x%x Events 0x%x}", p.Fh, p.Kh, p.Flags, p.Events)
+}
+
+func (o *PollOut) string() string {
+	return fmt.Sprintf("{Revents 0x%x}", o.Revents)
+}
+
+func (o *NotifyPollWakeupOut) string() string {
+	return fmt.Sprintf("{Kh %d}", o.Kh)
 }
 
 // Print pretty prints FUSE data types for kernel communication
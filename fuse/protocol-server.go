@@ -5,23 +5,44 @@
 package fuse
 
 import (
+	"context"
 	"log"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 // protocolServer bridges from the FUSE datatypes to a RawFileSystem
 type protocolServer struct {
 	fileSystem RawFileSystem
 
+	// fileSystemCtx serves the requests. It is the RawFileSystemCtx
+	// of fileSystem, see NewRawFileSystemCtx.
+	fileSystemCtx RawFileSystemCtx
+
 	writev func([][]byte) (int, syscall.Errno)
 
 	interruptMu    sync.Mutex
 	reqInflight    []*request
 	connectionDead bool
 
+	// completed counts the requests that have finished, so the
+	// systemd watchdog can tell whether the server makes progress.
+	// Protected by interruptMu.
+	completed uint64
+
 	latencies LatencyMap
 
+	// slogCount counts requests for MountOptions.Slog sampling.
+	slogCount atomic.Uint64
+
+	// handoffWaking is set while Server.Handoff wakes up the
+	// readers. Root lookups of handoffWakeName then fail with
+	// ENOENT without reaching the file system.
+	handoffWaking atomic.Bool
+
 	kernelSettings InitIn
 
 	opts *MountOptions
@@ -35,19 +56,38 @@ type protocolServer struct {
 func (ms *protocolServer) handleRequest(h *operationHandler, req *request) {
 	ms.addInflight(req)
 	defer ms.dropInflight(req)
+	if s := ms.opts.Stats; s != nil {
+		op := req.inHeader().Opcode
+		s.start(op)
+		defer s.done(op, req, time.Now())
+	}
+	if req.status.Ok() {
+		req.status = req.splitExtensions()
+	}
 
 	if req.status.Ok() && ms.opts.Debug {
 		ms.opts.Logger.Println(req.InputDebug())
 	}
+	if req.status.Ok() && ms.opts.Slog != nil {
+		sampled := ms.slogRequest(h, req)
+		defer ms.slogReply(h, req, sampled, time.Now())
+	}
 
-	if req.inHeader().NodeId == pollHackInode ||
-		req.inHeader().NodeId == FUSE_ROOT_ID && h.FileNames > 0 && req.filename() == pollHackName {
+	if req.status.Ok() && h.FileNames > 0 && !req.validNames(h) {
+		req.status = EINVAL
+	}
+
+	if !ms.opts.EnablePoll && (req.inHeader().NodeId == pollHackInode ||
+		req.inHeader().NodeId == FUSE_ROOT_ID && h.FileNames > 0 && req.filename() == pollHackName) {
 		doPollHackLookup(ms, req)
-	} else if req.status.Ok() && h.Func == nil {
-		ms.opts.Logger.Printf("Unimplemented opcode %v", operationName(req.inHeader().Opcode))
-		req.status = ENOSYS
+	} else if ms.handoffWaking.Load() && req.inHeader().Opcode == _OP_LOOKUP &&
+		req.inHeader().NodeId == FUSE_ROOT_ID && req.filename() == handoffWakeName {
+		// Sent by Handoff to wake up readers.
+		req.status = ENOENT
+	} else if req.status.Ok() && len(ms.opts.Interceptors) > 0 {
+		ms.intercept(h, req)
 	} else if req.status.Ok() {
-		h.Func(ms, req)
+		ms.serve(h, req)
 	}
 
 	// Forget/NotifyReply do not wait for reply from filesystem server.
@@ -58,6 +98,13 @@ func (ms *protocolServer) handleRequest(h *operationHandler, req *request) {
 			req.suppressReply = true
 		}
 	}
+	if req.abandoned.Swap(true) {
+		req.suppressReply = true
+		if req.readResult != nil {
+			req.readResult.Done()
+			req.readResult = nil
+		}
+	}
 	if req.status == EINTR {
 		ms.interruptMu.Lock()
 		dead := ms.connectionDead
@@ -82,9 +129,69 @@ func (ms *protocolServer) handleRequest(h *operationHandler, req *request) {
 	}
 }
 
+// context returns the context that the file system is called with
+// for req.
+func (ms *protocolServer) context(req *request) context.Context {
+	if _, ok := ms.fileSystemCtx.(*ctxFromRaw); ok {
+		// A RawFileSystem only gets the cancel channel.
+		return cancelContext(req.cancel)
+	}
+	c := newContext(req.cancel, req.inHeader())
+	c.ext = req.ext
+	return c
+}
+
+// serve answers a well-formed request: it refuses it, reports an
+// unimplemented opcode, or calls the handler.
+func (ms *protocolServer) serve(h *operationHandler, req *request) {
+	if req.refused {
+		req.status = ENOTCONN
+	} else if h.Func == nil {
+		ms.opts.Logger.Printf("Unimplemented opcode %v", operationName(req.inHeader().Opcode))
+		req.status = ENOSYS
+	} else {
+		ms.callHandler(h, req)
+	}
+}
+
+// callHandler runs the handler for the opcode, recovering panics if
+// MountOptions.RecoverPanics is set.
+func (ms *protocolServer) callHandler(h *operationHandler, req *request) {
+	if ms.opts.RecoverPanics {
+		defer ms.recoverPanic(req)
+	}
+	h.Func(ms, req)
+}
+
+// recoverPanic turns a panic in the handler for req into an EIO
+// reply, unless MountOptions.OnPanic asks to abort.
+func (ms *protocolServer) recoverPanic(req *request) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	desc := req.InputDebug()
+	ms.opts.Logger.Printf("panic serving %s: %v\n%s", desc, r, stack)
+	if ms.opts.OnPanic != nil && ms.opts.OnPanic(desc, r, stack) {
+		panic(r)
+	}
+
+	// The output may be partially filled in; drop it.
+	req.status = EIO
+	req.outPayload = nil
+	if req.readResult != nil {
+		req.readResult.Done()
+		req.readResult = nil
+	}
+}
+
 func (ms *protocolServer) addInflight(req *request) {
 	ms.interruptMu.Lock()
 	defer ms.interruptMu.Unlock()
+	if ms.opts.WatchdogTimeout > 0 && req.startTime.IsZero() {
+		req.startTime = time.Now()
+	}
 	req.inflightIndex = len(ms.reqInflight)
 	ms.reqInflight = append(ms.reqInflight, req)
 }
@@ -99,6 +206,22 @@ func (ms *protocolServer) dropInflight(req *request) {
 		ms.reqInflight[this].inflightIndex = this
 	}
 	ms.reqInflight = ms.reqInflight[:last]
+	ms.completed++
+}
+
+// progress returns the number of requests that have finished, and
+// whether any request is in flight. SETLKW requests, which wait for a
+// lock by design, do not count as in flight.
+func (ms *protocolServer) progress() (completed uint64, busy bool) {
+	ms.interruptMu.Lock()
+	defer ms.interruptMu.Unlock()
+	for _, req := range ms.reqInflight {
+		if req.inHeader().Opcode != _OP_SETLKW {
+			busy = true
+			break
+		}
+	}
+	return ms.completed, busy
 }
 
 func (ms *protocolServer) interruptRequest(unique uint64) Status {
@@ -121,6 +244,20 @@ func (ms *protocolServer) cancelAll() {
 	ms.interruptMu.Lock()
 	defer ms.interruptMu.Unlock()
 	ms.connectionDead = true
+	ms.interruptInflight()
+}
+
+// interruptAll interrupts all requests in flight, as if the kernel
+// had sent an INTERRUPT for each of them.
+func (ms *protocolServer) interruptAll() {
+	ms.interruptMu.Lock()
+	defer ms.interruptMu.Unlock()
+	ms.interruptInflight()
+}
+
+// interruptInflight closes the cancel channels of the requests in
+// flight. The caller must hold interruptMu.
+func (ms *protocolServer) interruptInflight() {
 	for _, req := range ms.reqInflight {
 		if !req.interrupted {
 			close(req.cancel)
@@ -151,9 +288,10 @@ func NewProtocolServer(fs RawFileSystem, opts *MountOptions) *ProtocolServer {
 	}
 	return &ProtocolServer{
 		protocolServer: protocolServer{
-			fileSystem:  fs,
-			retrieveTab: make(map[uint64]*retrieveCacheRequest),
-			opts:        &optsCopy,
+			fileSystem:    fs,
+			fileSystemCtx: NewRawFileSystemCtx(fs),
+			retrieveTab:   make(map[uint64]*retrieveCacheRequest),
+			opts:          &optsCopy,
 		},
 	}
 }
@@ -241,6 +379,10 @@ func (ps *ProtocolServer) HandleRequest(in [][]byte, out [][]byte) (int, Status)
 
 	beforePayload := req.outPayload
 	ps.protocolServer.handleRequest(h, &req)
+	if req.suppressReply {
+		// For example, INTERRUPT only has a reply if it failed.
+		return 0, OK
+	}
 	if len(req.outPayload) > 0 && len(beforePayload) > 0 &&
 		&beforePayload[0] != &req.outPayload[0] {
 		n := copy(beforePayload, req.outPayload)
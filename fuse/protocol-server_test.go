@@ -7,10 +7,49 @@ package fuse
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"unsafe"
 )
 
+// serveRequest sends the request struct pointed to by in, followed by
+// payload, to ps. The reply struct is stored in out, which is a
+// pointer to a struct, a byte slice or nil. It returns the reply
+// header.
+func serveRequest(t *testing.T, ps *ProtocolServer, in, out interface{}, payload ...[]byte) OutHeader {
+	t.Helper()
+	inBuf := [][]byte{asBytes(in)}
+	inBuf = append(inBuf, payload...)
+
+	var outHeader OutHeader
+	outBuf := [][]byte{asBytes(&outHeader)}
+	if out != nil {
+		outBuf = append(outBuf, asBytes(out))
+	}
+	if _, st := ps.HandleRequest(inBuf, outBuf); st != OK {
+		hdr := (*InHeader)(unsafe.Pointer(&inBuf[0][0]))
+		t.Fatalf("%s: HandleRequest: %v", operationName(hdr.Opcode), st)
+	}
+	return outHeader
+}
+
+// asBytes returns the memory of the struct pointed to by v, or v
+// itself if it is a byte slice.
+func asBytes(v interface{}) []byte {
+	if b, ok := v.([]byte); ok {
+		return b
+	}
+	p := reflect.ValueOf(v)
+	return unsafe.Slice((*byte)(p.UnsafePointer()), p.Type().Elem().Size())
+}
+
 func TestProtocolServerParse(t *testing.T) {
 	in := [][]byte{
 		[]byte("A\x00\x00\x00\x16\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00.\x04\x00\x00\x00\x00\x00\x00"),
@@ -46,3 +85,309 @@ func TestProtocolServerParse(t *testing.T) {
 		t.Errorf("OutHeader.Status = %d, want %d", gotStatus, -int32(ENOSYS))
 	}
 }
+
+type copyFileRange32FS struct {
+	RawFileSystem
+
+	gotLen uint64
+}
+
+func (fs *copyFileRange32FS) CopyFileRange(cancel <-chan struct{}, in *CopyFileRangeIn) (uint32, Status) {
+	fs.gotLen = in.Len
+	return uint32(in.Len), OK
+}
+
+func TestCopyFileRange64Fallback(t *testing.T) {
+	fs := &copyFileRange32FS{RawFileSystem: NewDefaultRawFileSystem()}
+	ps := NewProtocolServer(fs, &MountOptions{})
+
+	in := CopyFileRangeIn{
+		InHeader: InHeader{
+			Length: uint32(unsafe.Sizeof(CopyFileRangeIn{})),
+			Opcode: _OP_COPY_FILE_RANGE_64,
+			Unique: 2,
+			NodeId: 2,
+		},
+		NodeIdOut: 3,
+		Len:       1 << 33,
+	}
+	var out CopyFileRangeOut
+	outHeader := serveRequest(t, ps, &in, &out)
+	if outHeader.Status != 0 {
+		t.Fatalf("got status %d", outHeader.Status)
+	}
+	if fs.gotLen != math.MaxUint32 {
+		t.Errorf("CopyFileRange got len %d, want %d", fs.gotLen, uint64(math.MaxUint32))
+	}
+	if out.BytesCopied != math.MaxUint32 {
+		t.Errorf("got BytesCopied %d, want %d", out.BytesCopied, uint64(math.MaxUint32))
+	}
+}
+
+type panicFS struct {
+	RawFileSystem
+}
+
+func (fs *panicFS) GetAttr(cancel <-chan struct{}, in *GetAttrIn, out *AttrOut) Status {
+	var p *AttrOut
+	out.Attr = p.Attr
+	return OK
+}
+
+func TestRecoverPanics(t *testing.T) {
+	var gotRequest string
+	var gotStack []byte
+	ps := NewProtocolServer(&panicFS{NewDefaultRawFileSystem()}, &MountOptions{
+		RecoverPanics: true,
+		Logger:        log.New(io.Discard, "", 0),
+		OnPanic: func(request string, value interface{}, stack []byte) bool {
+			gotRequest = request
+			gotStack = stack
+			return false
+		},
+	})
+
+	in := GetAttrIn{
+		InHeader: InHeader{
+			Length: uint32(unsafe.Sizeof(GetAttrIn{})),
+			Opcode: _OP_GETATTR,
+			Unique: 2,
+			NodeId: 7,
+		},
+	}
+	var out AttrOut
+	outHeader := serveRequest(t, ps, &in, &out)
+	if outHeader.Status != -int32(EIO) {
+		t.Errorf("got status %d, want %d", outHeader.Status, -int32(EIO))
+	}
+	if !strings.Contains(gotRequest, "GETATTR") || !strings.Contains(gotRequest, "n7") {
+		t.Errorf("OnPanic got request %q", gotRequest)
+	}
+	if !bytes.Contains(gotStack, []byte("panicFS")) {
+		t.Errorf("OnPanic got stack without panicking function:\n%s", gotStack)
+	}
+}
+
+type inoFS struct {
+	RawFileSystem
+}
+
+func (fs *inoFS) GetAttr(cancel <-chan struct{}, in *GetAttrIn, out *AttrOut) Status {
+	out.Ino = in.NodeId
+	return OK
+}
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	var gotOut *AttrOut
+	logger := func(info *RequestInfo, handler func() Status) Status {
+		calls = append(calls, "log "+info.Name)
+		st := handler()
+		if out, ok := info.Output.(*AttrOut); ok {
+			gotOut = out
+		}
+		calls = append(calls, "log "+st.String())
+		return st
+	}
+	deny := func(info *RequestInfo, handler func() Status) Status {
+		if info.Opcode == _OP_LOOKUP {
+			calls = append(calls, "deny "+info.Names[0])
+			return EACCES
+		}
+		if _, ok := info.Input.(*GetAttrIn); info.Opcode == _OP_GETATTR && !ok {
+			t.Errorf("got input %T, want *GetAttrIn", info.Input)
+		}
+		return handler()
+	}
+	ps := NewProtocolServer(&inoFS{NewDefaultRawFileSystem()}, &MountOptions{
+		Interceptors: []Interceptor{logger, deny},
+	})
+
+	getattr := GetAttrIn{
+		InHeader: InHeader{
+			Length: uint32(unsafe.Sizeof(GetAttrIn{})),
+			Opcode: _OP_GETATTR,
+			Unique: 2,
+			NodeId: 7,
+		},
+	}
+	var attrOut AttrOut
+	if outHeader := serveRequest(t, ps, &getattr, &attrOut); outHeader.Status != 0 {
+		t.Fatalf("GETATTR: status %d", outHeader.Status)
+	}
+	if attrOut.Ino != 7 {
+		t.Errorf("got ino %d, want 7", attrOut.Ino)
+	}
+	if gotOut == nil {
+		t.Errorf("interceptor did not see AttrOut")
+	}
+
+	lookup := InHeader{
+		Length: uint32(unsafe.Sizeof(InHeader{})) + 5,
+		Opcode: _OP_LOOKUP,
+		Unique: 3,
+		NodeId: 1,
+	}
+	var entryOut EntryOut
+	if outHeader := serveRequest(t, ps, &lookup, &entryOut, []byte("file\x00")); outHeader.Status != -int32(EACCES) {
+		t.Errorf("LOOKUP: got status %d, want %d", outHeader.Status, -int32(EACCES))
+	}
+
+	// Interceptors also see opcodes without a handler.
+	bmap := _BmapIn{
+		InHeader: InHeader{
+			Length: uint32(unsafe.Sizeof(_BmapIn{})),
+			Opcode: _OP_BMAP,
+			Unique: 4,
+			NodeId: 7,
+		},
+	}
+	var bmapOut _BmapOut
+	if outHeader := serveRequest(t, ps, &bmap, &bmapOut); outHeader.Status != -int32(ENOSYS) {
+		t.Errorf("BMAP: got status %d, want %d", outHeader.Status, -int32(ENOSYS))
+	}
+
+	want := "log GETATTR,log OK,log LOOKUP,deny file,log 13=permission denied,log BMAP,log 38=function not implemented"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	ps := NewProtocolServer(&inoFS{NewDefaultRawFileSystem()}, &MountOptions{
+		Slog: &SlogOptions{
+			Handler:    slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			Level:      slog.LevelDebug,
+			ErrorLevel: slog.LevelWarn,
+			SampleRate: 2,
+		},
+	})
+
+	var attrOut AttrOut
+	var entryOut EntryOut
+	for i := 0; i < 4; i++ {
+		in := GetAttrIn{
+			InHeader: InHeader{
+				Length: uint32(unsafe.Sizeof(GetAttrIn{})),
+				Opcode: _OP_GETATTR,
+				Unique: uint64(2 + i),
+				NodeId: 7,
+			},
+		}
+		serveRequest(t, ps, &in, &attrOut)
+	}
+	lookup := InHeader{
+		Length: uint32(unsafe.Sizeof(InHeader{})) + 5,
+		Opcode: _OP_LOOKUP,
+		Unique: 10,
+		NodeId: 1,
+	}
+	serveRequest(t, ps, &lookup, &entryOut, []byte("file\x00"))
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+
+	// Two of four GETATTRs are sampled, and the failing LOOKUP
+	// reply is always logged.
+	var got []string
+	for _, r := range records {
+		got = append(got, fmt.Sprintf("%s %s %s %v", r["level"], r["msg"], r["op"], r["unique"]))
+	}
+	want := []string{
+		"DEBUG rx GETATTR 3",
+		"DEBUG tx GETATTR 3",
+		"DEBUG rx GETATTR 5",
+		"DEBUG tx GETATTR 5",
+		"WARN tx LOOKUP 10",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got records\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r := records[1]; !strings.Contains(fmt.Sprint(r["output"]), "i0:7") {
+		t.Errorf("GETATTR output %v does not mention ino", r["output"])
+	}
+	if r := records[4]; r["errno"] != float64(ENOSYS) {
+		t.Errorf("LOOKUP errno %v, want %d", r["errno"], ENOSYS)
+	}
+}
+
+func TestWatchdogExpectsReply(t *testing.T) {
+	for op, want := range map[uint32]bool{
+		_OP_LOOKUP:       true,
+		_OP_READ:         true,
+		_OP_FORGET:       false,
+		_OP_BATCH_FORGET: false,
+		_OP_INTERRUPT:    false,
+		_OP_NOTIFY_REPLY: false,
+	} {
+		if got := expectsReply(op); got != want {
+			t.Errorf("expectsReply(%s): got %v, want %v", operationName(op), got, want)
+		}
+	}
+}
+
+// doneResult records whether Done was called.
+type doneResult struct {
+	ReadResult
+	done bool
+}
+
+func (r *doneResult) Done() {
+	r.done = true
+}
+
+type resultFS struct {
+	RawFileSystem
+	result *doneResult
+}
+
+func (fs *resultFS) Read(cancel <-chan struct{}, in *ReadIn, buf []byte) (ReadResult, Status) {
+	return fs.result, OK
+}
+
+func TestRecoverPanicsInterceptor(t *testing.T) {
+	result := &doneResult{ReadResult: ReadResultData([]byte("hello"))}
+	var gotRequest string
+	ps := NewProtocolServer(&resultFS{NewDefaultRawFileSystem(), result}, &MountOptions{
+		RecoverPanics: true,
+		Logger:        log.New(io.Discard, "", 0),
+		OnPanic: func(request string, value interface{}, stack []byte) bool {
+			gotRequest = request
+			return false
+		},
+		Interceptors: []Interceptor{
+			func(info *RequestInfo, handler func() Status) Status {
+				handler()
+				panic("interceptor")
+			},
+		},
+	})
+
+	in := ReadIn{
+		InHeader: InHeader{
+			Length: uint32(unsafe.Sizeof(ReadIn{})),
+			Opcode: _OP_READ,
+			Unique: 2,
+			NodeId: 7,
+		},
+		Size: 5,
+	}
+	outHeader := serveRequest(t, ps, &in, make([]byte, 5))
+	if outHeader.Status != -int32(EIO) {
+		t.Errorf("got status %d, want %d", outHeader.Status, -int32(EIO))
+	}
+	if !strings.Contains(gotRequest, "READ") {
+		t.Errorf("OnPanic got request %q", gotRequest)
+	}
+	if !result.done {
+		t.Error("ReadResult.Done was not called for the dropped result")
+	}
+}
@@ -0,0 +1,490 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import "context"
+
+// RawFileSystemCtx is like RawFileSystem, but its methods take a
+// context.Context rather than a cancel channel. The context carries
+// the Caller (see FromContext), the unique ID of the request (see
+// UniqueFromContext), and is canceled if the kernel interrupts the
+// request. Contexts derived from it, for example to add a deadline or
+// tracing data, can be passed on to other RawFileSystemCtx
+// implementations.
+//
+// The methods are documented on RawFileSystem. Use
+// NewRawFileSystemFromCtx to serve a RawFileSystemCtx, and
+// NewRawFileSystemCtx to convert a RawFileSystem.
+type RawFileSystemCtx interface {
+	String() string
+	SetDebug(debug bool)
+
+	Lookup(ctx context.Context, header *InHeader, name string, out *EntryOut) (status Status)
+	Forget(nodeid, nlookup uint64)
+
+	GetAttr(ctx context.Context, input *GetAttrIn, out *AttrOut) (code Status)
+	SetAttr(ctx context.Context, input *SetAttrIn, out *AttrOut) (code Status)
+
+	Mknod(ctx context.Context, input *MknodIn, name string, out *EntryOut) (code Status)
+	Mkdir(ctx context.Context, input *MkdirIn, name string, out *EntryOut) (code Status)
+	Unlink(ctx context.Context, header *InHeader, name string) (code Status)
+	Rmdir(ctx context.Context, header *InHeader, name string) (code Status)
+	Rename(ctx context.Context, input *RenameIn, oldName string, newName string) (code Status)
+	Link(ctx context.Context, input *LinkIn, filename string, out *EntryOut) (code Status)
+	Symlink(ctx context.Context, header *InHeader, pointedTo string, linkName string, out *EntryOut) (code Status)
+	Readlink(ctx context.Context, header *InHeader) (out []byte, code Status)
+	Access(ctx context.Context, input *AccessIn) (code Status)
+
+	GetXAttr(ctx context.Context, header *InHeader, attr string, dest []byte) (sz uint32, code Status)
+	ListXAttr(ctx context.Context, header *InHeader, dest []byte) (uint32, Status)
+	SetXAttr(ctx context.Context, input *SetXAttrIn, attr string, data []byte) Status
+	RemoveXAttr(ctx context.Context, header *InHeader, attr string) (code Status)
+
+	Create(ctx context.Context, input *CreateIn, name string, out *CreateOut) (code Status)
+	TmpFile(ctx context.Context, input *CreateIn, name string, out *CreateOut) (code Status)
+	Open(ctx context.Context, input *OpenIn, out *OpenOut) (status Status)
+	Read(ctx context.Context, input *ReadIn, buf []byte) (ReadResult, Status)
+	Lseek(ctx context.Context, in *LseekIn, out *LseekOut) Status
+
+	GetLk(ctx context.Context, input *LkIn, out *LkOut) (code Status)
+	SetLk(ctx context.Context, input *LkIn) (code Status)
+	SetLkw(ctx context.Context, input *LkIn) (code Status)
+
+	Release(ctx context.Context, input *ReleaseIn)
+	Write(ctx context.Context, input *WriteIn, data []byte) (written uint32, code Status)
+	CopyFileRange(ctx context.Context, input *CopyFileRangeIn) (written uint32, code Status)
+	CopyFileRange64(ctx context.Context, input *CopyFileRangeIn) (written uint64, code Status)
+	Ioctl(ctx context.Context, input *IoctlIn, inbuf []byte, output *IoctlOut, outbuf []byte) (code Status)
+	Poll(ctx context.Context, input *PollIn, out *PollOut) (code Status)
+	Flush(ctx context.Context, input *FlushIn) Status
+	Fsync(ctx context.Context, input *FsyncIn) (code Status)
+	Fallocate(ctx context.Context, input *FallocateIn) (code Status)
+
+	OpenDir(ctx context.Context, input *OpenIn, out *OpenOut) (status Status)
+	ReadDir(ctx context.Context, input *ReadIn, out *DirEntryList) Status
+	ReadDirPlus(ctx context.Context, input *ReadIn, out *DirEntryList) Status
+	ReleaseDir(input *ReleaseIn)
+	FsyncDir(ctx context.Context, input *FsyncIn) (code Status)
+
+	StatFs(ctx context.Context, input *InHeader, out *StatfsOut) (code Status)
+	SyncFs(ctx context.Context, input *SyncFsIn) (code Status)
+	Statx(ctx context.Context, input *StatxIn, out *StatxOut) (code Status)
+
+	Init(*Server)
+	OnUnmount()
+}
+
+// NewRawFileSystemFromCtx returns a RawFileSystem that serves
+// requests through fs. Each call is passed a *Context for the
+// request.
+func NewRawFileSystemFromCtx(fs RawFileSystemCtx) RawFileSystem {
+	if c, ok := fs.(*ctxFromRaw); ok {
+		return c.fs
+	}
+	return &rawFromCtx{fs}
+}
+
+// NewRawFileSystemCtx returns a RawFileSystemCtx that calls fs,
+// passing ctx.Done() as the cancel channel. If fs was returned by
+// NewRawFileSystemFromCtx, the original RawFileSystemCtx is returned.
+// If fs has a method
+//
+//	RawFileSystemCtx() RawFileSystemCtx
+//
+// its result is returned, so file systems can implement both APIs.
+// The Server uses this to pass its contexts to the file system.
+func NewRawFileSystemCtx(fs RawFileSystem) RawFileSystemCtx {
+	if r, ok := fs.(*rawFromCtx); ok {
+		return r.fs
+	}
+	if p, ok := fs.(rawFileSystemCtxer); ok {
+		return p.RawFileSystemCtx()
+	}
+	return &ctxFromRaw{fs}
+}
+
+// rawFileSystemCtxer is implemented by RawFileSystems that also
+// implement RawFileSystemCtx.
+type rawFileSystemCtxer interface {
+	RawFileSystemCtx() RawFileSystemCtx
+}
+
+// rawFromCtx adapts a RawFileSystemCtx to RawFileSystem.
+type rawFromCtx struct {
+	fs RawFileSystemCtx
+}
+
+func (r *rawFromCtx) String() string {
+	return r.fs.String()
+}
+
+func (r *rawFromCtx) SetDebug(debug bool) {
+	r.fs.SetDebug(debug)
+}
+
+func (r *rawFromCtx) Forget(nodeid, nlookup uint64) {
+	r.fs.Forget(nodeid, nlookup)
+}
+
+func (r *rawFromCtx) ReleaseDir(input *ReleaseIn) {
+	r.fs.ReleaseDir(input)
+}
+
+func (r *rawFromCtx) Init(s *Server) {
+	r.fs.Init(s)
+}
+
+func (r *rawFromCtx) OnUnmount() {
+	r.fs.OnUnmount()
+}
+
+func (r *rawFromCtx) Lookup(cancel <-chan struct{}, header *InHeader, name string, out *EntryOut) (status Status) {
+	return r.fs.Lookup(newContext(cancel, header), header, name, out)
+}
+
+func (r *rawFromCtx) GetAttr(cancel <-chan struct{}, input *GetAttrIn, out *AttrOut) (code Status) {
+	return r.fs.GetAttr(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) SetAttr(cancel <-chan struct{}, input *SetAttrIn, out *AttrOut) (code Status) {
+	return r.fs.SetAttr(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) Mknod(cancel <-chan struct{}, input *MknodIn, name string, out *EntryOut) (code Status) {
+	return r.fs.Mknod(newContext(cancel, &input.InHeader), input, name, out)
+}
+
+func (r *rawFromCtx) Mkdir(cancel <-chan struct{}, input *MkdirIn, name string, out *EntryOut) (code Status) {
+	return r.fs.Mkdir(newContext(cancel, &input.InHeader), input, name, out)
+}
+
+func (r *rawFromCtx) Unlink(cancel <-chan struct{}, header *InHeader, name string) (code Status) {
+	return r.fs.Unlink(newContext(cancel, header), header, name)
+}
+
+func (r *rawFromCtx) Rmdir(cancel <-chan struct{}, header *InHeader, name string) (code Status) {
+	return r.fs.Rmdir(newContext(cancel, header), header, name)
+}
+
+func (r *rawFromCtx) Rename(cancel <-chan struct{}, input *RenameIn, oldName string, newName string) (code Status) {
+	return r.fs.Rename(newContext(cancel, &input.InHeader), input, oldName, newName)
+}
+
+func (r *rawFromCtx) Link(cancel <-chan struct{}, input *LinkIn, filename string, out *EntryOut) (code Status) {
+	return r.fs.Link(newContext(cancel, &input.InHeader), input, filename, out)
+}
+
+func (r *rawFromCtx) Symlink(cancel <-chan struct{}, header *InHeader, pointedTo string, linkName string, out *EntryOut) (code Status) {
+	return r.fs.Symlink(newContext(cancel, header), header, pointedTo, linkName, out)
+}
+
+func (r *rawFromCtx) Readlink(cancel <-chan struct{}, header *InHeader) (out []byte, code Status) {
+	return r.fs.Readlink(newContext(cancel, header), header)
+}
+
+func (r *rawFromCtx) Access(cancel <-chan struct{}, input *AccessIn) (code Status) {
+	return r.fs.Access(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) GetXAttr(cancel <-chan struct{}, header *InHeader, attr string, dest []byte) (sz uint32, code Status) {
+	return r.fs.GetXAttr(newContext(cancel, header), header, attr, dest)
+}
+
+func (r *rawFromCtx) ListXAttr(cancel <-chan struct{}, header *InHeader, dest []byte) (uint32, Status) {
+	return r.fs.ListXAttr(newContext(cancel, header), header, dest)
+}
+
+func (r *rawFromCtx) SetXAttr(cancel <-chan struct{}, input *SetXAttrIn, attr string, data []byte) Status {
+	return r.fs.SetXAttr(newContext(cancel, &input.InHeader), input, attr, data)
+}
+
+func (r *rawFromCtx) RemoveXAttr(cancel <-chan struct{}, header *InHeader, attr string) (code Status) {
+	return r.fs.RemoveXAttr(newContext(cancel, header), header, attr)
+}
+
+func (r *rawFromCtx) Create(cancel <-chan struct{}, input *CreateIn, name string, out *CreateOut) (code Status) {
+	return r.fs.Create(newContext(cancel, &input.InHeader), input, name, out)
+}
+
+func (r *rawFromCtx) TmpFile(cancel <-chan struct{}, input *CreateIn, name string, out *CreateOut) (code Status) {
+	return r.fs.TmpFile(newContext(cancel, &input.InHeader), input, name, out)
+}
+
+func (r *rawFromCtx) Open(cancel <-chan struct{}, input *OpenIn, out *OpenOut) (status Status) {
+	return r.fs.Open(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) Read(cancel <-chan struct{}, input *ReadIn, buf []byte) (ReadResult, Status) {
+	return r.fs.Read(newContext(cancel, &input.InHeader), input, buf)
+}
+
+func (r *rawFromCtx) Lseek(cancel <-chan struct{}, in *LseekIn, out *LseekOut) Status {
+	return r.fs.Lseek(newContext(cancel, &in.InHeader), in, out)
+}
+
+func (r *rawFromCtx) GetLk(cancel <-chan struct{}, input *LkIn, out *LkOut) (code Status) {
+	return r.fs.GetLk(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) SetLk(cancel <-chan struct{}, input *LkIn) (code Status) {
+	return r.fs.SetLk(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) SetLkw(cancel <-chan struct{}, input *LkIn) (code Status) {
+	return r.fs.SetLkw(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) Release(cancel <-chan struct{}, input *ReleaseIn) {
+	r.fs.Release(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) Write(cancel <-chan struct{}, input *WriteIn, data []byte) (written uint32, code Status) {
+	return r.fs.Write(newContext(cancel, &input.InHeader), input, data)
+}
+
+func (r *rawFromCtx) CopyFileRange(cancel <-chan struct{}, input *CopyFileRangeIn) (written uint32, code Status) {
+	return r.fs.CopyFileRange(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) CopyFileRange64(cancel <-chan struct{}, input *CopyFileRangeIn) (written uint64, code Status) {
+	return r.fs.CopyFileRange64(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) Ioctl(cancel <-chan struct{}, input *IoctlIn, inbuf []byte, output *IoctlOut, outbuf []byte) (code Status) {
+	return r.fs.Ioctl(newContext(cancel, &input.InHeader), input, inbuf, output, outbuf)
+}
+
+func (r *rawFromCtx) Poll(cancel <-chan struct{}, input *PollIn, out *PollOut) (code Status) {
+	return r.fs.Poll(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) Flush(cancel <-chan struct{}, input *FlushIn) Status {
+	return r.fs.Flush(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) Fsync(cancel <-chan struct{}, input *FsyncIn) (code Status) {
+	return r.fs.Fsync(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) Fallocate(cancel <-chan struct{}, input *FallocateIn) (code Status) {
+	return r.fs.Fallocate(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) OpenDir(cancel <-chan struct{}, input *OpenIn, out *OpenOut) (status Status) {
+	return r.fs.OpenDir(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) ReadDir(cancel <-chan struct{}, input *ReadIn, out *DirEntryList) Status {
+	return r.fs.ReadDir(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) ReadDirPlus(cancel <-chan struct{}, input *ReadIn, out *DirEntryList) Status {
+	return r.fs.ReadDirPlus(newContext(cancel, &input.InHeader), input, out)
+}
+
+func (r *rawFromCtx) FsyncDir(cancel <-chan struct{}, input *FsyncIn) (code Status) {
+	return r.fs.FsyncDir(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) StatFs(cancel <-chan struct{}, input *InHeader, out *StatfsOut) (code Status) {
+	return r.fs.StatFs(newContext(cancel, input), input, out)
+}
+
+func (r *rawFromCtx) SyncFs(cancel <-chan struct{}, input *SyncFsIn) (code Status) {
+	return r.fs.SyncFs(newContext(cancel, &input.InHeader), input)
+}
+
+func (r *rawFromCtx) Statx(cancel <-chan struct{}, input *StatxIn, out *StatxOut) (code Status) {
+	return r.fs.Statx(newContext(cancel, &input.InHeader), input, out)
+}
+
+// ctxFromRaw adapts a RawFileSystem to RawFileSystemCtx.
+type ctxFromRaw struct {
+	fs RawFileSystem
+}
+
+func (c *ctxFromRaw) String() string {
+	return c.fs.String()
+}
+
+func (c *ctxFromRaw) SetDebug(debug bool) {
+	c.fs.SetDebug(debug)
+}
+
+func (c *ctxFromRaw) Forget(nodeid, nlookup uint64) {
+	c.fs.Forget(nodeid, nlookup)
+}
+
+func (c *ctxFromRaw) ReleaseDir(input *ReleaseIn) {
+	c.fs.ReleaseDir(input)
+}
+
+func (c *ctxFromRaw) Init(s *Server) {
+	c.fs.Init(s)
+}
+
+func (c *ctxFromRaw) OnUnmount() {
+	c.fs.OnUnmount()
+}
+
+func (c *ctxFromRaw) Lookup(ctx context.Context, header *InHeader, name string, out *EntryOut) (status Status) {
+	return c.fs.Lookup(ctx.Done(), header, name, out)
+}
+
+func (c *ctxFromRaw) GetAttr(ctx context.Context, input *GetAttrIn, out *AttrOut) (code Status) {
+	return c.fs.GetAttr(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) SetAttr(ctx context.Context, input *SetAttrIn, out *AttrOut) (code Status) {
+	return c.fs.SetAttr(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) Mknod(ctx context.Context, input *MknodIn, name string, out *EntryOut) (code Status) {
+	return c.fs.Mknod(ctx.Done(), input, name, out)
+}
+
+func (c *ctxFromRaw) Mkdir(ctx context.Context, input *MkdirIn, name string, out *EntryOut) (code Status) {
+	return c.fs.Mkdir(ctx.Done(), input, name, out)
+}
+
+func (c *ctxFromRaw) Unlink(ctx context.Context, header *InHeader, name string) (code Status) {
+	return c.fs.Unlink(ctx.Done(), header, name)
+}
+
+func (c *ctxFromRaw) Rmdir(ctx context.Context, header *InHeader, name string) (code Status) {
+	return c.fs.Rmdir(ctx.Done(), header, name)
+}
+
+func (c *ctxFromRaw) Rename(ctx context.Context, input *RenameIn, oldName string, newName string) (code Status) {
+	return c.fs.Rename(ctx.Done(), input, oldName, newName)
+}
+
+func (c *ctxFromRaw) Link(ctx context.Context, input *LinkIn, filename string, out *EntryOut) (code Status) {
+	return c.fs.Link(ctx.Done(), input, filename, out)
+}
+
+func (c *ctxFromRaw) Symlink(ctx context.Context, header *InHeader, pointedTo string, linkName string, out *EntryOut) (code Status) {
+	return c.fs.Symlink(ctx.Done(), header, pointedTo, linkName, out)
+}
+
+func (c *ctxFromRaw) Readlink(ctx context.Context, header *InHeader) (out []byte, code Status) {
+	return c.fs.Readlink(ctx.Done(), header)
+}
+
+func (c *ctxFromRaw) Access(ctx context.Context, input *AccessIn) (code Status) {
+	return c.fs.Access(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) GetXAttr(ctx context.Context, header *InHeader, attr string, dest []byte) (sz uint32, code Status) {
+	return c.fs.GetXAttr(ctx.Done(), header, attr, dest)
+}
+
+func (c *ctxFromRaw) ListXAttr(ctx context.Context, header *InHeader, dest []byte) (uint32, Status) {
+	return c.fs.ListXAttr(ctx.Done(), header, dest)
+}
+
+func (c *ctxFromRaw) SetXAttr(ctx context.Context, input *SetXAttrIn, attr string, data []byte) Status {
+	return c.fs.SetXAttr(ctx.Done(), input, attr, data)
+}
+
+func (c *ctxFromRaw) RemoveXAttr(ctx context.Context, header *InHeader, attr string) (code Status) {
+	return c.fs.RemoveXAttr(ctx.Done(), header, attr)
+}
+
+func (c *ctxFromRaw) Create(ctx context.Context, input *CreateIn, name string, out *CreateOut) (code Status) {
+	return c.fs.Create(ctx.Done(), input, name, out)
+}
+
+func (c *ctxFromRaw) TmpFile(ctx context.Context, input *CreateIn, name string, out *CreateOut) (code Status) {
+	return c.fs.TmpFile(ctx.Done(), input, name, out)
+}
+
+func (c *ctxFromRaw) Open(ctx context.Context, input *OpenIn, out *OpenOut) (status Status) {
+	return c.fs.Open(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) Read(ctx context.Context, input *ReadIn, buf []byte) (ReadResult, Status) {
+	return c.fs.Read(ctx.Done(), input, buf)
+}
+
+func (c *ctxFromRaw) Lseek(ctx context.Context, in *LseekIn, out *LseekOut) Status {
+	return c.fs.Lseek(ctx.Done(), in, out)
+}
+
+func (c *ctxFromRaw) GetLk(ctx context.Context, input *LkIn, out *LkOut) (code Status) {
+	return c.fs.GetLk(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) SetLk(ctx context.Context, input *LkIn) (code Status) {
+	return c.fs.SetLk(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) SetLkw(ctx context.Context, input *LkIn) (code Status) {
+	return c.fs.SetLkw(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) Release(ctx context.Context, input *ReleaseIn) {
+	c.fs.Release(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) Write(ctx context.Context, input *WriteIn, data []byte) (written uint32, code Status) {
+	return c.fs.Write(ctx.Done(), input, data)
+}
+
+func (c *ctxFromRaw) CopyFileRange(ctx context.Context, input *CopyFileRangeIn) (written uint32, code Status) {
+	return c.fs.CopyFileRange(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) CopyFileRange64(ctx context.Context, input *CopyFileRangeIn) (written uint64, code Status) {
+	return c.fs.CopyFileRange64(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) Ioctl(ctx context.Context, input *IoctlIn, inbuf []byte, output *IoctlOut, outbuf []byte) (code Status) {
+	return c.fs.Ioctl(ctx.Done(), input, inbuf, output, outbuf)
+}
+
+func (c *ctxFromRaw) Poll(ctx context.Context, input *PollIn, out *PollOut) (code Status) {
+	return c.fs.Poll(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) Flush(ctx context.Context, input *FlushIn) Status {
+	return c.fs.Flush(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) Fsync(ctx context.Context, input *FsyncIn) (code Status) {
+	return c.fs.Fsync(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) Fallocate(ctx context.Context, input *FallocateIn) (code Status) {
+	return c.fs.Fallocate(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) OpenDir(ctx context.Context, input *OpenIn, out *OpenOut) (status Status) {
+	return c.fs.OpenDir(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) ReadDir(ctx context.Context, input *ReadIn, out *DirEntryList) Status {
+	return c.fs.ReadDir(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) ReadDirPlus(ctx context.Context, input *ReadIn, out *DirEntryList) Status {
+	return c.fs.ReadDirPlus(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) FsyncDir(ctx context.Context, input *FsyncIn) (code Status) {
+	return c.fs.FsyncDir(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) StatFs(ctx context.Context, input *InHeader, out *StatfsOut) (code Status) {
+	return c.fs.StatFs(ctx.Done(), input, out)
+}
+
+func (c *ctxFromRaw) SyncFs(ctx context.Context, input *SyncFsIn) (code Status) {
+	return c.fs.SyncFs(ctx.Done(), input)
+}
+
+func (c *ctxFromRaw) Statx(ctx context.Context, input *StatxIn, out *StatxOut) (code Status) {
+	return c.fs.Statx(ctx.Done(), input, out)
+}
@@ -0,0 +1,232 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Recordings of FUSE traffic, see MountOptions.RecordTraffic, have
+// the following format. All integers are little-endian.
+//
+// The recording starts with the 8 bytes of recordMagic, "GOFUSE\x00\x01".
+// The last byte is the format version.
+//
+// It is followed by records, each consisting of a 16-byte header
+//
+//	kind   uint32 // RecordRequest or RecordReply
+//	length uint32 // number of data bytes following the header
+//	time   int64  // nanoseconds since the Unix epoch
+//
+// and the data. The data of a request is the message as read from
+// the kernel, starting with the InHeader. The data of a reply is the
+// message as written to the kernel, starting with the OutHeader.
+// READ replies contain the data, even if it was spliced. The first
+// record is the INIT request.
+const recordMagic = "GOFUSE\x00\x01"
+
+// Kinds of records in a traffic recording.
+const (
+	RecordRequest = 1
+	RecordReply   = 2
+)
+
+// TrafficRecord is a message in a traffic recording.
+type TrafficRecord struct {
+	Kind uint32
+	Time time.Time
+	Data []byte
+}
+
+type recordHeader struct {
+	Kind   uint32
+	Length uint32
+	Time   int64
+}
+
+// recorder writes traffic recordings.
+type recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+func newRecorder(w io.Writer) *recorder {
+	r := &recorder{w: w}
+	_, r.err = io.WriteString(w, recordMagic)
+	return r
+}
+
+// record writes the concatenation of iov as one record.
+func (r *recorder) record(kind uint32, iov ...[]byte) {
+	hdr := recordHeader{
+		Kind:   kind,
+		Length: uint32(iovLen(iov)),
+		Time:   time.Now().UnixNano(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = binary.Write(r.w, binary.LittleEndian, &hdr)
+	for _, b := range iov {
+		if r.err == nil {
+			_, r.err = r.w.Write(b)
+		}
+	}
+}
+
+// recordReply records the reply for req. READ results are converted
+// to bytes, so they can be recorded.
+func (ms *Server) recordReply(req *request) {
+	if req.readResult != nil {
+		req.outPayload, req.status = req.readResult.Bytes(req.outPayload)
+		req.readResult.Done()
+		req.readResult = nil
+		req.serializeHeader(len(req.outPayload))
+	}
+	ms.recorder.record(RecordReply, req.outHeaderBuf, req.outDataBuf, req.outPayload)
+}
+
+// ReadRecording reads a recording written through
+// MountOptions.RecordTraffic. If the recording is truncated, the
+// complete records are returned along with the error.
+func ReadRecording(r io.Reader) ([]TrafficRecord, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != recordMagic {
+		return nil, fmt.Errorf("not a FUSE traffic recording: magic %q", magic)
+	}
+
+	var recs []TrafficRecord
+	for {
+		var hdr recordHeader
+		if err := binary.Read(br, binary.LittleEndian, &hdr); err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return recs, err
+		}
+		data := make([]byte, hdr.Length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return recs, err
+		}
+		recs = append(recs, TrafficRecord{
+			Kind: hdr.Kind,
+			Time: time.Unix(0, hdr.Time),
+			Data: data,
+		})
+	}
+}
+
+// ReplayDiff describes a request for which the replayed reply differs
+// from the recorded one.
+type ReplayDiff struct {
+	Unique uint64
+	Op     string
+
+	// Want is the recorded reply, and Got the replayed one. Either
+	// is nil if there was no reply.
+	Want []byte
+	Got  []byte
+}
+
+func (d *ReplayDiff) String() string {
+	return fmt.Sprintf("%d %s: got %s, want %s", d.Unique, d.Op, describeReply(d.Got), describeReply(d.Want))
+}
+
+func describeReply(b []byte) string {
+	if b == nil {
+		return "no reply"
+	}
+	if len(b) < int(sizeOfOutHeader) {
+		return fmt.Sprintf("short reply %x", b)
+	}
+	hdr := (*OutHeader)(unsafe.Pointer(&b[0]))
+	return fmt.Sprintf("status %v, %d bytes %x", Status(-hdr.Status), len(b), b[sizeOfOutHeader:])
+}
+
+// Replay serves the requests of a recording, in order, through a
+// ProtocolServer for fs, and compares its replies with the recorded
+// ones. It returns the requests whose reply differs. Requests that
+// the ProtocolServer cannot parse are reported with a nil Got. To
+// reproduce the INIT reply, opts should match the options that the
+// recording Server was created with.
+func Replay(recs []TrafficRecord, fs RawFileSystem, opts *MountOptions) []ReplayDiff {
+	want := map[uint64][]byte{}
+	for _, rec := range recs {
+		if rec.Kind == RecordReply && len(rec.Data) >= int(sizeOfOutHeader) {
+			want[(*OutHeader)(unsafe.Pointer(&rec.Data[0])).Unique] = rec.Data
+		}
+	}
+
+	o := serverOptions(fs, opts)
+	o.RecordTraffic = nil
+	ps := NewProtocolServer(fs, &o)
+	var diffs []ReplayDiff
+	for _, rec := range recs {
+		if rec.Kind != RecordRequest || len(rec.Data) < int(unsafe.Sizeof(InHeader{})) {
+			continue
+		}
+		hdr := (*InHeader)(unsafe.Pointer(&rec.Data[0]))
+		got := ps.replay(rec.Data)
+		if w := want[hdr.Unique]; !bytes.Equal(got, w) {
+			diffs = append(diffs, ReplayDiff{
+				Unique: hdr.Unique,
+				Op:     operationName(hdr.Opcode),
+				Want:   w,
+				Got:    got,
+			})
+		}
+	}
+	return diffs
+}
+
+// maxReplayPayload is the largest reply payload, besides READ data,
+// that Replay provides room for. It is the maximum size of an
+// extended attribute value.
+const maxReplayPayload = 1 << 16
+
+// replay serves one recorded request, and returns the reply, or nil
+// if there is none.
+func (ps *ProtocolServer) replay(in []byte) []byte {
+	h, _, outSize, outPayloadSize, errno := parseRequest(in, &ps.kernelSettings)
+	if outPayloadSize > max(ps.opts.MaxWrite, maxReplayPayload) {
+		// The kernel never asks for this much, so the
+		// recording is corrupt.
+		return nil
+	}
+	if errno != OK || h.SuppressReply {
+		if errno == OK {
+			ps.HandleRequest([][]byte{in}, nil)
+		}
+		return nil
+	}
+
+	out := make([]byte, int(sizeOfOutHeader)+outSize+outPayloadSize)
+	iov := [][]byte{out[:sizeOfOutHeader]}
+	if outSize > 0 {
+		iov = append(iov, out[sizeOfOutHeader:int(sizeOfOutHeader)+outSize])
+	}
+	if outPayloadSize > 0 {
+		iov = append(iov, out[int(sizeOfOutHeader)+outSize:])
+	}
+	n, st := ps.HandleRequest([][]byte{in}, iov)
+	if st != OK || n == 0 {
+		return nil
+	}
+	return out[:n]
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unsafe"
 )
@@ -26,6 +27,25 @@ type request struct {
 	// written under Server.interruptMu
 	interrupted bool
 
+	// abandoned is set if the reply is no longer ours to send:
+	// either the kernel requeued the request through
+	// Server.ResendPending, or the watchdog already answered
+	// it. The reply from the handler is then dropped.
+	abandoned atomic.Bool
+
+	// stuck is set once the watchdog has reported the request.
+	// written under Server.interruptMu
+	stuck bool
+
+	// uring is set for requests exchanged through io_uring. The
+	// kernel does not requeue these on NOTIFY_RESEND.
+	uring bool
+
+	// refused is set for requests that arrive while the server
+	// shuts down. They are answered with ENOTCONN instead of
+	// being passed to the file system.
+	refused bool
+
 	// inHeader + opcode specific data
 	inputBuf []byte
 
@@ -38,6 +58,9 @@ type request struct {
 	// Unstructured input (filenames, data for WRITE call)
 	inPayload []byte
 
+	// Extensions appended to the request, if any.
+	ext *requestExtensions
+
 	// Output data.
 	status Status
 
@@ -47,6 +70,10 @@ type request struct {
 
 	// Start timestamp for timing info.
 	startTime time.Time
+
+	// The device file descriptor the request was read from. The
+	// reply must be written to the same file descriptor.
+	fd int
 }
 
 // requestAlloc holds the request, plus I/O buffers, which are
@@ -82,10 +109,15 @@ func (r *request) outHeader() *OutHeader {
 // TODO - benchmark to see if this is necessary?
 func (r *request) clear() {
 	r.suppressReply = false
+	r.abandoned.Store(false)
+	r.stuck = false
+	r.uring = false
+	r.refused = false
 	r.inputBuf = nil
 	r.outHeaderBuf = nil
 	r.outDataBuf = nil
 	r.inPayload = nil
+	r.ext = nil
 	r.status = OK
 	r.outPayload = nil
 	r.startTime = time.Time{}
@@ -228,7 +260,7 @@ func parseRequest(in []byte, kernelSettings *InitIn) (h *operationHandler, inSiz
 	}
 	if hdr.Opcode == _OP_INIT && inSize > len(in) {
 		// Minor version 36 extended the size of InitIn struct
-		inSize = len(in)
+		inSize = max(len(in), int(unsafe.Offsetof(InitIn{}.Flags2)))
 	}
 	if len(in) < inSize {
 		log.Printf("Short read for %v: %q", h.Name, in)
@@ -280,6 +312,34 @@ func (r *request) filenames() (string, string) {
 	return s1, s2
 }
 
+// validNames checks that the payload starts with the names that the
+// opcode takes, as the kernel sends them: non-empty and
+// NUL-terminated. Directory entry names also cannot contain '/' or be
+// "." or "..".
+func (r *request) validNames(h *operationHandler) bool {
+	op := r.inHeader().Opcode
+	rest := r.inPayload
+	for i := 0; i < h.FileNames; i++ {
+		idx := bytes.IndexByte(rest, 0)
+		if idx <= 0 {
+			return false
+		}
+		name := string(rest[:idx])
+		rest = rest[idx+1:]
+		switch {
+		case op == _OP_SETXATTR || op == _OP_GETXATTR || op == _OP_REMOVEXATTR:
+			// Attribute names.
+		case op == _OP_SYMLINK && i == 1:
+			// The link target.
+		case op == _OP_TMPFILE:
+			// The kernel passes "/".
+		case strings.Contains(name, "/") || name == "." || name == "..":
+			return false
+		}
+	}
+	return true
+}
+
 // serializeHeader serializes the response header. The header points
 // to an internal buffer of the receiver.
 func (r *request) serializeHeader(outPayloadSize int) {
@@ -293,7 +353,7 @@ func (r *request) serializeHeader(outPayloadSize int) {
 	// The InitOut structure has 24 bytes (ie. TimeGran and
 	// further fields not available) in fuse version <= 22.
 	// https://john-millikin.com/the-fuse-protocol#FUSE_INIT
-	if r.inHeader().Opcode == _OP_INIT {
+	if r.inHeader().Opcode == _OP_INIT && len(r.outDataBuf) > 0 {
 		out := (*InitOut)(r.outData())
 		if out.Minor <= 22 {
 			r.outDataBuf = r.outDataBuf[:24]
@@ -45,6 +45,22 @@ type Server struct {
 	// Empty if unmounted.
 	mountPoint string
 
+	// unmountMu serializes Unmount, which may be called by
+	// Shutdown and by ServeSystemd on a signal.
+	unmountMu sync.Mutex
+
+	// detachedFd is the file descriptor of a detached mount that
+	// has not been attached yet, or -1.
+	detachedFd int
+
+	// detachedClosed is set once Unmount has closed detachedFd, so
+	// a later Unmount waits for the mount to go away again.
+	detachedClosed bool
+
+	// mountNs identifies the mount namespace in which AttachMount
+	// attached the mount. It is empty for other mounts.
+	mountNs string
+
 	// writeMu serializes close and notify writes
 	writeMu sync.Mutex
 
@@ -63,20 +79,46 @@ type Server struct {
 	reqPool sync.Pool
 
 	// Pool for raw requests data
-	readPool   sync.Pool
-	reqMu      sync.Mutex
-	reqReaders int
+	readPool sync.Pool
+	reqMu    sync.Mutex
+
+	// queues holds the device file descriptors that requests are
+	// read from. queues[0] reads from mountFd; the others are
+	// clones of it.
+	queues []*readQueue
 
 	singleReader bool
 	canSplice    bool
 	loops        sync.WaitGroup
-	serving      bool // for preventing duplicate Serve() calls
+
+	// serving is set by Serve, for preventing duplicate Serve()
+	// calls. Protected by reqMu.
+	serving bool
+
+	// handingOff is set by Handoff to make the readers exit.
+	// Protected by reqMu.
+	handingOff bool
+
+	// activeLoops is the number of running reader loops, which
+	// Handoff waits to drop to zero. Protected by reqMu.
+	activeLoops int
 
 	// Used to implement WaitMount on macos.
 	ready chan error
 
+	// recorder is set if MountOptions.RecordTraffic is set.
+	recorder *recorder
+
 	// for implementing single threaded processing.
 	requestProcessingMu sync.Mutex
+
+	// drain tracks requests for Shutdown.
+	drainMu sync.Mutex
+	drain   drainState
+
+	// systemd is set if the server was started by ServeSystemd
+	// under systemd.
+	systemd *systemdNotifier
 }
 
 // SetDebug is deprecated. Use MountOptions.Debug instead.
@@ -110,7 +152,8 @@ func (ms *Server) RecordLatencies(l LatencyMap) {
 
 // Unmount calls fusermount -u on the mount. This has the effect of
 // shutting down the filesystem. After the Server is unmounted, it
-// should be discarded.  This function is idempotent.
+// should be discarded.  This function is idempotent, and calls are
+// serialized.
 //
 // Does not work when we were mounted with the magic /dev/fd/N mountpoint syntax,
 // as we do not know the real mountpoint. Unmount using
@@ -119,12 +162,45 @@ func (ms *Server) RecordLatencies(l LatencyMap) {
 //
 // in this case.
 func (ms *Server) Unmount() (err error) {
+	ms.unmountMu.Lock()
+	defer ms.unmountMu.Unlock()
+	if ms.detachedFd >= 0 {
+		ms.notifyStopping()
+		// Closing the last reference to a detached mount
+		// unmounts it.
+		syscall.Close(ms.detachedFd)
+		ms.detachedFd = -1
+		ms.detachedClosed = true
+	}
+	if ms.detachedClosed {
+		// If the mount file descriptor was passed on, or files
+		// were opened through it, the mount stays until those
+		// are closed too.
+		if !ms.waitLoops(detachedUnmountTimeout) {
+			return fmt.Errorf("detached mount is still referenced: %w", syscall.EBUSY)
+		}
+		ms.detachedClosed = false
+		return nil
+	}
 	if ms.mountPoint == "" {
 		return nil
 	}
 	if parseFuseFd(ms.mountPoint) >= 0 {
 		return fmt.Errorf("Cannot unmount magic mountpoint %q. Please use `fusermount -u REALMOUNTPOINT` instead.", ms.mountPoint)
 	}
+	if ms.mountNs != "" {
+		// The mount point is only valid in the namespace the
+		// mount was attached in, and fusermount inherits the
+		// namespace of this thread.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if ns, err := threadMountNamespace(); err != nil {
+			return err
+		} else if ns != ms.mountNs {
+			return fmt.Errorf("mount %q was attached in mount namespace %s, not %s", ms.mountPoint, ms.mountNs, ns)
+		}
+	}
+	ms.notifyStopping()
 	delay := time.Duration(0)
 	for try := 0; try < 5; try++ {
 		err = unmount(ms.mountPoint, ms.opts)
@@ -144,9 +220,30 @@ func (ms *Server) Unmount() (err error) {
 	// Wait for event loops to exit.
 	ms.loops.Wait()
 	ms.mountPoint = ""
+	ms.mountNs = ""
 	return err
 }
 
+// detachedUnmountTimeout is how long Unmount waits for a detached
+// mount to go away after closing its file descriptor.
+const detachedUnmountTimeout = time.Second
+
+// waitLoops waits up to timeout for the read loops to exit. It
+// returns false if they are still running.
+func (ms *Server) waitLoops(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		ms.loops.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 // alignSlice ensures that the byte at alignedByte is aligned with the
 // given logical block size.  The input slice should be at least (size
 // + blockSize)
@@ -156,12 +253,8 @@ func alignSlice(buf []byte, alignedByte, blockSize, size uintptr) []byte {
 	return buf[:size]
 }
 
-// NewServer creates a FUSE server and attaches ("mounts") it to the
-// `mountPoint` directory.
-//
-// See the "Mount styles" section in the package documentation if you want to
-// know about the inner workings of the mount process. Usually you do not.
-func NewServer(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server, error) {
+// serverOptions returns a copy of opts with the defaults filled in.
+func serverOptions(fs RawFileSystem, opts *MountOptions) MountOptions {
 	if opts == nil {
 		opts = &MountOptions{
 			MaxBackground: _DEFAULT_BACKGROUND_TASKS,
@@ -205,7 +298,71 @@ func NewServer(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server
 			o.DisabledCapabilities |= s.mask
 		}
 	}
+	return o
+}
+
+// NewServer creates a FUSE server and attaches ("mounts") it to the
+// `mountPoint` directory.
+//
+// See the "Mount styles" section in the package documentation if you want to
+// know about the inner workings of the mount process. Usually you do not.
+func NewServer(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server, error) {
+	o := serverOptions(fs, opts)
+	ms := newServer(fs, &o)
+
+	var fd int
+	if o.DetachedMount {
+		var err error
+		fd, ms.detachedFd, err = mountDetached(&o, ms.ready)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		mountPoint = filepath.Clean(mountPoint)
+		if !filepath.IsAbs(mountPoint) {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			mountPoint = filepath.Clean(filepath.Join(cwd, mountPoint))
+		}
+		if o.CleanStaleMount {
+			if err := cleanStaleMount(mountPoint, &o); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		fd, err = mount(mountPoint, &o, ms.ready)
+		if err != nil {
+			return nil, err
+		}
+		ms.mountPoint = mountPoint
+	}
+
+	ms.mountFd = fd
+	ms.queues = []*readQueue{{fd: fd}}
+
+	if code := ms.handleInit(); !code.Ok() {
+		syscall.Close(fd)
+		if ms.detachedFd >= 0 {
+			// Dropping the last reference unmounts it.
+			syscall.Close(ms.detachedFd)
+			ms.detachedFd = -1
+		}
+		// TODO - unmount as well?
+		return nil, fmt.Errorf("init: %s", code)
+	}
+	ms.cloneQueues()
 
+	// This prepares for Serve being called somewhere, either
+	// synchronously or asynchronously.
+	ms.loops.Add(1)
+	return ms, nil
+}
+
+// newServer returns a Server for fs that is not connected to the
+// kernel yet. o should come from serverOptions.
+func newServer(fs RawFileSystem, o *MountOptions) *Server {
 	maxReaders := runtime.GOMAXPROCS(0)
 	if maxReaders < minMaxReaders {
 		maxReaders = minMaxReaders
@@ -215,17 +372,22 @@ func NewServer(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server
 
 	ms := &Server{
 		protocolServer: protocolServer{
-			fileSystem:  fs,
-			retrieveTab: make(map[uint64]*retrieveCacheRequest),
-			opts:        &o,
+			fileSystem:    fs,
+			fileSystemCtx: NewRawFileSystemCtx(fs),
+			retrieveTab:   make(map[uint64]*retrieveCacheRequest),
+			opts:          o,
 		},
-		opts:         &o,
+		opts:         o,
 		maxReaders:   maxReaders,
 		singleReader: useSingleReader,
 		ready:        make(chan error, 1),
+		detachedFd:   -1,
 	}
 
 	ms.protocolServer.writev = ms.writev
+	if o.RecordTraffic != nil {
+		ms.recorder = newRecorder(o.RecordTraffic)
+	}
 	ms.reqPool.New = func() interface{} {
 		return &requestAlloc{
 			request: request{
@@ -246,32 +408,30 @@ func NewServer(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server
 		buf = alignSlice(buf, unsafe.Sizeof(WriteIn{}), logicalBlockSize, uintptr(targetSize))
 		return buf
 	}
-	mountPoint = filepath.Clean(mountPoint)
-	if !filepath.IsAbs(mountPoint) {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return nil, err
-		}
-		mountPoint = filepath.Clean(filepath.Join(cwd, mountPoint))
-	}
-	fd, err := mount(mountPoint, &o, ms.ready)
-	if err != nil {
-		return nil, err
-	}
+	return ms
+}
 
-	ms.mountPoint = mountPoint
-	ms.mountFd = fd
+// readQueue is a FUSE device file descriptor, and the goroutines
+// reading from it.
+type readQueue struct {
+	fd int
 
-	if code := ms.handleInit(); !code.Ok() {
-		syscall.Close(fd)
-		// TODO - unmount as well?
-		return nil, fmt.Errorf("init: %s", code)
-	}
+	// readers is the number of goroutines blocked reading fd.
+	// Protected by Server.reqMu.
+	readers int
+}
 
-	// This prepares for Serve being called somewhere, either
-	// synchronously or asynchronously.
-	ms.loops.Add(1)
-	return ms, nil
+// cloneQueues sets up opts.NumQueues-1 clones of the device, so
+// requests can be read in parallel through multiple queues.
+func (ms *Server) cloneQueues() {
+	for len(ms.queues) < ms.opts.NumQueues {
+		fd, err := ms.cloneFd()
+		if err != nil {
+			ms.opts.Logger.Printf("cannot clone FUSE device, using %d queues: %v", len(ms.queues), err)
+			return
+		}
+		ms.queues = append(ms.queues, &readQueue{fd: fd})
+	}
 }
 
 func escape(optionValue string) string {
@@ -279,6 +439,19 @@ func escape(optionValue string) string {
 }
 
 func (o *MountOptions) optionsStrings() []string {
+	// Commas and backslashs in an option need to be escaped, because
+	// options are separated by a comma and backslashs are used to
+	// escape other characters.
+	var rEscaped []string
+	for _, s := range o.optionsList() {
+		rEscaped = append(rEscaped, escape(s))
+	}
+
+	return rEscaped
+}
+
+// optionsList returns the mount options for fusermount, unescaped.
+func (o *MountOptions) optionsList() []string {
 	var r []string
 	r = append(r, o.Options...)
 
@@ -302,16 +475,7 @@ func (o *MountOptions) optionsStrings() []string {
 	if o.IDMappedMount && !o.containsOption("default_permissions") {
 		r = append(r, "default_permissions")
 	}
-
-	// Commas and backslashs in an option need to be escaped, because
-	// options are separated by a comma and backslashs are used to
-	// escape other characters.
-	var rEscaped []string
-	for _, s := range r {
-		rEscaped = append(rEscaped, escape(s))
-	}
-
-	return rEscaped
+	return r
 }
 
 func (o *MountOptions) containsOption(opt string) bool {
@@ -328,10 +492,13 @@ func (o *MountOptions) containsOption(opt string) bool {
 func (ms *Server) DebugData() string {
 	var r int
 	ms.reqMu.Lock()
-	r = ms.reqReaders
+	for _, q := range ms.queues {
+		r += q.readers
+	}
+	n := len(ms.queues)
 	ms.reqMu.Unlock()
 
-	return fmt.Sprintf("readers: %d", r)
+	return fmt.Sprintf("readers: %d, queues: %d", r, n)
 }
 
 // handleEINTR retries the given function until it doesn't return syscall.EINTR.
@@ -353,15 +520,17 @@ func handleEINTR(fn func() error) (err error) {
 	return
 }
 
-// Returns a new request, or error. Returns
-// nil, OK if we have too many readers already.
-func (ms *Server) readRequest() (req *requestAlloc, code Status) {
+// Returns a new request read from the given queue, or error. Returns
+// nil, OK if we have too many readers already, or if the server is
+// being handed off.
+func (ms *Server) readRequest(q *readQueue) (req *requestAlloc, code Status) {
 	ms.reqMu.Lock()
-	if ms.reqReaders > ms.maxReaders {
+	// The maximum number of readers is shared among all queues.
+	if ms.handingOff || q.readers > ms.maxReaders/len(ms.queues) {
 		ms.reqMu.Unlock()
 		return nil, OK
 	}
-	ms.reqReaders++
+	q.readers++
 	ms.reqMu.Unlock()
 
 	reqIface := ms.reqPool.Get()
@@ -372,14 +541,14 @@ func (ms *Server) readRequest() (req *requestAlloc, code Status) {
 	var n int
 	err := handleEINTR(func() error {
 		var err error
-		n, err = syscall.Read(ms.mountFd, dest)
+		n, err = syscall.Read(q.fd, dest)
 		return err
 	})
 	if err != nil {
 		code = ToStatus(err)
 		ms.reqPool.Put(reqIface)
 		ms.reqMu.Lock()
-		ms.reqReaders--
+		q.readers--
 		ms.reqMu.Unlock()
 		return nil, code
 	}
@@ -387,6 +556,10 @@ func (ms *Server) readRequest() (req *requestAlloc, code Status) {
 	if ms.latencies != nil {
 		req.startTime = time.Now()
 	}
+	req.fd = q.fd
+	if ms.recorder != nil {
+		ms.recorder.record(RecordRequest, dest[:n])
+	}
 	ms.reqMu.Lock()
 	defer ms.reqMu.Unlock()
 	gobbled := req.setInput(dest[:n])
@@ -404,10 +577,10 @@ func (ms *Server) readRequest() (req *requestAlloc, code Status) {
 	if !gobbled {
 		ms.readPool.Put(destIface)
 	}
-	ms.reqReaders--
-	if !ms.singleReader && ms.reqReaders <= 0 && !needsBackPressure {
+	q.readers--
+	if !ms.singleReader && q.readers <= 0 && !needsBackPressure && !ms.handingOff {
 		ms.loops.Add(1)
-		go ms.loop()
+		go ms.loop(q)
 	}
 
 	return req, OK
@@ -448,19 +621,41 @@ func (ms *Server) recordStats(req *request) {
 //
 // Each filesystem operation executes in a separate goroutine.
 func (ms *Server) Serve() {
-	if ms.serving {
+	ms.reqMu.Lock()
+	serving := ms.serving
+	ms.serving = true
+	ms.reqMu.Unlock()
+	if serving {
 		// Calling Serve() multiple times leads to a panic on unmount and fun
 		// debugging sessions ( https://github.com/hanwen/go-fuse/issues/512 ).
 		// Catch it early.
 		log.Panic("Serve() must only be called once, you have called it a second time")
 	}
-	ms.serving = true
 
-	ms.loop()
+	ms.startIOUring()
+	stopWatchdog := ms.startWatchdog()
+	stopSystemdWatchdog := ms.startSystemdWatchdog()
+	for _, q := range ms.queues[1:] {
+		ms.loops.Add(1)
+		go ms.loop(q)
+	}
+	ms.loop(ms.queues[0])
 	ms.loops.Wait()
+	stopWatchdog()
+	stopSystemdWatchdog()
+	ms.reqMu.Lock()
+	handedOff := ms.handingOff
+	ms.reqMu.Unlock()
+	if !handedOff {
+		// Not handed off: tell systemd we are stopping. Otherwise,
+		// the successor serves the mount from now on.
+		ms.notifyStopping()
+	}
 
 	ms.writeMu.Lock()
-	syscall.Close(ms.mountFd)
+	for _, q := range ms.queues {
+		syscall.Close(q.fd)
+	}
 	ms.writeMu.Unlock()
 
 	// shutdown in-flight cache retrieves.
@@ -480,7 +675,9 @@ func (ms *Server) Serve() {
 		close(reading.ready)
 	}
 
-	ms.fileSystem.OnUnmount()
+	if !handedOff {
+		ms.fileSystem.OnUnmount()
+	}
 }
 
 // Wait waits for the serve loop to exit. This should only be called
@@ -494,7 +691,7 @@ func (ms *Server) handleInit() Status {
 	// and don't spawn new readers.
 	orig := ms.singleReader
 	ms.singleReader = true
-	req, errNo := ms.readRequest()
+	req, errNo := ms.readRequest(ms.queues[0])
 	ms.singleReader = orig
 
 	if errNo != OK || req == nil {
@@ -541,11 +738,19 @@ func (ms *Server) handleInit() Status {
 // BenchmarkGoFuseStat-2          	    9310	    121332 ns/op
 // BenchmarkGoFuseReaddir         	    4074	    361568 ns/op
 // BenchmarkGoFuseReaddir-2       	    3511	    319765 ns/op
-func (ms *Server) loop() {
+func (ms *Server) loop(q *readQueue) {
 	defer ms.loops.Done()
+	ms.reqMu.Lock()
+	ms.activeLoops++
+	ms.reqMu.Unlock()
+	defer func() {
+		ms.reqMu.Lock()
+		ms.activeLoops--
+		ms.reqMu.Unlock()
+	}()
 exit:
 	for {
-		req, errNo := ms.readRequest()
+		req, errNo := ms.readRequest(q)
 		switch errNo {
 		case OK:
 			if req == nil {
@@ -588,6 +793,12 @@ func (ms *Server) handleRequest(req *requestAlloc) Status {
 		return code
 	}
 
+	if ms.beginRequest(req.inHeader().Opcode) {
+		defer ms.endRequest()
+	} else {
+		req.refused = true
+	}
+
 	req.suppressReply = h.SuppressReply
 	req.inPayload = req.inputBuf[inSize:]
 	req.inputBuf = req.inputBuf[:inSize]
@@ -603,7 +814,10 @@ func (ms *Server) handleRequest(req *requestAlloc) Status {
 	if req.suppressReply {
 		return OK
 	}
-	errno := ms.write(&req.request)
+	if ms.recorder != nil {
+		ms.recordReply(&req.request)
+	}
+	errno := ms.write(req.fd, &req.request)
 	if errno != 0 {
 		// Ignore ENOENT for INTERRUPT responses which
 		// indicates that the referred request is no longer
@@ -675,12 +889,14 @@ func newNotifyRequest(opcode uint32) *request {
 		outHeaderBuf: make([]byte, sizeOfOutHeader),
 		outDataBuf:   make([]byte, getHandler(opcode).OutputSize),
 		status: map[uint32]Status{
+			_OP_NOTIFY_POLL:           NOTIFY_POLL,
 			_OP_NOTIFY_INVAL_INODE:    NOTIFY_INVAL_INODE,
 			_OP_NOTIFY_INVAL_ENTRY:    NOTIFY_INVAL_ENTRY,
 			_OP_NOTIFY_STORE_CACHE:    NOTIFY_STORE_CACHE,
 			_OP_NOTIFY_RETRIEVE_CACHE: NOTIFY_RETRIEVE_CACHE,
 			_OP_NOTIFY_DELETE:         NOTIFY_DELETE,
 			_OP_NOTIFY_PRUNE:          NOTIFY_PRUNE,
+			_OP_NOTIFY_RESEND:         NOTIFY_RESEND,
 		}[opcode],
 	}
 	r.inHeader().Opcode = opcode
@@ -718,6 +934,56 @@ func (ms *Server) PruneNotify(nodes []uint64) Status {
 	return ms.notifyWrite(req)
 }
 
+// PollNotify wakes up the poll(2) callers waiting on the kernel
+// handle kh, which was passed in PollIn.Kh with
+// FUSE_POLL_SCHEDULE_NOTIFY set. The kernel then issues a new POLL
+// request.
+func (ms *protocolServer) PollNotify(kh uint64) Status {
+	if !ms.kernelSettings.SupportsNotify(NOTIFY_POLL) {
+		return ENOSYS
+	}
+	req := newNotifyRequest(_OP_NOTIFY_POLL)
+
+	entry := (*NotifyPollWakeupOut)(req.outData())
+	entry.Kh = kh
+
+	return ms.notifyWrite(req)
+}
+
+// ResendPending asks the kernel to queue all requests that are
+// waiting for a reply again, so they are read anew. The handlers
+// that are still running for these requests are interrupted, and
+// their replies are dropped. Use this to recover from file system
+// calls that hang. Requests exchanged through io_uring are not
+// requeued. This needs CAP_HAS_RESEND (Linux 6.9); ENOSYS is
+// returned if the kernel does not support it.
+func (ms *Server) ResendPending() Status {
+	if ms.kernelSettings.Flags64()&CAP_HAS_RESEND == 0 {
+		return ENOSYS
+	}
+	req := newNotifyRequest(_OP_NOTIFY_RESEND)
+
+	// Holding interruptMu keeps requests from being added to
+	// the inflight list until the kernel has requeued, so the
+	// resent copies are not marked below.
+	ms.interruptMu.Lock()
+	defer ms.interruptMu.Unlock()
+	if st := ms.notifyWrite(req); st != OK {
+		return st
+	}
+	for _, inflight := range ms.reqInflight {
+		if inflight.uring {
+			continue
+		}
+		inflight.abandoned.Store(true)
+		if !inflight.interrupted {
+			close(inflight.cancel)
+			inflight.interrupted = true
+		}
+	}
+	return OK
+}
+
 // InodeNotifyStoreCache tells kernel to store data into inode's cache.
 //
 // This call is similar to InodeNotify, but instead of only invalidating a data
@@ -896,10 +1162,28 @@ func (ms *protocolServer) DeleteNotify(parent uint64, child uint64, name string)
 // within a directory changes. You should not hold any FUSE filesystem
 // locks, as that can lead to deadlock.
 func (ms *protocolServer) EntryNotify(parent uint64, name string) Status {
+	return ms.entryNotify(parent, name, 0)
+}
+
+// EntryNotifyExpire marks an entry within a directory as expired,
+// so the next access looks it up again. Unlike EntryNotify, the
+// kernel keeps the entry if it is still in use, eg. as a mount point
+// or working directory. This needs CAP_HAS_EXPIRE_ONLY (Linux 6.2);
+// ENOSYS is returned if the kernel does not support it. You should
+// not hold any FUSE filesystem locks, as that can lead to deadlock.
+func (ms *protocolServer) EntryNotifyExpire(parent uint64, name string) Status {
+	if ms.kernelSettings.Flags64()&CAP_HAS_EXPIRE_ONLY == 0 {
+		return ENOSYS
+	}
+	return ms.entryNotify(parent, name, FUSE_EXPIRE_ONLY)
+}
+
+func (ms *protocolServer) entryNotify(parent uint64, name string, flags uint32) Status {
 	req := newNotifyRequest(_OP_NOTIFY_INVAL_ENTRY)
 	entry := (*NotifyInvalEntryOut)(req.outData())
 	entry.Parent = parent
 	entry.NameLen = uint32(len(name))
+	entry.Flags = flags
 
 	// Many versions of FUSE generate stacktraces if the
 	// terminating null byte is missing.
@@ -921,6 +1205,8 @@ func (in *InitIn) SupportsVersion(maj, min uint32) bool {
 // supported. Pass any of the NOTIFY_* types as argument.
 func (in *InitIn) SupportsNotify(notifyType int) bool {
 	switch notifyType {
+	case NOTIFY_POLL:
+		return in.SupportsVersion(7, 11)
 	case NOTIFY_INVAL_ENTRY:
 		return in.SupportsVersion(7, 12)
 	case NOTIFY_INVAL_INODE:
@@ -944,28 +1230,52 @@ func (in *InitIn) supportsRenameSwap() bool {
 // WaitMount waits for the first request to be served. Use this to
 // avoid racing between accessing the (empty or not yet mounted)
 // mountpoint, and the OS trying to setup the user-space mount.
+//
+// Unless MountOptions.EnablePoll is set, this also switches off POLL
+// for the mount.
 func (ms *Server) WaitMount() error {
 	err := <-ms.ready
 	if err != nil {
 		return err
 	}
+	if ms.opts.EnablePoll {
+		return nil
+	}
 	if parseFuseFd(ms.mountPoint) >= 0 {
 		// Magic `/dev/fd/N` mountpoint. We don't know the real mountpoint, so
 		// we cannot run the poll hack.
 		return nil
 	}
+	if ms.detachedFd >= 0 {
+		return pollHack(fmt.Sprintf("/proc/self/fd/%d", ms.detachedFd))
+	}
 	return pollHack(ms.mountPoint)
 }
 
+// DetachedMountFd returns the file descriptor of a mount created with
+// MountOptions.DetachedMount, or -1 if there is none, or the mount
+// has been attached with AttachMount. Before attaching the mount, it
+// can be changed with mount_setattr(2), for example to make it an
+// ID-mapped mount (see MountOptions.IDMappedMount), or it can be
+// passed to another process to attach it with move_mount(2). The
+// descriptor is closed by AttachMount and Unmount.
+func (ms *Server) DetachedMountFd() int {
+	return ms.detachedFd
+}
+
 // parseFuseFd checks if `mountPoint` is the special form /dev/fd/N (with N >= 0),
-// and returns N in this case. Returns -1 otherwise.
+// and returns N in this case. N may also be the name of a file
+// descriptor passed by systemd. Returns -1 otherwise.
 func parseFuseFd(mountPoint string) (fd int) {
 	dir, file := path.Split(mountPoint)
 	if dir != "/dev/fd/" {
 		return -1
 	}
 	fd, err := strconv.Atoi(file)
-	if err != nil || fd <= 0 {
+	if err != nil {
+		return systemdListenFd(file)
+	}
+	if fd <= 0 {
 		return -1
 	}
 	return fd
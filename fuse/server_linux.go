@@ -4,12 +4,37 @@
 
 package fuse
 
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
 const useSingleReader = false
 
-func (ms *Server) write(req *request) Status {
+const _DEV_IOC_CLONE = 0x8004e500
+
+// cloneFd opens a new FUSE device file descriptor that is attached to
+// the same connection as the mount, but has its own queue of
+// requests being processed.
+func (ms *Server) cloneFd() (int, error) {
+	fd, err := syscall.Open("/dev/fuse", os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	src := uint32(ms.mountFd)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(_DEV_IOC_CLONE), uintptr(unsafe.Pointer(&src)))
+	if errno != 0 {
+		syscall.Close(fd)
+		return -1, errno
+	}
+	return fd, nil
+}
+
+func (ms *Server) write(fd int, req *request) Status {
 	if req.outPayloadSize() == 0 {
 		err := handleEINTR(func() error {
-			_, err := writev(ms.mountFd, [][]byte{req.outHeaderBuf, req.outDataBuf})
+			_, err := writev(fd, [][]byte{req.outHeaderBuf, req.outDataBuf})
 			return err
 		})
 		return ToStatus(err)
@@ -17,7 +42,7 @@ func (ms *Server) write(req *request) Status {
 	if req.readResult != nil {
 		defer req.readResult.Done()
 		if ms.canSplice {
-			err := ms.trySplice(req, req.readResult)
+			err := ms.trySplice(fd, req, req.readResult)
 			if err == nil {
 				return OK
 			}
@@ -30,6 +55,6 @@ func (ms *Server) write(req *request) Status {
 		req.serializeHeader(len(req.outPayload))
 	}
 
-	_, err := writev(ms.mountFd, [][]byte{req.outHeaderBuf, req.outDataBuf, req.outPayload})
+	_, err := writev(fd, [][]byte{req.outHeaderBuf, req.outDataBuf, req.outPayload})
 	return ToStatus(err)
 }
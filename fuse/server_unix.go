@@ -2,15 +2,17 @@
 
 package fuse
 
+import "syscall"
+
 // OSX and FreeBSD has races when multiple routines read
 // from the FUSE device: on unmount, sometime some reads
 // do not error-out, meaning that unmount will hang.
 const useSingleReader = true
 
-func (ms *Server) write(req *request) Status {
+func (ms *Server) write(fd int, req *request) Status {
 	if req.outPayloadSize() == 0 {
 		err := handleEINTR(func() error {
-			_, err := writev(int(ms.mountFd), [][]byte{req.outHeaderBuf, req.outDataBuf})
+			_, err := writev(fd, [][]byte{req.outHeaderBuf, req.outDataBuf})
 			return err
 		})
 		return ToStatus(err)
@@ -23,9 +25,20 @@ func (ms *Server) write(req *request) Status {
 		req.readResult = nil
 	}
 
-	_, err := writev(int(ms.mountFd), [][]byte{req.outHeaderBuf, req.outDataBuf, req.outPayload})
+	_, err := writev(fd, [][]byte{req.outHeaderBuf, req.outDataBuf, req.outPayload})
 	if req.readResult != nil {
 		req.readResult.Done()
 	}
 	return ToStatus(err)
 }
+
+// cloneFd is only supported on Linux.
+func (ms *Server) cloneFd() (int, error) {
+	return -1, syscall.ENOSYS
+}
+
+// startIOUring is a no-op: io_uring is only available on Linux.
+func (ms *Server) startIOUring() {}
+
+// ioUringNegotiated returns false: io_uring is only available on Linux.
+func (ms *Server) ioUringNegotiated() bool { return false }
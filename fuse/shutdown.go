@@ -0,0 +1,146 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// drainState tracks the requests that are being handled, so Shutdown
+// can wait for them.
+type drainState struct {
+	// draining is set once Shutdown has started.
+	draining bool
+
+	// active counts the requests being handled.
+	active int
+
+	// idle is made when draining starts, and closed when active
+	// drops to zero.
+	idle chan struct{}
+}
+
+// signalIdle closes idle, unless it is closed already. Requests that
+// finish work are still served while draining, so active can drop to
+// zero more than once.
+func (d *drainState) signalIdle() {
+	select {
+	case <-d.idle:
+	default:
+		close(d.idle)
+	}
+}
+
+// finishesWork returns true for operations that complete work on
+// files that are already open, or that only release kernel
+// references. These are still served while draining, so that data
+// written before the shutdown reaches the file system.
+func finishesWork(op uint32) bool {
+	switch op {
+	case _OP_WRITE, _OP_FLUSH, _OP_FSYNC, _OP_FSYNCDIR, _OP_RELEASE, _OP_RELEASEDIR,
+		_OP_FORGET, _OP_BATCH_FORGET, _OP_INTERRUPT, _OP_NOTIFY_REPLY, _OP_DESTROY:
+		return true
+	}
+	return false
+}
+
+// beginRequest registers a request with opcode op. It returns false
+// if the request should be refused because the server is shutting
+// down.
+func (ms *Server) beginRequest(op uint32) bool {
+	ms.drainMu.Lock()
+	defer ms.drainMu.Unlock()
+	if ms.drain.draining && !finishesWork(op) {
+		return false
+	}
+	ms.drain.active++
+	return true
+}
+
+// endRequest unregisters a request registered with beginRequest.
+func (ms *Server) endRequest() {
+	ms.drainMu.Lock()
+	defer ms.drainMu.Unlock()
+	ms.drain.active--
+	if ms.drain.active == 0 && ms.drain.draining {
+		ms.drain.signalIdle()
+	}
+}
+
+// Shutdown gracefully shuts down the server. It
+//
+//  1. stops accepting new requests: from then on, requests fail
+//     with ENOTCONN, except for those that finish work on files that
+//     are already open (WRITE, FLUSH, FSYNC, RELEASE and friends),
+//  2. waits for the requests in flight to complete,
+//  3. waits for pending notifications to be written, and for
+//     outstanding InodeRetrieveCache calls to be answered, and
+//  4. unmounts the file system, and waits for Serve to return.
+//
+// If ctx expires while waiting for requests, the requests still in
+// flight are interrupted. The unmount is not bounded by ctx, but
+// gives up after a few retries, like Unmount. All phases run
+// regardless of earlier failures; the returned error joins the errors
+// from each of them.
+//
+// Shutdown may be called concurrently; later calls wait for the same
+// requests as the first one.
+func (ms *Server) Shutdown(ctx context.Context) error {
+	ms.drainMu.Lock()
+	if !ms.drain.draining {
+		ms.drain.draining = true
+		ms.drain.idle = make(chan struct{})
+		if ms.drain.active == 0 {
+			ms.drain.signalIdle()
+		}
+	}
+	idle := ms.drain.idle
+	ms.drainMu.Unlock()
+
+	var errs []error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		ms.interruptAll()
+		errs = append(errs, fmt.Errorf("draining requests: %w", ctx.Err()))
+	}
+
+	if err := ms.flushNotifications(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flushing notifications: %w", err))
+	}
+
+	if err := ms.Unmount(); err != nil {
+		errs = append(errs, fmt.Errorf("unmount: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// flushNotifications waits for notifications that are being written,
+// and for the kernel to answer outstanding retrieve requests.
+func (ms *Server) flushNotifications(ctx context.Context) error {
+	// Notifications are written under writeMu, so taking it waits
+	// for the writes in progress.
+	ms.writeMu.Lock()
+	ms.writeMu.Unlock()
+
+	delay := time.Millisecond
+	for {
+		ms.retrieveMu.Lock()
+		n := len(ms.retrieveTab)
+		ms.retrieveMu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("%d retrieve requests pending: %w", n, ctx.Err())
+		}
+		delay = min(2*delay, 50*time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogOptions configures structured logging of requests through
+// log/slog. Each request yields an "rx" record when it is received,
+// and a "tx" record when it is answered, with the opcode, unique ID,
+// node ID and caller, the decoded input and output, the status, the
+// sizes and the duration as attributes.
+type SlogOptions struct {
+	// Handler receives the records.
+	Handler slog.Handler
+
+	// Level is the level of the records, and ErrorLevel that of
+	// replies for failed requests. The zero value of both is
+	// slog.LevelInfo.
+	Level      slog.Level
+	ErrorLevel slog.Level
+
+	// SampleRate, if larger than 1, logs only one in SampleRate
+	// requests. Replies for failed requests are always logged.
+	SampleRate int
+}
+
+// slogRequest logs the "rx" record for req. It returns whether the
+// request was sampled.
+func (ms *protocolServer) slogRequest(h *operationHandler, req *request) bool {
+	o := ms.opts.Slog
+	if o.SampleRate > 1 && ms.slogCount.Add(1)%uint64(o.SampleRate) != 0 {
+		return false
+	}
+	ctx := context.Background()
+	if !o.Handler.Enabled(ctx, o.Level) {
+		return true
+	}
+
+	hdr := req.inHeader()
+	r := slog.NewRecord(time.Now(), o.Level, "rx", 0)
+	r.AddAttrs(slogHeader(h, hdr)...)
+	r.AddAttrs(
+		slog.Uint64("pid", uint64(hdr.Pid)),
+		slog.Uint64("uid", uint64(hdr.Uid)),
+		slog.Uint64("gid", uint64(hdr.Gid)),
+	)
+	if h.InType != nil {
+		r.AddAttrs(slog.String("input", Print(asType(req.inData(), h.InType))))
+	}
+	switch h.FileNames {
+	case 1:
+		r.AddAttrs(slog.String("name", req.filename()))
+	case 2:
+		n1, n2 := req.filenames()
+		r.AddAttrs(slog.String("name", n1), slog.String("name2", n2))
+	}
+	r.AddAttrs(slog.Int("in_size", len(req.inPayload)))
+	o.Handler.Handle(ctx, r)
+	return true
+}
+
+// slogReply logs the "tx" record for req, which was received at
+// start.
+func (ms *protocolServer) slogReply(h *operationHandler, req *request, sampled bool, start time.Time) {
+	if req.suppressReply {
+		return
+	}
+	o := ms.opts.Slog
+	level := o.Level
+	if req.status > OK {
+		level = o.ErrorLevel
+	} else if !sampled {
+		return
+	}
+	ctx := context.Background()
+	if !o.Handler.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), level, "tx", 0)
+	r.AddAttrs(slogHeader(h, req.inHeader())...)
+	r.AddAttrs(slog.String("status", req.status.String()))
+	if req.status > OK {
+		r.AddAttrs(slog.Int("errno", int(req.status)))
+	} else if h.OutType != nil && len(req.outDataBuf) > 0 {
+		r.AddAttrs(slog.String("output", Print(asType(req.outData(), h.OutType))))
+	}
+	r.AddAttrs(
+		slog.Int("out_size", len(req.outDataBuf)+req.outPayloadSize()),
+		slog.Duration("duration", time.Since(start)),
+	)
+	o.Handler.Handle(ctx, r)
+}
+
+func slogHeader(h *operationHandler, hdr *InHeader) []slog.Attr {
+	return []slog.Attr{
+		slog.String("op", h.Name),
+		slog.Uint64("unique", hdr.Unique),
+		slog.Uint64("nodeid", hdr.NodeId),
+	}
+}
@@ -30,7 +30,7 @@ func (s *Server) setSplice() {
 // If a short read occurs (payloadLen < fdData.Sz), the header in the pipe
 // would carry the wrong total length, so we return an error and let the
 // caller fall back to a Pread-based path.
-func (ms *Server) trySplice(req *request, readResult ReadResult) error {
+func (ms *Server) trySplice(devFd int, req *request, readResult ReadResult) error {
 	// The caller (handleRequest) already called req.serializeHeader with
 	// readResult.Size(), so req.outHeaderBuf is correct for the optimistic case.
 	total := len(req.outHeaderBuf) + len(req.outDataBuf) + readResult.Size()
@@ -90,11 +90,11 @@ func (ms *Server) trySplice(req *request, readResult ReadResult) error {
 		// New length.
 		req.serializeHeader(payloadLen)
 
-		return ms.trySplice(req, ReadResultPipe(pair, payloadLen))
+		return ms.trySplice(devFd, req, ReadResultPipe(pair, payloadLen))
 	}
 
 	// Write header + payload to /dev/fuse.
-	_, err = pair.WriteTo(uintptr(ms.mountFd), total)
+	_, err = pair.WriteTo(uintptr(devFd), total)
 	return err
 }
 
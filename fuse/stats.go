@@ -0,0 +1,178 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the latency histogram
+// buckets.
+var latencyBounds = [...]time.Duration{
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type opStats struct {
+	inflight atomic.Int64
+	count    atomic.Uint64
+	sum      atomic.Int64
+
+	// buckets counts the requests per latency bucket. The last
+	// bucket holds the requests slower than all bounds.
+	buckets [len(latencyBounds) + 1]atomic.Uint64
+}
+
+// Stats collects statistics of the requests served by a Server:
+// latency histograms and requests in flight per opcode, errors
+// returned per errno, and the number of bytes read and written.
+// Install it through MountOptions.Stats. The fuse/metrics package
+// exports Stats over HTTP.
+type Stats struct {
+	ops [_OPCODE_COUNT]opStats
+
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+
+	errorsMu sync.Mutex
+	errors   map[Status]uint64
+}
+
+// NewStats returns an empty Stats.
+func NewStats() *Stats {
+	return &Stats{
+		errors: make(map[Status]uint64),
+	}
+}
+
+// OpStats holds the statistics of one opcode.
+type OpStats struct {
+	// Count is the number of requests served, and Sum their
+	// total latency.
+	Count uint64
+	Sum   time.Duration
+
+	// Buckets holds the number of requests per latency bucket.
+	// Buckets[i] counts the requests that took at most
+	// StatsSnapshot.Bounds[i], and longer than the previous
+	// bound. The last element counts the requests slower than
+	// all bounds.
+	Buckets []uint64
+
+	// InFlight is the number of requests being served.
+	InFlight int64
+}
+
+// StatsSnapshot is a copy of the values in Stats.
+type StatsSnapshot struct {
+	// Bounds are the upper bounds of the latency buckets.
+	Bounds []time.Duration
+
+	// Ops holds the statistics per opcode name, for opcodes that
+	// were seen.
+	Ops map[string]OpStats
+
+	// Errors holds the number of requests that failed, per
+	// error code.
+	Errors map[Status]uint64
+
+	// BytesRead and BytesWritten count the data passed in READ
+	// and WRITE requests.
+	BytesRead    uint64
+	BytesWritten uint64
+}
+
+// Snapshot returns the current values. The values are read one at a
+// time, so they may not be consistent with each other if requests
+// are served concurrently.
+func (s *Stats) Snapshot() StatsSnapshot {
+	r := StatsSnapshot{
+		Bounds:       latencyBounds[:],
+		Ops:          make(map[string]OpStats),
+		Errors:       make(map[Status]uint64),
+		BytesRead:    s.bytesRead.Load(),
+		BytesWritten: s.bytesWritten.Load(),
+	}
+	for op := range s.ops {
+		st := &s.ops[op]
+		o := OpStats{
+			Count:    st.count.Load(),
+			Sum:      time.Duration(st.sum.Load()),
+			InFlight: st.inflight.Load(),
+			Buckets:  make([]uint64, len(st.buckets)),
+		}
+		if o.Count == 0 && o.InFlight == 0 {
+			continue
+		}
+		for i := range st.buckets {
+			o.Buckets[i] = st.buckets[i].Load()
+		}
+		r.Ops[operationName(uint32(op))] = o
+	}
+
+	s.errorsMu.Lock()
+	defer s.errorsMu.Unlock()
+	for code, n := range s.errors {
+		r.Errors[code] = n
+	}
+	return r
+}
+
+// start records the start of a request.
+func (s *Stats) start(op uint32) {
+	if op < _OPCODE_COUNT {
+		s.ops[op].inflight.Add(1)
+	}
+}
+
+// done records the result of a request, once it is ready to be
+// sent.
+func (s *Stats) done(op uint32, req *request, start time.Time) {
+	if op >= _OPCODE_COUNT {
+		return
+	}
+	dt := time.Since(start)
+	st := &s.ops[op]
+	st.inflight.Add(-1)
+	st.count.Add(1)
+	st.sum.Add(int64(dt))
+	i := 0
+	for i < len(latencyBounds) && dt > latencyBounds[i] {
+		i++
+	}
+	st.buckets[i].Add(1)
+
+	if req.status > OK {
+		s.errorsMu.Lock()
+		s.errors[req.status]++
+		s.errorsMu.Unlock()
+		return
+	}
+	switch op {
+	case _OP_READ:
+		s.bytesRead.Add(uint64(req.outPayloadSize()))
+	case _OP_WRITE:
+		s.bytesWritten.Add(uint64((*WriteOut)(req.outData()).Size))
+	}
+}
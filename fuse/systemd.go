@@ -0,0 +1,174 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sdListenFdsStart is the first file descriptor passed by systemd,
+// see sd_listen_fds(3).
+const sdListenFdsStart = 3
+
+// systemdListenFd returns the file descriptor that systemd passed with
+// the given name (FileDescriptorName= in the unit file), or -1.
+func systemdListenFd(name string) int {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return -1
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return -1
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n && i < len(names); i++ {
+		if names[i] == name {
+			return sdListenFdsStart + i
+		}
+	}
+	return -1
+}
+
+// systemdNotifier sends notifications to systemd, see sd_notify(3).
+type systemdNotifier struct {
+	conn *net.UnixConn
+
+	// watchdog is the interval at which systemd expects pings, or
+	// zero.
+	watchdog time.Duration
+
+	stopping sync.Once
+}
+
+// newSystemdNotifier returns a notifier for the socket in
+// NOTIFY_SOCKET, or nil if it is not set.
+func newSystemdNotifier() (*systemdNotifier, error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil, nil
+	}
+	// Go translates a leading '@' to an abstract socket address.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	n := &systemdNotifier{conn: conn}
+	if pid, err := strconv.Atoi(os.Getenv("WATCHDOG_PID")); err == nil && pid != os.Getpid() {
+		return n, nil
+	}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	return n, nil
+}
+
+func (n *systemdNotifier) send(state string) error {
+	_, err := n.conn.Write([]byte(state))
+	return err
+}
+
+// startSystemdWatchdog pings the systemd watchdog at half the interval
+// that systemd asked for, as long as the server makes progress: a
+// ping is skipped if requests were in flight at the previous ping,
+// and none has finished since. The returned function stops it.
+func (ms *Server) startSystemdWatchdog() func() {
+	n := ms.systemd
+	if n == nil || n.watchdog <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(n.watchdog / 2)
+		defer ticker.Stop()
+		var last uint64
+		var wasBusy bool
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				completed, busy := ms.progress()
+				stalled := wasBusy && busy && completed == last
+				last, wasBusy = completed, busy
+				if stalled {
+					ms.opts.Logger.Printf("systemd watchdog: no request finished in %v, not pinging", n.watchdog/2)
+					continue
+				}
+				if err := n.send("WATCHDOG=1"); err != nil {
+					ms.opts.Logger.Printf("systemd watchdog: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// notifyStopping tells systemd that the file system is being
+// unmounted.
+func (ms *Server) notifyStopping() {
+	n := ms.systemd
+	if n == nil {
+		return
+	}
+	n.stopping.Do(func() {
+		if err := n.send("STOPPING=1"); err != nil {
+			ms.opts.Logger.Printf("systemd notify: %v", err)
+		}
+	})
+}
+
+// ServeSystemd mounts fs like NewServer, and serves it in the
+// background. It integrates with systemd (see sd_notify(3)):
+//
+//   - mountPoint can name a /dev/fuse file descriptor that systemd
+//     passed to the process, with the magic /dev/fd/NAME syntax,
+//     where NAME is the FileDescriptorName= of the descriptor. See
+//     the "Mount styles" section in the package documentation.
+//   - Once the mount is ready to serve (see Server.WaitMount),
+//     READY=1 is sent to NOTIFY_SOCKET, so units ordered after the
+//     service find the file system mounted. Use Type=notify in the
+//     unit file.
+//   - STOPPING=1 is sent when the file system is unmounted, but not
+//     when it is passed on with Server.Handoff.
+//   - If the unit sets WatchdogSec=, the watchdog is pinged while
+//     the server is serving and requests keep finishing, so systemd
+//     restarts a server whose requests are all stuck.
+//
+// Without NOTIFY_SOCKET, ServeSystemd only mounts and serves. Call
+// Wait on the returned server to wait for the file system to be
+// unmounted.
+func ServeSystemd(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server, error) {
+	n, err := newSystemdNotifier()
+	if err != nil {
+		return nil, err
+	}
+	ms, err := NewServer(fs, mountPoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	ms.systemd = n
+	go ms.Serve()
+	if err := ms.WaitMount(); err != nil {
+		ms.Unmount()
+		return nil, err
+	}
+	if n != nil {
+		if err := n.send("READY=1"); err != nil {
+			ms.Unmount()
+			return nil, err
+		}
+	}
+	return ms, nil
+}
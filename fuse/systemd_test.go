@@ -0,0 +1,173 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/internal/testutil"
+)
+
+func TestParseFuseFdSystemd(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "sock:fuse")
+
+	for in, want := range map[string]int{
+		"/dev/fd/7":     7,
+		"/dev/fd/fuse":  4,
+		"/dev/fd/sock":  3,
+		"/dev/fd/other": -1,
+		"/dev/fd/0":     -1,
+		"/mnt/fuse":     -1,
+	} {
+		if got := parseFuseFd(in); got != want {
+			t.Errorf("parseFuseFd(%q): got %d, want %d", in, got, want)
+		}
+	}
+
+	t.Setenv("LISTEN_PID", "1")
+	if got := parseFuseFd("/dev/fd/fuse"); got != -1 {
+		t.Errorf("parseFuseFd for other process: got %d, want -1", got)
+	}
+}
+
+// notifyListener listens on a NOTIFY_SOCKET for the test, and
+// returns the channel of received notifications.
+func notifyListener(t *testing.T) <-chan string {
+	sock := filepath.Join(t.TempDir(), "notify")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	t.Setenv("NOTIFY_SOCKET", sock)
+	t.Setenv("WATCHDOG_USEC", "20000")
+
+	received := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := l.Read(buf)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- string(buf[:n])
+		}
+	}()
+	return received
+}
+
+// expectNotification waits for the notification want, skipping
+// watchdog pings.
+func expectNotification(t *testing.T, received <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-received:
+			if got == want {
+				return
+			}
+			if got != "WATCHDOG=1" {
+				t.Fatalf("got notification %q, want %q", got, want)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+}
+
+func TestServeSystemd(t *testing.T) {
+	received := notifyListener(t)
+	srv, err := ServeSystemd(NewDefaultRawFileSystem(), t.TempDir(), &MountOptions{
+		Debug: testutil.VerboseTest(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, received, "READY=1")
+	expectNotification(t, received, "WATCHDOG=1")
+	if err := srv.Unmount(); err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, received, "STOPPING=1")
+	srv.Wait()
+}
+
+// stallingFS hangs in GetAttr until stall is closed.
+type stallingFS struct {
+	RawFileSystem
+	stall chan struct{}
+}
+
+func (fs *stallingFS) GetAttr(cancel <-chan struct{}, in *GetAttrIn, out *AttrOut) Status {
+	select {
+	case <-fs.stall:
+	case <-cancel:
+		return EINTR
+	}
+	out.Mode = S_IFDIR | 0755
+	return OK
+}
+
+func TestSystemdWatchdogStall(t *testing.T) {
+	received := notifyListener(t)
+	fs := &stallingFS{RawFileSystem: NewDefaultRawFileSystem(), stall: make(chan struct{})}
+	mnt := t.TempDir()
+	srv, err := ServeSystemd(fs, mnt, &MountOptions{
+		Debug: testutil.VerboseTest(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, received, "READY=1")
+	expectNotification(t, received, "WATCHDOG=1")
+
+	done := make(chan struct{})
+	go func() {
+		var st syscall.Stat_t
+		syscall.Stat(mnt, &st)
+		close(done)
+	}()
+	// With the GETATTR stuck, the pings must stop. The watchdog
+	// interval is 20ms; allow for pings that were sent before the
+	// request arrived.
+	quiet := time.NewTimer(100 * time.Millisecond)
+	deadline := time.After(5 * time.Second)
+wait:
+	for {
+		select {
+		case got := <-received:
+			if got != "WATCHDOG=1" {
+				t.Errorf("got notification %q", got)
+			}
+			quiet.Reset(100 * time.Millisecond)
+		case <-quiet.C:
+			break wait
+		case <-deadline:
+			// Release the request before failing, or the
+			// stat keeps the test from exiting.
+			t.Error("watchdog kept pinging while the server was stuck")
+			break wait
+		}
+	}
+
+	close(fs.stall)
+	<-done
+	expectNotification(t, received, "WATCHDOG=1")
+	if err := srv.Unmount(); err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, received, "STOPPING=1")
+	srv.Wait()
+}
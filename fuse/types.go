@@ -70,6 +70,9 @@ const (
 
 	// EROFS Read-only file system
 	EROFS = Status(syscall.EROFS)
+
+	// ENOTCONN Transport endpoint is not connected
+	ENOTCONN = Status(syscall.ENOTCONN)
 )
 
 type ForgetIn struct {
@@ -423,20 +426,23 @@ type IoctlOut struct {
 	OutIovs uint32
 }
 
-type _PollIn struct {
+type PollIn struct {
 	InHeader
-	Fh      uint64
-	Kh      uint64
-	Flags   uint32
-	Padding uint32
+	Fh uint64
+
+	// Kh is the kernel handle for the poll. Pass it to
+	// Server.PollNotify to wake up the poller.
+	Kh     uint64
+	Flags  uint32
+	Events uint32
 }
 
-type _PollOut struct {
+type PollOut struct {
 	Revents uint32
 	Padding uint32
 }
 
-type _NotifyPollWakeupOut struct {
+type NotifyPollWakeupOut struct {
 	Kh uint64
 }
 
@@ -508,7 +514,7 @@ type NotifyInvalInodeOut struct {
 type NotifyInvalEntryOut struct {
 	Parent  uint64
 	NameLen uint32
-	Padding uint32
+	Flags   uint32
 }
 
 type NotifyInvalDeleteOut struct {
@@ -550,7 +556,7 @@ type NotifyPruneOut struct {
 }
 
 const (
-	//	NOTIFY_POLL         = -1 // notify kernel that a poll waiting for IO on a file handle should wake up
+	NOTIFY_POLL           = -1 // notify kernel that a poll waiting for IO on a file handle should wake up
 	NOTIFY_INVAL_INODE    = -2 // notify kernel that an inode should be invalidated
 	NOTIFY_INVAL_ENTRY    = -3 // notify kernel that a directory entry should be invalidated
 	NOTIFY_STORE_CACHE    = -4 // store data into kernel cache of an inode
@@ -568,6 +574,11 @@ type FlushIn struct {
 	LockOwner uint64
 }
 
+type SyncFsIn struct {
+	InHeader
+	Padding uint64
+}
+
 type LseekIn struct {
 	InHeader
 	Fh      uint64
@@ -656,7 +667,12 @@ type CreateOut struct {
 // Caller has data on the process making the FS call.
 //
 // The UID and GID are effective UID/GID, except for the ACCESS
-// opcode, where UID and GID are the real UIDs
+// opcode, where UID and GID are the real UIDs.
+//
+// Caller is part of the request header, so it cannot carry more data.
+// Use GroupsFromContext for the supplementary groups of the caller,
+// and SecurityContextFromContext for the security context of files
+// it creates.
 type Caller struct {
 	Owner
 	Pid uint32
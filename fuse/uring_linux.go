@@ -0,0 +1,592 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file implements the FUSE-over-io_uring transport (Linux
+// 6.14 and later). Rather than reading requests from /dev/fuse
+// and writing replies back, the server hands buffers to the
+// kernel using IORING_OP_URING_CMD. The kernel fills a buffer
+// when a request arrives, and the reply is committed together with
+// fetching the next request in a single SQE.
+//
+// The kernel expects one queue per possible CPU, and routes a
+// request to the queue of the CPU the calling process runs on.
+// FORGET, INTERRUPT and notify replies still go through /dev/fuse,
+// so the regular read loop keeps running alongside the rings.
+
+const (
+	_IORING_SETUP_SQE128     = 1 << 10
+	_IORING_FEAT_SINGLE_MMAP = 1 << 0
+	_IORING_ENTER_GETEVENTS  = 1 << 0
+	_IORING_OFF_SQ_RING      = 0
+	_IORING_OFF_CQ_RING      = 0x8000000
+	_IORING_OFF_SQES         = 0x10000000
+	_IORING_OP_NOP           = 0
+	_IORING_OP_URING_CMD     = 46
+
+	_FUSE_IO_URING_CMD_REGISTER         = 1
+	_FUSE_IO_URING_CMD_COMMIT_AND_FETCH = 2
+
+	// The number of iovecs passed with FUSE_IO_URING_CMD_REGISTER:
+	// the header and the payload.
+	_FUSE_URING_IOV_SEGS = 2
+
+	defaultIOUringQueueDepth = 2
+)
+
+type ioSqringOffsets struct {
+	Head        uint32
+	Tail        uint32
+	RingMask    uint32
+	RingEntries uint32
+	Flags       uint32
+	Dropped     uint32
+	Array       uint32
+	Resv1       uint32
+	UserAddr    uint64
+}
+
+type ioCqringOffsets struct {
+	Head        uint32
+	Tail        uint32
+	RingMask    uint32
+	RingEntries uint32
+	Overflow    uint32
+	Cqes        uint32
+	Flags       uint32
+	Resv1       uint32
+	UserAddr    uint64
+}
+
+type ioUringParams struct {
+	SqEntries    uint32
+	CqEntries    uint32
+	Flags        uint32
+	SqThreadCpu  uint32
+	SqThreadIdle uint32
+	Features     uint32
+	WqFd         uint32
+	Resv         [3]uint32
+	SqOff        ioSqringOffsets
+	CqOff        ioCqringOffsets
+}
+
+// ioUringSqe is a 128-byte submission queue entry, as used with
+// IORING_SETUP_SQE128. The fields from Opcode to FileIndex match
+// struct io_uring_sqe; Cmd is the command area of IORING_OP_URING_CMD.
+type ioUringSqe struct {
+	Opcode      uint8
+	Flags       uint8
+	Ioprio      uint16
+	Fd          int32
+	CmdOp       uint32
+	Pad1        uint32
+	Addr        uint64
+	Len         uint32
+	OpFlags     uint32
+	UserData    uint64
+	BufIndex    uint16
+	Personality uint16
+	FileIndex   int32
+	Cmd         [80]byte
+}
+
+type ioUringCqe struct {
+	UserData uint64
+	Res      int32
+	Flags    uint32
+}
+
+// ioUring is a minimal io_uring instance. Submissions may come from
+// any goroutine; completions must be reaped from a single goroutine.
+type ioUring struct {
+	fd int
+
+	sqRing  []byte
+	cqRing  []byte
+	sqesMem []byte
+
+	sqHead  *uint32
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []ioUringSqe
+
+	cqHead *uint32
+	cqTail *uint32
+	cqMask uint32
+	cqes   []ioUringCqe
+
+	// serializes submissions.
+	mu sync.Mutex
+}
+
+func newIOUring(entries uint32) (*ioUring, error) {
+	p := ioUringParams{
+		Flags: _IORING_SETUP_SQE128,
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	r := &ioUring{fd: int(fd)}
+
+	sqSize := int(p.SqOff.Array) + int(p.SqEntries)*4
+	cqSize := int(p.CqOff.Cqes) + int(p.CqEntries)*int(unsafe.Sizeof(ioUringCqe{}))
+	singleMmap := p.Features&_IORING_FEAT_SINGLE_MMAP != 0
+	if singleMmap {
+		sqSize = max(sqSize, cqSize)
+	}
+
+	var err error
+	r.sqRing, err = unix.Mmap(r.fd, _IORING_OFF_SQ_RING, sqSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	if singleMmap {
+		r.cqRing = r.sqRing
+	} else {
+		r.cqRing, err = unix.Mmap(r.fd, _IORING_OFF_CQ_RING, cqSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+	}
+	r.sqesMem, err = unix.Mmap(r.fd, _IORING_OFF_SQES, int(p.SqEntries)*int(unsafe.Sizeof(ioUringSqe{})), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.Head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.Tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.RingMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.Array])), p.SqEntries)
+	r.sqes = unsafe.Slice((*ioUringSqe)(unsafe.Pointer(&r.sqesMem[0])), p.SqEntries)
+
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.CqOff.Head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.CqOff.Tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.CqOff.RingMask]))
+	r.cqes = unsafe.Slice((*ioUringCqe)(unsafe.Pointer(&r.cqRing[p.CqOff.Cqes])), p.CqEntries)
+	return r, nil
+}
+
+func (r *ioUring) close() {
+	if r.sqesMem != nil {
+		unix.Munmap(r.sqesMem)
+	}
+	if r.cqRing != nil && &r.cqRing[0] != &r.sqRing[0] {
+		unix.Munmap(r.cqRing)
+	}
+	if r.sqRing != nil {
+		unix.Munmap(r.sqRing)
+	}
+	syscall.Close(r.fd)
+}
+
+func (r *ioUring) enter(toSubmit, minComplete, flags uint32) (int, error) {
+	var n uintptr
+	err := handleEINTR(func() error {
+		var errno syscall.Errno
+		n, _, errno = unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+	return int(n), err
+}
+
+// submit fills in a single SQE and passes it to the kernel.
+func (r *ioUring) submit(fill func(sqe *ioUringSqe)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tail := *r.sqTail
+	if tail-atomic.LoadUint32(r.sqHead) >= uint32(len(r.sqes)) {
+		return syscall.EBUSY
+	}
+	idx := tail & r.sqMask
+	sqe := &r.sqes[idx]
+	*sqe = ioUringSqe{}
+	fill(sqe)
+	r.sqArray[idx] = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+
+	_, err := r.enter(1, 0, 0)
+	return err
+}
+
+// waitCqe blocks until a completion is available, and returns it.
+func (r *ioUring) waitCqe() (ioUringCqe, error) {
+	for {
+		head := *r.cqHead
+		if head != atomic.LoadUint32(r.cqTail) {
+			cqe := r.cqes[head&r.cqMask]
+			atomic.StoreUint32(r.cqHead, head+1)
+			return cqe, nil
+		}
+		if _, err := r.enter(0, 1, _IORING_ENTER_GETEVENTS); err != nil {
+			return ioUringCqe{}, err
+		}
+	}
+}
+
+// fuseUringCmdReq is struct fuse_uring_cmd_req, which is carried in
+// the command area of the SQE.
+type fuseUringCmdReq struct {
+	Flags    uint64
+	CommitId uint64
+	Qid      uint16
+	Padding  [6]uint8
+}
+
+// fuseUringEntInOut is struct fuse_uring_ent_in_out.
+type fuseUringEntInOut struct {
+	Flags     uint64
+	CommitId  uint64
+	PayloadSz uint32
+	Padding   uint32
+	Reserved  uint64
+}
+
+// fuseUringReqHeader is struct fuse_uring_req_header. For requests,
+// InOut holds the InHeader, and OpIn holds the opcode specific input
+// struct. For replies, InOut holds the OutHeader; the structured
+// output and the payload both go into the payload buffer.
+type fuseUringReqHeader struct {
+	InOut    [128]byte
+	OpIn     [128]byte
+	EntInOut fuseUringEntInOut
+}
+
+// uringEntry is a request slot registered with the kernel.
+type uringEntry struct {
+	header  fuseUringReqHeader
+	payload []byte
+	iov     [_FUSE_URING_IOV_SEGS]unix.Iovec
+
+	// inHeader + opcode specific data, reassembled from header.
+	inBuf [unsafe.Sizeof(InHeader{}) + unsafe.Sizeof(fuseUringReqHeader{}.OpIn)]byte
+}
+
+type uringQueue struct {
+	server *Server
+	qid    uint16
+	ring   *ioUring
+	ents   []*uringEntry
+
+	// handlers tracks requests that are being processed, so the
+	// ring stays alive until they have queued their reply.
+	handlers sync.WaitGroup
+
+	// commits holds replies that are ready to be committed.
+	commitsMu sync.Mutex
+	commits   []uringCommit
+}
+
+type uringCommit struct {
+	ent      int
+	commitID uint64
+}
+
+// uringWakeup is the user data of the NOP that wakes up the queue
+// thread when a reply is ready.
+const uringWakeup = ^uint64(0)
+
+func (ms *Server) ioUringNegotiated() bool {
+	return ms.opts.EnableIOUring &&
+		ms.kernelSettings.Flags64()&CAP_OVER_IO_URING != 0 &&
+		ms.opts.DisabledCapabilities&CAP_OVER_IO_URING == 0
+}
+
+// possibleCPUs returns the number of CPUs the kernel may bring
+// online. The kernel sizes its queue table on this number.
+func possibleCPUs() int {
+	data, err := os.ReadFile("/sys/devices/system/cpu/possible")
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	s := strings.TrimSpace(string(data))
+	if i := strings.LastIndexAny(s, "-,"); i >= 0 {
+		s = s[i+1:]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	return n + 1
+}
+
+// startIOUring sets up a ring for each CPU and registers it with the
+// kernel. If this fails, requests keep flowing through the /dev/fuse
+// read loop.
+func (ms *Server) startIOUring() {
+	if !ms.ioUringNegotiated() {
+		return
+	}
+
+	depth := ms.opts.IOUringQueueDepth
+	if depth <= 0 {
+		depth = defaultIOUringQueueDepth
+	}
+	pageSize := syscall.Getpagesize()
+	maxPages := (ms.opts.MaxWrite-1)/pageSize + 1
+	payloadSize := max(_FUSE_MIN_READ_BUFFER, ms.opts.MaxWrite, maxPages*pageSize)
+
+	var queues []*uringQueue
+	for qid := 0; qid < possibleCPUs(); qid++ {
+		ring, err := newIOUring(uint32(depth))
+		if err != nil {
+			ms.opts.Logger.Printf("io_uring setup failed: %v; using /dev/fuse", err)
+			for _, q := range queues {
+				q.ring.close()
+			}
+			return
+		}
+		q := &uringQueue{
+			server: ms,
+			qid:    uint16(qid),
+			ring:   ring,
+		}
+		for i := 0; i < depth; i++ {
+			e := &uringEntry{
+				payload: make([]byte, payloadSize),
+			}
+			e.iov[0].Base = (*byte)(unsafe.Pointer(&e.header))
+			e.iov[0].SetLen(int(unsafe.Sizeof(e.header)))
+			e.iov[1].Base = &e.payload[0]
+			e.iov[1].SetLen(len(e.payload))
+			q.ents = append(q.ents, e)
+		}
+		queues = append(queues, q)
+	}
+
+	for _, q := range queues {
+		ms.loops.Add(1)
+		go q.serve()
+	}
+}
+
+func (q *uringQueue) fillCmd(sqe *ioUringSqe, op uint32, ent int, commitID uint64) {
+	sqe.Opcode = _IORING_OP_URING_CMD
+	sqe.Fd = int32(q.server.mountFd)
+	sqe.CmdOp = op
+	sqe.UserData = uint64(ent)
+	cmd := (*fuseUringCmdReq)(unsafe.Pointer(&sqe.Cmd[0]))
+	cmd.CommitId = commitID
+	cmd.Qid = q.qid
+}
+
+func (q *uringQueue) register(ent int) error {
+	e := q.ents[ent]
+	return q.ring.submit(func(sqe *ioUringSqe) {
+		q.fillCmd(sqe, _FUSE_IO_URING_CMD_REGISTER, ent, 0)
+		sqe.Addr = uint64(uintptr(unsafe.Pointer(&e.iov[0])))
+		sqe.Len = _FUSE_URING_IOV_SEGS
+	})
+}
+
+func (q *uringQueue) serve() {
+	ms := q.server
+	defer ms.loops.Done()
+	defer q.ring.close()
+	defer q.handlers.Wait()
+
+	// The kernel picks the queue based on the CPU of the calling
+	// process; keep the completion reaper close to it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var set unix.CPUSet
+	set.Set(int(q.qid))
+	unix.SchedSetaffinity(0, &set)
+
+	for i := range q.ents {
+		if err := q.register(i); err != nil {
+			ms.opts.Logger.Printf("io_uring queue %d: register: %v; using /dev/fuse", q.qid, err)
+			return
+		}
+	}
+
+	for {
+		if err := q.flushCommits(); err != nil {
+			ms.opts.Logger.Printf("io_uring queue %d: commit: %v", q.qid, err)
+			return
+		}
+		cqe, err := q.ring.waitCqe()
+		if err != nil {
+			ms.opts.Logger.Printf("io_uring queue %d: %v", q.qid, err)
+			return
+		}
+		if cqe.Res < 0 {
+			switch errno := syscall.Errno(-cqe.Res); errno {
+			case syscall.ENOTCONN, syscall.ECONNABORTED, syscall.ENODEV, syscall.ECANCELED:
+				// Unmounted.
+				if ms.opts.Debug {
+					ms.opts.Logger.Printf("io_uring queue %d: %v, exiting", q.qid, errno)
+				}
+			default:
+				// Registration was refused (eg. the
+				// enable_uring module parameter is not
+				// set). The kernel disables io_uring for
+				// the connection, so requests go through
+				// the read loop instead.
+				if q.qid == 0 || ms.opts.Debug {
+					ms.opts.Logger.Printf("io_uring queue %d: kernel refused: %v; using /dev/fuse", q.qid, errno)
+				}
+			}
+			return
+		}
+		if cqe.UserData == uringWakeup {
+			continue
+		}
+		if cqe.UserData >= uint64(len(q.ents)) {
+			ms.opts.Logger.Printf("io_uring queue %d: unknown entry %d", q.qid, cqe.UserData)
+			continue
+		}
+		q.handlers.Add(1)
+		go q.handle(int(cqe.UserData))
+	}
+}
+
+// handle processes the request in entry `ent`, and queues the reply
+// for the queue thread.
+//
+// The commit must be submitted by the queue thread: the kernel
+// delivers the next request for an entry as task work on the thread
+// that submitted the fetch. If that were an arbitrary Go thread, it
+// might be blocked, possibly on a FUSE request itself.
+func (q *uringQueue) handle(ent int) {
+	defer q.handlers.Done()
+	e := q.ents[ent]
+	commitID := e.header.EntInOut.CommitId
+	e.header.EntInOut.PayloadSz = q.process(e)
+
+	q.commitsMu.Lock()
+	q.commits = append(q.commits, uringCommit{ent, commitID})
+	q.commitsMu.Unlock()
+	if err := q.ring.submit(func(sqe *ioUringSqe) {
+		sqe.Opcode = _IORING_OP_NOP
+		sqe.UserData = uringWakeup
+	}); err != nil && q.server.opts.Debug {
+		q.server.opts.Logger.Printf("io_uring queue %d: wakeup: %v", q.qid, err)
+	}
+}
+
+// flushCommits commits the queued replies, which also fetches the
+// next request for their entries.
+func (q *uringQueue) flushCommits() error {
+	q.commitsMu.Lock()
+	commits := q.commits
+	q.commits = nil
+	q.commitsMu.Unlock()
+
+	for _, c := range commits {
+		if err := q.ring.submit(func(sqe *ioUringSqe) {
+			q.fillCmd(sqe, _FUSE_IO_URING_CMD_COMMIT_AND_FETCH, c.ent, c.commitID)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// process runs the request found in the entry through the protocol
+// server, and puts the reply back into the entry. It returns the
+// size of the reply payload.
+func (q *uringQueue) process(e *uringEntry) uint32 {
+	n := q.handleEntry(e)
+	if rec := q.server.recorder; rec != nil {
+		rec.record(RecordReply, e.header.InOut[:sizeOfOutHeader], e.payload[:n])
+	}
+	return n
+}
+
+func (q *uringQueue) handleEntry(e *uringEntry) uint32 {
+	ms := q.server
+	hdrSize := int(unsafe.Sizeof(InHeader{}))
+	copy(e.inBuf[:hdrSize], e.header.InOut[:hdrSize])
+	copy(e.inBuf[hdrSize:], e.header.OpIn[:])
+	hdr := (*InHeader)(unsafe.Pointer(&e.inBuf[0]))
+
+	reply := func(code Status) uint32 {
+		o := (*OutHeader)(unsafe.Pointer(&e.header.InOut[0]))
+		*o = OutHeader{
+			Length: uint32(sizeOfOutHeader),
+			Status: int32(-code),
+			Unique: hdr.Unique,
+		}
+		return 0
+	}
+
+	h, inSize, outSize, outPayloadSize, code := parseRequest(e.inBuf[:], &ms.kernelSettings)
+	if !code.Ok() {
+		ms.opts.Logger.Printf("parseRequest: %v", code)
+		return reply(code)
+	}
+	payloadSz := min(int(e.header.EntInOut.PayloadSz), len(e.payload))
+	if ms.recorder != nil {
+		ms.recorder.record(RecordRequest, e.inBuf[:inSize], e.payload[:payloadSz])
+	}
+
+	req := ms.reqPool.Get().(*requestAlloc)
+	defer ms.returnRequest(req)
+	if ms.latencies != nil {
+		req.startTime = time.Now()
+	}
+	if ms.opts.SingleThreaded {
+		ms.requestProcessingMu.Lock()
+		defer ms.requestProcessingMu.Unlock()
+	}
+
+	if ms.beginRequest(hdr.Opcode) {
+		defer ms.endRequest()
+	} else {
+		req.refused = true
+	}
+
+	req.uring = true
+	req.inputBuf = e.inBuf[:inSize]
+	req.inPayload = e.payload[:payloadSz]
+	req.outHeaderBuf = req.outHeaderInline[:]
+	req.outDataBuf = req.outDataInline[:outSize]
+	clear(req.outHeaderBuf)
+	clear(req.outDataBuf)
+	if outPayloadSize > 0 {
+		req.outPayload = ms.buffers.AllocBuffer(uint32(outPayloadSize))
+		req.bufferPoolOutputBuf = req.outPayload
+	}
+	ms.protocolServer.handleRequest(h, &req.request)
+
+	if req.readResult != nil {
+		req.outPayload, req.status = req.readResult.Bytes(req.outPayload)
+		req.readResult.Done()
+		req.readResult = nil
+		req.serializeHeader(len(req.outPayload))
+	}
+	if len(req.outDataBuf)+len(req.outPayload) > len(e.payload) {
+		ms.opts.Logger.Printf("io_uring: reply for %s too large: %d bytes", h.Name, len(req.outDataBuf)+len(req.outPayload))
+		return reply(EIO)
+	}
+	copy(e.header.InOut[:], req.outHeaderBuf)
+	n := copy(e.payload, req.outDataBuf)
+	n += copy(e.payload[n:], req.outPayload)
+	return uint32(n)
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestIOUringStructSizes(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"io_uring_params", unsafe.Sizeof(ioUringParams{}), 120},
+		{"io_uring_sqe (SQE128)", unsafe.Sizeof(ioUringSqe{}), 128},
+		{"io_uring_cqe", unsafe.Sizeof(ioUringCqe{}), 16},
+		{"fuse_uring_cmd_req", unsafe.Sizeof(fuseUringCmdReq{}), 24},
+		{"fuse_uring_req_header", unsafe.Sizeof(fuseUringReqHeader{}), 288},
+	} {
+		if c.got != c.want {
+			t.Errorf("sizeof(%s): got %d, want %d", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestIOUringNop(t *testing.T) {
+	r, err := newIOUring(4)
+	if err == syscall.ENOSYS || err == syscall.EPERM {
+		t.Skipf("io_uring not available: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("newIOUring: %v", err)
+	}
+	defer r.close()
+
+	for i := 0; i < 10; i++ {
+		if err := r.submit(func(sqe *ioUringSqe) {
+			sqe.Opcode = _IORING_OP_NOP
+			sqe.UserData = uint64(i)
+		}); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		cqe, err := r.waitCqe()
+		if err != nil {
+			t.Fatalf("waitCqe: %v", err)
+		}
+		if cqe.Res != 0 || cqe.UserData != uint64(i) {
+			t.Errorf("got cqe %+v, want res 0, user data %d", cqe, i)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// startWatchdog starts a goroutine that periodically looks for
+// requests whose handler has been running for longer than
+// MountOptions.WatchdogTimeout. The returned function stops it, and
+// returns once it is no longer writing to the device.
+func (ms *Server) startWatchdog() func() {
+	if ms.opts.WatchdogTimeout <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ms.opts.WatchdogTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				ms.checkStuck(now)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// checkStuck reports the requests that became stuck since the last
+// check, and fails them if asked to.
+func (ms *Server) checkStuck(now time.Time) {
+	found := false
+	ms.interruptMu.Lock()
+	for _, req := range ms.reqInflight {
+		if req.stuck || req.startTime.IsZero() {
+			continue
+		}
+		dt := now.Sub(req.startTime)
+		if dt < ms.opts.WatchdogTimeout {
+			continue
+		}
+		req.stuck = true
+		found = true
+		ms.opts.Logger.Printf("watchdog: request running for %v: %s", dt.Round(time.Millisecond), req.InputDebug())
+
+		if ms.opts.WatchdogFailRequests && !req.uring && expectsReply(req.inHeader().Opcode) && !req.abandoned.Swap(true) {
+			if st := ms.failRequest(req, EIO); !st.Ok() {
+				ms.opts.Logger.Printf("watchdog: failing request %d: %v", req.inHeader().Unique, st)
+			}
+			if !req.interrupted {
+				close(req.cancel)
+				req.interrupted = true
+			}
+		}
+	}
+	ms.interruptMu.Unlock()
+
+	if found && ms.opts.WatchdogDumpStacks {
+		ms.opts.Logger.Printf("watchdog: goroutine stacks:\n%s", allStacks())
+	}
+}
+
+// expectsReply returns whether the kernel waits for a reply to a
+// request with the given opcode. An INTERRUPT is only answered if it
+// cannot be matched to a request.
+func expectsReply(op uint32) bool {
+	if op == _OP_INTERRUPT {
+		return false
+	}
+	h := getHandler(op)
+	return h == nil || !h.SuppressReply
+}
+
+// failRequest answers req with the given error, independent of its
+// handler.
+func (ms *Server) failRequest(req *request, code Status) Status {
+	hdr := OutHeader{
+		Length: uint32(sizeOfOutHeader),
+		Status: -int32(code),
+		Unique: req.inHeader().Unique,
+	}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&hdr)), sizeOfOutHeader)
+	if ms.recorder != nil {
+		ms.recorder.record(RecordReply, buf)
+	}
+
+	// Protect against concurrent close.
+	ms.writeMu.Lock()
+	defer ms.writeMu.Unlock()
+	err := handleEINTR(func() error {
+		_, err := writev(req.fd, [][]byte{buf})
+		return err
+	})
+	return ToStatus(err)
+}
+
+// allStacks returns the stacks of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
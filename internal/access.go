@@ -10,8 +10,23 @@ import (
 )
 
 // HasAccess tests if a caller can access a file with permissions
-// `perm` in mode `mask`
+// `perm` in mode `mask`. The supplementary groups of the caller are
+// taken from the user database.
 func HasAccess(callerUid, callerGid, fileUid, fileGid uint32, perm uint32, mask uint32) bool {
+	return hasAccess(callerUid, callerGid, fileUid, fileGid, perm, mask, func() []uint32 {
+		return userGroups(callerUid)
+	})
+}
+
+// HasGroupAccess is like HasAccess, but uses `groups` as the
+// supplementary groups of the caller.
+func HasGroupAccess(callerUid, callerGid uint32, groups []uint32, fileUid, fileGid uint32, perm uint32, mask uint32) bool {
+	return hasAccess(callerUid, callerGid, fileUid, fileGid, perm, mask, func() []uint32 {
+		return groups
+	})
+}
+
+func hasAccess(callerUid, callerGid, fileUid, fileGid uint32, perm uint32, mask uint32, groups func() []uint32) bool {
 	if callerUid == 0 {
 		// root can do anything.
 		return true
@@ -41,20 +56,30 @@ func HasAccess(callerUid, callerGid, fileUid, fileGid uint32, perm uint32, mask
 		return false
 	}
 
-	u, err := user.LookupId(strconv.Itoa(int(callerUid)))
+	for _, g := range groups() {
+		if g == fileGid {
+			return true
+		}
+	}
+	return false
+}
+
+// userGroups returns the groups of the user from the user database.
+func userGroups(uid uint32) []uint32 {
+	u, err := user.LookupId(strconv.Itoa(int(uid)))
 	if err != nil {
-		return false
+		return nil
 	}
 	gs, err := u.GroupIds()
 	if err != nil {
-		return false
+		return nil
 	}
-
-	fileGidStr := strconv.Itoa(int(fileGid))
+	var result []uint32
 	for _, gidStr := range gs {
-		if gidStr == fileGidStr {
-			return true
+		g, err := strconv.ParseUint(gidStr, 10, 32)
+		if err == nil {
+			result = append(result, uint32(g))
 		}
 	}
-	return false
+	return result
 }
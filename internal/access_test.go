@@ -74,3 +74,16 @@ func TestHasAccess(t *testing.T) {
 		}
 	}
 }
+
+func TestHasGroupAccess(t *testing.T) {
+	groups := []uint32{20, 30}
+	if !HasGroupAccess(1000, 10, groups, 2000, 30, 0070, 04) {
+		t.Error("supplementary group 30 should grant access")
+	}
+	if HasGroupAccess(1000, 10, groups, 2000, 40, 0070, 04) {
+		t.Error("group 40 is not a group of the caller")
+	}
+	if HasGroupAccess(1000, 10, nil, 2000, 30, 0070, 04) {
+		t.Error("caller without supplementary groups should not get access")
+	}
+}
@@ -0,0 +1,8 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fsmount creates FUSE mounts with the Linux mount API,
+// fsopen(2), fsconfig(2) and fsmount(2). It is shared by package fuse
+// and the mount broker.
+package fsmount
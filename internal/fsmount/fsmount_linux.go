@@ -0,0 +1,123 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fsmount
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrUnavailable is returned (wrapped) by Mount if the kernel does
+// not support the new mount API, and mount(2) should be used instead.
+var ErrUnavailable = errors.New("new mount API unavailable")
+
+// Attrs converts mount(2) flags to the MOUNT_ATTR_* flags for
+// fsmount(2), and the superblock flags that are passed to fsconfig(2)
+// as parameters. It returns false if some flags have no equivalent.
+func Attrs(flags uintptr) (attrs int, sbFlags []string, ok bool) {
+	if flags&syscall.MS_MGC_MSK == syscall.MS_MGC_VAL {
+		flags &^= syscall.MS_MGC_MSK
+	}
+	for _, f := range []struct {
+		flag uintptr
+		attr int
+	}{
+		{syscall.MS_RDONLY, unix.MOUNT_ATTR_RDONLY},
+		{syscall.MS_NOSUID, unix.MOUNT_ATTR_NOSUID},
+		{syscall.MS_NODEV, unix.MOUNT_ATTR_NODEV},
+		{syscall.MS_NOEXEC, unix.MOUNT_ATTR_NOEXEC},
+		{syscall.MS_NOATIME, unix.MOUNT_ATTR_NOATIME},
+		{syscall.MS_NODIRATIME, unix.MOUNT_ATTR_NODIRATIME},
+		{syscall.MS_STRICTATIME, unix.MOUNT_ATTR_STRICTATIME},
+		{syscall.MS_RELATIME, unix.MOUNT_ATTR_RELATIME},
+	} {
+		if flags&f.flag != 0 {
+			attrs |= f.attr
+			flags &^= f.flag
+		}
+	}
+	if attrs&unix.MOUNT_ATTR_RDONLY != 0 {
+		// mount(2) makes both the mount and the superblock read-only.
+		sbFlags = append(sbFlags, "ro")
+	}
+	for _, f := range []struct {
+		flag uintptr
+		name string
+	}{
+		{syscall.MS_SYNCHRONOUS, "sync"},
+		{syscall.MS_DIRSYNC, "dirsync"},
+	} {
+		if flags&f.flag != 0 {
+			sbFlags = append(sbFlags, f.name)
+			flags &^= f.flag
+		}
+	}
+	return attrs, sbFlags, flags == 0
+}
+
+// Mount creates a detached FUSE mount, and returns its file
+// descriptor. The source, the subtype if it is not empty, and each of
+// params, either "key=value" or a flag, are passed to fsconfig(2)
+// separately, so errors name the parameter that the kernel rejected.
+// Errors include the messages that the kernel logged for the
+// file system context.
+func Mount(source, subtype string, params []string, attrs int) (int, error) {
+	fsFd, err := unix.Fsopen("fuse", unix.FSOPEN_CLOEXEC)
+	if err != nil {
+		return -1, fmt.Errorf("%w: fsopen: %v", ErrUnavailable, err)
+	}
+	defer syscall.Close(fsFd)
+
+	if err := unix.FsconfigSetString(fsFd, "source", source); err != nil {
+		return -1, contextError(fsFd, "fsconfig source", err)
+	}
+	if subtype != "" {
+		if err := unix.FsconfigSetString(fsFd, "subtype", subtype); err != nil {
+			return -1, contextError(fsFd, "fsconfig subtype", err)
+		}
+	}
+	for _, p := range params {
+		key, value, hasValue := strings.Cut(p, "=")
+		if hasValue {
+			err = unix.FsconfigSetString(fsFd, key, value)
+		} else {
+			err = unix.FsconfigSetFlag(fsFd, key)
+		}
+		if err != nil {
+			return -1, contextError(fsFd, fmt.Sprintf("fsconfig %q", p), err)
+		}
+	}
+	if err := unix.FsconfigCreate(fsFd); err != nil {
+		return -1, contextError(fsFd, "fsconfig create", err)
+	}
+	mntFd, err := unix.Fsmount(fsFd, unix.FSMOUNT_CLOEXEC, attrs)
+	if err != nil {
+		return -1, contextError(fsFd, "fsmount", err)
+	}
+	return mntFd, nil
+}
+
+// contextError wraps err, which was returned for operation op on
+// the file system context fsFd, with the messages that the kernel
+// logged for the context.
+func contextError(fsFd int, op string, err error) error {
+	var msgs []string
+	buf := make([]byte, 1024)
+	for {
+		n, rerr := unix.Read(fsFd, buf)
+		if rerr != nil || n <= 0 {
+			break
+		}
+		msgs = append(msgs, strings.TrimSpace(string(buf[:n])))
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("%s: %w (%s)", op, err, strings.Join(msgs, "; "))
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}